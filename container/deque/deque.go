@@ -0,0 +1,166 @@
+// Package deque 实现基于环形缓冲区的双端队列
+// 两端的Push与Pop均摊复杂度都是O(1), 可以按下标访问;
+// 元素放在一块连续的内存里, 做队列或者栈时比container/list快得多, 分配也少
+package deque
+
+// minCap 是缓冲区的最小容量, 必须是2的幂
+const minCap = 8
+
+// Deque 是双端队列, 零值是一个可以直接使用的空队列
+// Deque不是并发安全的
+type Deque[T any] struct {
+	buf  []T // 长度总是0或者2的幂, 这样取模可以用位与
+	head int // 第一个元素的下标
+	n    int
+}
+
+// New 返回一个空的Deque, 预先分配至少能放下size个元素的空间
+func New[T any](size int) *Deque[T] {
+	d := new(Deque[T])
+	if size > 0 {
+		d.buf = make([]T, capFor(size))
+	}
+	return d
+}
+
+// capFor 返回不小于n的2的幂, 至少是minCap
+func capFor(n int) int {
+	c := minCap
+	for c < n {
+		c <<= 1
+	}
+	return c
+}
+
+// Len 返回元素的个数
+func (d *Deque[T]) Len() int {
+	return d.n
+}
+
+// Cap 返回不用扩容时能放下的元素个数
+func (d *Deque[T]) Cap() int {
+	return len(d.buf)
+}
+
+// index 把第i个元素换算成buf里的下标
+func (d *Deque[T]) index(i int) int {
+	return (d.head + i) & (len(d.buf) - 1)
+}
+
+// PushBack 在队尾加入v
+func (d *Deque[T]) PushBack(v T) {
+	d.grow()
+	d.buf[d.index(d.n)] = v
+	d.n++
+}
+
+// PushFront 在队头加入v
+func (d *Deque[T]) PushFront(v T) {
+	d.grow()
+	d.head = (d.head - 1) & (len(d.buf) - 1)
+	d.buf[d.head] = v
+	d.n++
+}
+
+// PopFront 删除并返回队头的元素, 队列为空时ok为false
+func (d *Deque[T]) PopFront() (v T, ok bool) {
+	if d.n == 0 {
+		return v, false
+	}
+	var zero T
+	v = d.buf[d.head]
+	d.buf[d.head] = zero // 不要让缓冲区继续引用它
+	d.head = (d.head + 1) & (len(d.buf) - 1)
+	d.n--
+	d.shrink()
+	return v, true
+}
+
+// PopBack 删除并返回队尾的元素, 队列为空时ok为false
+func (d *Deque[T]) PopBack() (v T, ok bool) {
+	if d.n == 0 {
+		return v, false
+	}
+	var zero T
+	i := d.index(d.n - 1)
+	v = d.buf[i]
+	d.buf[i] = zero
+	d.n--
+	d.shrink()
+	return v, true
+}
+
+// Front 返回队头的元素, 但是不删除它
+func (d *Deque[T]) Front() (v T, ok bool) {
+	if d.n == 0 {
+		return v, false
+	}
+	return d.buf[d.head], true
+}
+
+// Back 返回队尾的元素, 但是不删除它
+func (d *Deque[T]) Back() (v T, ok bool) {
+	if d.n == 0 {
+		return v, false
+	}
+	return d.buf[d.index(d.n-1)], true
+}
+
+// At 返回第i个元素, 队头是第0个
+// i超出范围时panic
+func (d *Deque[T]) At(i int) T {
+	d.checkIndex(i)
+	return d.buf[d.index(i)]
+}
+
+// Set 把第i个元素设为v, i超出范围时panic
+func (d *Deque[T]) Set(i int, v T) {
+	d.checkIndex(i)
+	d.buf[d.index(i)] = v
+}
+
+func (d *Deque[T]) checkIndex(i int) {
+	if i < 0 || i >= d.n {
+		panic("deque: index out of range")
+	}
+}
+
+// Clear 删除所有元素, 保留已经分配的空间
+func (d *Deque[T]) Clear() {
+	clear(d.buf)
+	d.head = 0
+	d.n = 0
+}
+
+// grow 在缓冲区满时把容量翻倍
+func (d *Deque[T]) grow() {
+	if d.n < len(d.buf) {
+		return
+	}
+	if len(d.buf) == 0 {
+		d.buf = make([]T, minCap)
+		return
+	}
+	d.resize(len(d.buf) << 1)
+}
+
+// shrink 在元素只占四分之一时把容量减半, 避免一次很多元素之后一直占着内存
+// 减半而不是减到四分之一, 这样在边界上反复Push与Pop时不会每次都重新分配
+func (d *Deque[T]) shrink() {
+	if len(d.buf) > minCap && d.n <= len(d.buf)>>2 {
+		d.resize(len(d.buf) >> 1)
+	}
+}
+
+// resize 把元素按顺序复制到一个容量为c的新缓冲区的开头
+func (d *Deque[T]) resize(c int) {
+	buf := make([]T, c)
+	if d.head+d.n <= len(d.buf) {
+		copy(buf, d.buf[d.head:d.head+d.n])
+	} else {
+		k := copy(buf, d.buf[d.head:])
+		copy(buf[k:], d.buf[:d.n-k])
+	}
+	d.buf = buf
+	d.head = 0
+}
@@ -0,0 +1,183 @@
+package deque
+
+import (
+	"container/list"
+	"math/rand"
+	"testing"
+)
+
+// check 比较d与model, 同时检查容量是2的幂
+func check(t *testing.T, d *Deque[int], model []int) {
+	t.Helper()
+	if d.Len() != len(model) {
+		t.Fatalf("Len = %d, want %d", d.Len(), len(model))
+	}
+	if c := d.Cap(); c != 0 && c&(c-1) != 0 {
+		t.Fatalf("Cap = %d, not a power of two", c)
+	}
+	for i, v := range model {
+		if got := d.At(i); got != v {
+			t.Fatalf("At(%d) = %d, want %d", i, got, v)
+		}
+	}
+	f, okf := d.Front()
+	b, okb := d.Back()
+	if len(model) == 0 {
+		if okf || okb {
+			t.Fatal("Front or Back on empty deque succeeded")
+		}
+		return
+	}
+	if !okf || !okb || f != model[0] || b != model[len(model)-1] {
+		t.Fatalf("Front, Back = %d, %d; want %d, %d", f, b, model[0], model[len(model)-1])
+	}
+}
+
+func TestDequeRandomOps(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var d Deque[int] // 零值可以直接使用
+	var model []int
+	for i := 0; i < 20000; i++ {
+		// 前一半多放, 后一半多取, 这样会扩容也会缩容
+		push := r.Intn(10) < 6
+		if i > 10000 {
+			push = r.Intn(10) < 4
+		}
+		switch op := r.Intn(2); {
+		case push && op == 0:
+			d.PushBack(i)
+			model = append(model, i)
+		case push:
+			d.PushFront(i)
+			model = append([]int{i}, model...)
+		case op == 0:
+			v, ok := d.PopFront()
+			if len(model) == 0 {
+				if ok {
+					t.Fatal("PopFront on empty deque succeeded")
+				}
+				continue
+			}
+			if !ok || v != model[0] {
+				t.Fatalf("PopFront = %d, %v; want %d", v, ok, model[0])
+			}
+			model = model[1:]
+		default:
+			v, ok := d.PopBack()
+			if len(model) == 0 {
+				if ok {
+					t.Fatal("PopBack on empty deque succeeded")
+				}
+				continue
+			}
+			if !ok || v != model[len(model)-1] {
+				t.Fatalf("PopBack = %d, %v; want %d", v, ok, model[len(model)-1])
+			}
+			model = model[:len(model)-1]
+		}
+		if i%100 == 0 {
+			check(t, &d, model)
+		}
+	}
+	check(t, &d, model)
+}
+
+func TestDequeIndex(t *testing.T) {
+	d := New[string](3)
+	if d.Cap() != minCap {
+		t.Errorf("Cap = %d, want %d", d.Cap(), minCap)
+	}
+	// 让元素跨过缓冲区的末尾
+	for i := 0; i < 6; i++ {
+		d.PushBack("x")
+		d.PopFront()
+	}
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		d.PushBack(s)
+	}
+	d.Set(2, "C")
+	if got := d.At(2); got != "C" {
+		t.Errorf("At(2) = %q after Set", got)
+	}
+	for _, i := range []int{-1, 5} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("At(%d) did not panic", i)
+				}
+			}()
+			d.At(i)
+		}()
+	}
+	d.Clear()
+	if d.Len() != 0 || d.Cap() != minCap {
+		t.Errorf("after Clear: Len = %d, Cap = %d", d.Len(), d.Cap())
+	}
+}
+
+func TestDequeShrink(t *testing.T) {
+	var d Deque[int]
+	for i := 0; i < 1000; i++ {
+		d.PushBack(i)
+	}
+	for i := 0; i < 1000; i++ {
+		if v, _ := d.PopFront(); v != i {
+			t.Fatalf("PopFront = %d, want %d", v, i)
+		}
+	}
+	if d.Cap() != minCap {
+		t.Errorf("Cap = %d after draining, want %d", d.Cap(), minCap)
+	}
+}
+
+// 队列: 保持benchQueueLen个元素, 每次从队尾放入一个, 从队头取出一个
+const benchQueueLen = 1000
+
+func BenchmarkDequeQueue(b *testing.B) {
+	var d Deque[int]
+	for i := 0; i < benchQueueLen; i++ {
+		d.PushBack(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.PushBack(i)
+		d.PopFront()
+	}
+}
+
+func BenchmarkListQueue(b *testing.B) {
+	l := list.New()
+	for i := 0; i < benchQueueLen; i++ {
+		l.PushBack(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.PushBack(i)
+		l.Remove(l.Front())
+	}
+}
+
+// 突发: 一次放入很多再全部取出, 包括扩容与缩容
+func BenchmarkDequeBurst(b *testing.B) {
+	var d Deque[int]
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchQueueLen; j++ {
+			d.PushBack(j)
+		}
+		for d.Len() > 0 {
+			d.PopFront()
+		}
+	}
+}
+
+func BenchmarkListBurst(b *testing.B) {
+	l := list.New()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchQueueLen; j++ {
+			l.PushBack(j)
+		}
+		for l.Len() > 0 {
+			l.Remove(l.Front())
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package heap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClosed 是往已经关闭的BlockingQueue里放元素, 或者关闭后取完了元素时返回的错误
+var ErrClosed = errors.New("heap: queue closed")
+
+// BlockingQueue 是并发安全的优先队列, 底下是一个heap.Interface
+// 队列为空时Pop阻塞, 设置了容量时队列满了Push阻塞, 两者都可以用context取消
+//
+// 关闭以后不能再Push, 但是Pop还能把剩下的元素按优先级取完, 取完以后返回ErrClosed
+type BlockingQueue struct {
+	mu       sync.Mutex
+	h        Interface
+	capacity int
+	closed   bool
+
+	// changed 在每次状态变化时关闭并换一个新的, 等待的goroutine都会被唤醒
+	// sync.Cond不能和context一起select, 所以用channel
+	changed chan struct{}
+	waiters int // 正在等待changed的goroutine数, 没有人等时不用换channel
+}
+
+// NewBlockingQueue 返回一个使用h的BlockingQueue
+// h里已有的元素会先用Init整理成堆, 之后h只能通过BlockingQueue访问
+// capacity<=0表示不限制容量
+func NewBlockingQueue(h Interface, capacity int) *BlockingQueue {
+	Init(h)
+	return &BlockingQueue{
+		h:        h,
+		capacity: capacity,
+		changed:  make(chan struct{}),
+	}
+}
+
+// broadcastLocked 唤醒所有等待的goroutine, 调用时必须持有q.mu
+func (q *BlockingQueue) broadcastLocked() {
+	if q.waiters == 0 {
+		return
+	}
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// wait 等待下一次状态变化或者ctx被取消, 调用与返回时都持有q.mu
+func (q *BlockingQueue) wait(ctx context.Context) error {
+	changed := q.changed
+	q.waiters++
+	q.mu.Unlock()
+	var err error
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	q.mu.Lock()
+	q.waiters--
+	return err
+}
+
+// Len 返回队列里元素的个数
+func (q *BlockingQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.h.Len()
+}
+
+// Push 放入x, 队列满时一直等待
+func (q *BlockingQueue) Push(x interface{}) error {
+	return q.PushCtx(context.Background(), x)
+}
+
+// PushCtx 放入x, 队列满时等待, 直到有空位, ctx被取消或者队列被关闭
+func (q *BlockingQueue) PushCtx(ctx context.Context, x interface{}) error {
+	q.mu.Lock()
+	for {
+		if q.closed {
+			q.mu.Unlock()
+			return ErrClosed
+		}
+		if q.capacity <= 0 || q.h.Len() < q.capacity {
+			break
+		}
+		if err := q.wait(ctx); err != nil {
+			q.mu.Unlock()
+			return err
+		}
+	}
+	Push(q.h, x)
+	q.broadcastLocked()
+	q.mu.Unlock()
+	return nil
+}
+
+// TryPush 不等待地放入x, 队列满了或者已经关闭时返回false
+func (q *BlockingQueue) TryPush(x interface{}) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.capacity > 0 && q.h.Len() >= q.capacity {
+		return false
+	}
+	Push(q.h, x)
+	q.broadcastLocked()
+	return true
+}
+
+// Pop 取出优先级最高的元素, 队列为空时一直等待
+func (q *BlockingQueue) Pop() (interface{}, error) {
+	return q.PopCtx(context.Background())
+}
+
+// PopTimeout 和Pop一样, 但是最多等待d, 超时返回context.DeadlineExceeded
+func (q *BlockingQueue) PopTimeout(d time.Duration) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return q.PopCtx(ctx)
+}
+
+// PopCtx 取出优先级最高的元素, 队列为空时等待, 直到有元素, ctx被取消,
+// 或者队列被关闭(此时返回ErrClosed)
+func (q *BlockingQueue) PopCtx(ctx context.Context) (interface{}, error) {
+	q.mu.Lock()
+	for q.h.Len() == 0 {
+		if q.closed {
+			q.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if err := q.wait(ctx); err != nil {
+			q.mu.Unlock()
+			return nil, err
+		}
+	}
+	x := Pop(q.h)
+	q.broadcastLocked()
+	q.mu.Unlock()
+	return x, nil
+}
+
+// TryPop 不等待地取出优先级最高的元素, 队列为空时ok为false
+func (q *BlockingQueue) TryPop() (x interface{}, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.h.Len() == 0 {
+		return nil, false
+	}
+	x = Pop(q.h)
+	q.broadcastLocked()
+	return x, true
+}
+
+// Close 关闭队列: 之后的Push都返回ErrClosed, 阻塞在Push里的也一样
+// 剩下的元素还可以取出来, 队列空了以后Pop返回ErrClosed
+// 重复关闭没有影响
+func (q *BlockingQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.broadcastLocked()
+}
+
+// Drain 按优先级顺序取出所有剩下的元素, 不等待
+// 通常在Close之后调用, 处理还没有被消费的元素
+func (q *BlockingQueue) Drain() []interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := make([]interface{}, 0, q.h.Len())
+	for q.h.Len() > 0 {
+		items = append(items, Pop(q.h))
+	}
+	if len(items) > 0 {
+		q.broadcastLocked()
+	}
+	return items
+}
@@ -0,0 +1,171 @@
+package heap
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBlockingQueueOrderAndClose(t *testing.T) {
+	q := NewBlockingQueue(&intHeap{5, 3, 9}, 0)
+	for _, v := range []int{1, 7} {
+		if err := q.Push(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if x, _ := q.Pop(); x != 1 {
+		t.Errorf("Pop = %v, want 1", x)
+	}
+	q.Close()
+	q.Close()
+	if err := q.Push(0); err != ErrClosed {
+		t.Errorf("Push after Close = %v", err)
+	}
+	// 关闭以后还能按顺序取完
+	for _, want := range []int{3, 5, 7, 9} {
+		if x, err := q.Pop(); err != nil || x != want {
+			t.Errorf("Pop = %v, %v; want %d", x, err, want)
+		}
+	}
+	if _, err := q.Pop(); err != ErrClosed {
+		t.Errorf("Pop on drained closed queue = %v", err)
+	}
+}
+
+func TestBlockingQueueTimeoutAndCancel(t *testing.T) {
+	q := NewBlockingQueue(&intHeap{}, 1)
+	start := time.Now()
+	if _, err := q.PopTimeout(20 * time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("PopTimeout = %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("PopTimeout returned early")
+	}
+
+	q.Push(1)
+	if q.TryPush(2) {
+		t.Error("TryPush succeeded on full queue")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- q.PushCtx(ctx, 2) }()
+	select {
+	case err := <-done:
+		t.Fatalf("PushCtx on full queue returned %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("PushCtx after cancel = %v", err)
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len = %d, want 1", q.Len())
+	}
+}
+
+func TestBlockingQueueWakeups(t *testing.T) {
+	q := NewBlockingQueue(&intHeap{}, 1)
+
+	// 阻塞的Pop被Push唤醒
+	got := make(chan interface{})
+	go func() {
+		x, _ := q.Pop()
+		got <- x
+	}()
+	time.Sleep(5 * time.Millisecond)
+	q.Push(42)
+	if x := <-got; x != 42 {
+		t.Errorf("Pop = %v, want 42", x)
+	}
+
+	// 阻塞的Push被Pop唤醒
+	q.Push(1)
+	pushed := make(chan error)
+	go func() { pushed <- q.Push(2) }()
+	time.Sleep(5 * time.Millisecond)
+	q.Pop()
+	if err := <-pushed; err != nil {
+		t.Errorf("Push = %v", err)
+	}
+
+	// 阻塞的Push与Pop都被Close唤醒
+	go func() { pushed <- q.Push(3) }()
+	empty := NewBlockingQueue(&intHeap{}, 0)
+	popped := make(chan error)
+	go func() {
+		_, err := empty.Pop()
+		popped <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	q.Close()
+	empty.Close()
+	if err := <-pushed; err != ErrClosed {
+		t.Errorf("blocked Push after Close = %v", err)
+	}
+	if err := <-popped; err != ErrClosed {
+		t.Errorf("blocked Pop after Close = %v", err)
+	}
+	if items := q.Drain(); len(items) != 1 || items[0] != 2 {
+		t.Errorf("Drain = %v", items)
+	}
+}
+
+// TestBlockingQueueStress 多个生产者与消费者并发, 用-race运行
+// 每个元素必须恰好被取出一次
+func TestBlockingQueueStress(t *testing.T) {
+	const producers, consumers, perProducer = 8, 8, 2000
+	q := NewBlockingQueue(&intHeap{}, 16)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				if err := q.Push(p*perProducer + i); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(p)
+	}
+
+	seen := make([][]int, consumers)
+	var cwg sync.WaitGroup
+	for c := 0; c < consumers; c++ {
+		cwg.Add(1)
+		go func(c int) {
+			defer cwg.Done()
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				x, err := q.PopCtx(ctx)
+				cancel()
+				if err == ErrClosed {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				seen[c] = append(seen[c], x.(int))
+			}
+		}(c)
+	}
+
+	wg.Wait()
+	q.Close()
+	cwg.Wait()
+
+	count := make([]int, producers*perProducer)
+	for _, s := range seen {
+		for _, x := range s {
+			count[x]++
+		}
+	}
+	for x, n := range count {
+		if n != 1 {
+			t.Fatalf("item %d popped %d times", x, n)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package heap
+
+// Heap 是类型安全的最小堆的公共接口, DaryHeap与PairingHeap都实现了它
+// 哪个元素最小由构造时传入的less决定
+type Heap[T any] interface {
+	Len() int
+	Push(v T)
+	Peek() (v T, ok bool)
+	Pop() (v T, ok bool)
+}
+
+var (
+	_ Heap[int] = (*DaryHeap[int])(nil)
+	_ Heap[int] = (*PairingHeap[int])(nil)
+)
+
+// DaryHeap 是每个节点有d个子节点的最小堆
+// 比二叉堆矮, Push与上浮更快; 下沉时每层要比较d个子节点, 但是它们在内存里相邻,
+// 元素多, 比较便宜时d取4或者8通常比二叉堆快
+// DaryHeap不是并发安全的
+type DaryHeap[T any] struct {
+	d     int
+	items []T
+	less  func(a, b T) bool
+}
+
+// NewDaryHeap 返回一个每个节点有d个子节点的空堆, less(a, b)为true时a先出堆
+// d小于2时panic
+func NewDaryHeap[T any](d int, less func(a, b T) bool) *DaryHeap[T] {
+	if d < 2 {
+		panic("heap: arity less than 2 for NewDaryHeap")
+	}
+	return &DaryHeap[T]{d: d, less: less}
+}
+
+// Len 返回元素的个数
+func (h *DaryHeap[T]) Len() int {
+	return len(h.items)
+}
+
+// Push 加入v, 复杂度为O(log_d(n))
+func (h *DaryHeap[T]) Push(v T) {
+	h.items = append(h.items, v)
+	h.up(len(h.items) - 1)
+}
+
+// Peek 返回最小的元素, 但是不删除它
+func (h *DaryHeap[T]) Peek() (v T, ok bool) {
+	if len(h.items) == 0 {
+		return v, false
+	}
+	return h.items[0], true
+}
+
+// Pop 删除并返回最小的元素, 复杂度为O(d*log_d(n))
+func (h *DaryHeap[T]) Pop() (v T, ok bool) {
+	n := len(h.items) - 1
+	if n < 0 {
+		return v, false
+	}
+	v = h.items[0]
+	h.items[0] = h.items[n]
+	var zero T
+	h.items[n] = zero // 不要让底层数组继续引用它
+	h.items = h.items[:n]
+	if n > 0 {
+		h.down(0)
+	}
+	return v, true
+}
+
+// up 和heap.up一样, 只是父节点是(j-1)/d
+// 不用Swap, 把要移动的元素拿在手里, 最后放一次
+func (h *DaryHeap[T]) up(j int) {
+	x := h.items[j]
+	for j > 0 {
+		i := (j - 1) / h.d // parent
+		if !h.less(x, h.items[i]) {
+			break
+		}
+		h.items[j] = h.items[i]
+		j = i
+	}
+	h.items[j] = x
+}
+
+// down 和heap.down一样, 只是要在d个子节点里找最小的
+func (h *DaryHeap[T]) down(i int) {
+	n := len(h.items)
+	x := h.items[i]
+	for {
+		first := h.d*i + 1
+		if first >= n || first < 0 { // first < 0 after int overflow
+			break
+		}
+		j := first // 最小的子节点
+		last := first + h.d
+		if last > n {
+			last = n
+		}
+		for k := first + 1; k < last; k++ {
+			if h.less(h.items[k], h.items[j]) {
+				j = k
+			}
+		}
+		if !h.less(h.items[j], x) {
+			break
+		}
+		h.items[i] = h.items[j]
+		i = j
+	}
+	h.items[i] = x
+}
@@ -0,0 +1,132 @@
+package heap
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// newHeaps 返回要一起测试的所有Heap实现
+func newHeaps() map[string]Heap[int] {
+	m := map[string]Heap[int]{"pairing": NewPairingHeap(intLess)}
+	for _, d := range []int{2, 3, 4, 8} {
+		m["dary"+strconv.Itoa(d)] = NewDaryHeap(d, intLess)
+	}
+	return m
+}
+
+// testHeapOps 随机地Push与Pop, 和排好序的切片比较
+func testHeapOps(t *testing.T, name string, h Heap[int]) {
+	r := rand.New(rand.NewSource(1))
+	var model []int
+	for i := 0; i < 5000; i++ {
+		if r.Intn(3) > 0 {
+			v := r.Intn(1000)
+			h.Push(v)
+			model = append(model, v)
+			sort.Ints(model)
+		} else {
+			v, ok := h.Pop()
+			if len(model) == 0 {
+				if ok {
+					t.Fatalf("%s: Pop on empty heap returned %d", name, v)
+				}
+				continue
+			}
+			if !ok || v != model[0] {
+				t.Fatalf("%s: Pop = %d, %v; want %d", name, v, ok, model[0])
+			}
+			model = model[1:]
+		}
+		if h.Len() != len(model) {
+			t.Fatalf("%s: Len = %d, want %d", name, h.Len(), len(model))
+		}
+		if v, ok := h.Peek(); ok != (len(model) > 0) || ok && v != model[0] {
+			t.Fatalf("%s: Peek = %d, %v", name, v, ok)
+		}
+	}
+}
+
+func TestHeapVariants(t *testing.T) {
+	for name, h := range newHeaps() {
+		testHeapOps(t, name, h)
+	}
+}
+
+func TestDaryHeapPanicsOnSmallArity(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewDaryHeap(1) did not panic")
+		}
+	}()
+	NewDaryHeap(1, intLess)
+}
+
+const benchHeapSize = 100000
+
+// 先放入benchHeapSize个元素, 然后每次Pop一个再Push一个
+func benchmarkPushPop(b *testing.B, h Heap[int]) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < benchHeapSize; i++ {
+		h.Push(r.Int())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Pop()
+		h.Push(r.Int())
+	}
+}
+
+// 只Push, 插入多的场景
+func benchmarkPush(b *testing.B, h Heap[int]) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		h.Push(r.Int())
+	}
+}
+
+func BenchmarkBinaryHeapPushPop(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	h := &intHeap{}
+	for i := 0; i < benchHeapSize; i++ {
+		Push(h, r.Int())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Pop(h)
+		Push(h, r.Int())
+	}
+}
+
+func BenchmarkDaryHeapPushPop(b *testing.B) {
+	for _, d := range []int{2, 4, 8} {
+		b.Run("d="+strconv.Itoa(d), func(b *testing.B) {
+			benchmarkPushPop(b, NewDaryHeap(d, intLess))
+		})
+	}
+}
+
+func BenchmarkPairingHeapPushPop(b *testing.B) {
+	benchmarkPushPop(b, NewPairingHeap(intLess))
+}
+
+func BenchmarkBinaryHeapPush(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	h := &intHeap{}
+	for i := 0; i < b.N; i++ {
+		Push(h, r.Int())
+	}
+}
+
+func BenchmarkDaryHeapPush(b *testing.B) {
+	for _, d := range []int{2, 4, 8} {
+		b.Run("d="+strconv.Itoa(d), func(b *testing.B) {
+			benchmarkPush(b, NewDaryHeap(d, intLess))
+		})
+	}
+}
+
+func BenchmarkPairingHeapPush(b *testing.B) {
+	benchmarkPush(b, NewPairingHeap(intLess))
+}
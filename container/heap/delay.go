@@ -0,0 +1,368 @@
+package heap
+
+import (
+	"sync"
+	"time"
+)
+
+// 分层时间轮
+//
+// 时间被切成固定长度的tick, 第0层有wheelSize个槽, 每个槽是一个tick;
+// 第l层的每个槽覆盖wheelSize^l个tick. 元素按到期时间离现在的距离放进对应的层,
+// 每走一个tick只处理第0层的一个槽, 低位走完一圈时把上一层的当前槽拆下来重新放,
+// 这样插入与取消都是O(1)的链表操作
+//
+// 超出所有层范围的元素放在一个普通的堆里, 等离现在足够近了再移到轮子上
+
+const (
+	wheelBits   = 6
+	wheelSize   = 1 << wheelBits
+	wheelMask   = wheelSize - 1
+	wheelLevels = 4 // 4层一共覆盖2^24个tick, tick为1ms时大约4.6小时
+)
+
+// Clock 是DelayQueue使用的时钟, 测试时可以换成手动拨动的时钟
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Timer 是DelayQueue.Add返回的句柄, 用来在到期前取消
+type Timer[T any] struct {
+	Value T
+
+	expire     int64 // 到期的tick
+	q          *DelayQueue[T]
+	next, prev *Timer[T]
+	list       *timerList[T]    // 所在的槽, 或者q.ready
+	item       *Item[*Timer[T]] // 在q.overflow里时的句柄
+}
+
+// timerList 是带哨兵的双向循环链表, 和container/list一样
+type timerList[T any] struct {
+	root Timer[T]
+}
+
+func (l *timerList[T]) pushBack(t *Timer[T]) {
+	if l.root.next == nil {
+		l.root.next = &l.root
+		l.root.prev = &l.root
+	}
+	t.prev = l.root.prev
+	t.next = &l.root
+	t.prev.next = t
+	l.root.prev = t
+	t.list = l
+}
+
+func (l *timerList[T]) remove(t *Timer[T]) {
+	t.prev.next = t.next
+	t.next.prev = t.prev
+	t.next, t.prev, t.list = nil, nil, nil
+}
+
+func (l *timerList[T]) front() *Timer[T] {
+	if l.root.next == nil || l.root.next == &l.root {
+		return nil
+	}
+	return l.root.next
+}
+
+// DelayQueue 是延迟队列: 用Add放进去的元素到期以后从C()返回的channel里送出来
+// 精度是一个tick, 元素不会早于到期时间送出, 最多晚一个tick(消费者跟不上时会更晚)
+// 同一个tick到期的元素按放入的顺序送出
+//
+// DelayQueue用一个goroutine推进时间轮, 不再使用时要调用Stop
+type DelayQueue[T any] struct {
+	mu       sync.Mutex
+	clock    Clock
+	tick     time.Duration
+	start    time.Time
+	current  int64 // 已经处理到的tick
+	levels   int
+	slots    [wheelLevels][wheelSize]timerList[T]
+	wheelN   int                       // 轮子上的元素个数
+	ready    timerList[T]              // 已经到期, 等待送出的元素
+	overflow *PriorityQueue[*Timer[T]] // 超出轮子范围的元素
+	n        int
+
+	c        chan T
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDelayQueue 返回一个以tick为精度的DelayQueue, 并启动推进时间轮的goroutine
+// clock为nil时使用系统时钟
+func NewDelayQueue[T any](tick time.Duration, clock Clock) *DelayQueue[T] {
+	q := newDelayQueue[T](tick, clock)
+	go q.run()
+	return q
+}
+
+func newDelayQueue[T any](tick time.Duration, clock Clock) *DelayQueue[T] {
+	if tick <= 0 {
+		panic("heap: non-positive tick for NewDelayQueue")
+	}
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &DelayQueue[T]{
+		clock:    clock,
+		tick:     tick,
+		start:    clock.Now(),
+		levels:   wheelLevels,
+		overflow: NewPriorityQueue(func(a, b *Timer[T]) bool { return a.expire < b.expire }),
+		c:        make(chan T),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// C 返回送出到期元素的channel
+func (q *DelayQueue[T]) C() <-chan T {
+	return q.c
+}
+
+// Len 返回还没有送出的元素个数
+func (q *DelayQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// Add 放入v, d以后到期
+func (q *DelayQueue[T]) Add(v T, d time.Duration) *Timer[T] {
+	return q.AddAt(v, q.clock.Now().Add(d))
+}
+
+// AddAt 放入v, 在at到期, at已经过去时尽快送出
+// 离现在不超过轮子范围时复杂度为O(1), 否则为O(log(n))
+func (q *DelayQueue[T]) AddAt(v T, at time.Time) *Timer[T] {
+	t := &Timer[T]{Value: v}
+	expire := q.expireOf(at)
+	q.mu.Lock()
+	wake := q.scheduleLocked(t, expire)
+	q.mu.Unlock()
+	if wake {
+		q.wakeup()
+	}
+	return t
+}
+
+// Reset 把t改为d以后到期, t已经送出或者取消时重新放入队列
+// 和Cancel以后再Add一样, 但是不用分配新的Timer, 适合频繁续期的超时
+// 返回t原来是否还在队列里; t是别的队列的元素时什么也不做, 返回false
+func (q *DelayQueue[T]) Reset(t *Timer[T], d time.Duration) bool {
+	expire := q.expireOf(q.clock.Now().Add(d))
+	q.mu.Lock()
+	if t.q != nil && t.q != q {
+		q.mu.Unlock()
+		return false
+	}
+	active := t.q == q
+	if active {
+		q.removeLocked(t)
+	}
+	wake := q.scheduleLocked(t, expire)
+	q.mu.Unlock()
+	if wake {
+		q.wakeup()
+	}
+	return active
+}
+
+// expireOf 返回at所在的tick, 向上取整, 保证不会提前送出
+func (q *DelayQueue[T]) expireOf(at time.Time) int64 {
+	d := at.Sub(q.start)
+	expire := int64(d / q.tick)
+	if d%q.tick > 0 {
+		expire++
+	}
+	return expire
+}
+
+// scheduleLocked 放入t, 返回是否需要唤醒推进时间轮的goroutine
+func (q *DelayQueue[T]) scheduleLocked(t *Timer[T], expire int64) bool {
+	idle := q.n == 0
+	if idle {
+		// 没有元素的时候goroutine不推进current, 这里直接跟上
+		if now := q.tickOf(q.clock.Now()); now > q.current {
+			q.current = now
+		}
+	}
+	t.expire = expire
+	t.q = q
+	q.insertLocked(t)
+	q.n++
+	return idle || t.list == &q.ready
+}
+
+func (q *DelayQueue[T]) wakeup() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel 取消t, 复杂度为O(1), t在overflow堆里时为O(log(n))
+// t已经送出, 已经取消或者不是这个队列的元素时返回false
+func (q *DelayQueue[T]) Cancel(t *Timer[T]) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if t.q != q {
+		return false
+	}
+	q.removeLocked(t)
+	return true
+}
+
+func (q *DelayQueue[T]) removeLocked(t *Timer[T]) {
+	if t.list != nil {
+		q.unlinkLocked(t)
+	} else {
+		q.overflow.Remove(t.item)
+		t.item = nil
+	}
+	t.q = nil
+	q.n--
+}
+
+// Stop 停止推进时间轮, 之后不会再送出元素, 没有送出的元素被丢弃
+// 重复调用没有影响
+func (q *DelayQueue[T]) Stop() {
+	q.stopOnce.Do(func() { close(q.stop) })
+	<-q.done
+}
+
+func (q *DelayQueue[T]) tickOf(t time.Time) int64 {
+	return int64(t.Sub(q.start) / q.tick)
+}
+
+// span 返回轮子能覆盖的tick数
+func (q *DelayQueue[T]) span() int64 {
+	return 1 << (wheelBits * q.levels)
+}
+
+// insertLocked 按t.expire离q.current的距离放到ready, 某一层的槽, 或者overflow里
+func (q *DelayQueue[T]) insertLocked(t *Timer[T]) {
+	delta := t.expire - q.current
+	switch {
+	case delta <= 0:
+		q.ready.pushBack(t)
+	case delta >= q.span():
+		t.item = q.overflow.Push(t)
+	default:
+		l := 0
+		for delta >= 1<<(wheelBits*(l+1)) {
+			l++
+		}
+		q.slots[l][(t.expire>>(wheelBits*l))&wheelMask].pushBack(t)
+		q.wheelN++
+	}
+}
+
+func (q *DelayQueue[T]) unlinkLocked(t *Timer[T]) {
+	if t.list != &q.ready {
+		q.wheelN--
+	}
+	t.list.remove(t)
+}
+
+// pullOverflowLocked 把overflow里离现在足够近的元素移到轮子上
+func (q *DelayQueue[T]) pullOverflowLocked() {
+	for {
+		t, ok := q.overflow.Peek()
+		if !ok || t.expire-q.current >= q.span() {
+			return
+		}
+		q.overflow.Pop()
+		t.item = nil
+		q.insertLocked(t)
+	}
+}
+
+// advanceLocked 把时间轮推进到now, 到期的元素追加到due后返回
+func (q *DelayQueue[T]) advanceLocked(now int64, due []T) []T {
+	for {
+		for t := q.ready.front(); t != nil; t = q.ready.front() {
+			q.ready.remove(t)
+			t.q = nil
+			q.n--
+			due = append(due, t.Value)
+		}
+		if q.current >= now {
+			return due
+		}
+		if q.wheelN == 0 {
+			// 轮子是空的, 不用一格一格地走
+			q.current = now
+			q.pullOverflowLocked()
+			continue
+		}
+		q.current++
+		// 低位走完一圈, 把上一层的当前槽拆下来重新放
+		for l := 1; l < q.levels; l++ {
+			if q.current&(1<<(wheelBits*l)-1) != 0 {
+				break
+			}
+			q.cascadeLocked(&q.slots[l][(q.current>>(wheelBits*l))&wheelMask])
+		}
+		// 第0层的当前槽里都是这个tick到期的
+		slot := &q.slots[0][q.current&wheelMask]
+		for t := slot.front(); t != nil; t = slot.front() {
+			q.unlinkLocked(t)
+			q.ready.pushBack(t)
+		}
+		q.pullOverflowLocked()
+	}
+}
+
+func (q *DelayQueue[T]) cascadeLocked(slot *timerList[T]) {
+	for t := slot.front(); t != nil; t = slot.front() {
+		q.unlinkLocked(t)
+		q.insertLocked(t)
+	}
+}
+
+func (q *DelayQueue[T]) run() {
+	defer close(q.done)
+	var due []T
+	var zero T
+	for {
+		now := q.clock.Now()
+		nowTick := q.tickOf(now)
+		q.mu.Lock()
+		due = q.advanceLocked(nowTick, due[:0])
+		idle := q.n == 0
+		q.mu.Unlock()
+
+		for i, v := range due {
+			select {
+			case q.c <- v:
+			case <-q.stop:
+				return
+			}
+			due[i] = zero // 不要让due继续引用送出去的元素
+		}
+
+		// 有元素时在下一个tick开始的时候醒来, 没有元素时等Add唤醒
+		var next <-chan time.Time
+		if !idle {
+			next = q.clock.After(q.start.Add(time.Duration(nowTick+1) * q.tick).Sub(now))
+		}
+		select {
+		case <-next:
+		case <-q.wake:
+		case <-q.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package heap
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 是手动拨动的时钟
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{c.now.Add(d), ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	w := c.waiters[:0]
+	for _, x := range c.waiters {
+		if x.at.After(c.now) {
+			w = append(w, x)
+		} else {
+			x.c <- c.now
+		}
+	}
+	c.waiters = w
+}
+
+// TestDelayQueueWheel 不启动goroutine, 直接推进时间轮
+// 每个元素必须在跨过它的到期tick的那一次推进里送出, 被取消的不能送出
+func TestDelayQueueWheel(t *testing.T) {
+	for _, levels := range []int{1, 2, 3} {
+		clk := newFakeClock()
+		q := newDelayQueue[int](time.Millisecond, clk)
+		q.levels = levels // 层数少的时候overflow用得更多
+		span := q.span()
+		r := rand.New(rand.NewSource(int64(levels)))
+
+		expire := map[int]int64{}
+		timers := map[int]*Timer[int]{}
+		id := 0
+		add := func(now int64, max int64) {
+			at := now + r.Int63n(max)
+			timers[id] = q.AddAt(id, q.start.Add(time.Duration(at)*time.Millisecond))
+			expire[id] = at
+			id++
+		}
+
+		var now int64
+		var due []int
+		for step := 0; step < 300; step++ {
+			for i := 0; i < 20; i++ {
+				add(now, 3*span)
+			}
+			add(now, 1) // 已经到期
+			for k := range timers {
+				if r.Intn(10) == 0 {
+					if !q.Cancel(timers[k]) {
+						t.Fatalf("Cancel(%d) failed", k)
+					}
+					delete(timers, k)
+					delete(expire, k)
+				}
+			}
+
+			prev := now
+			now += r.Int63n(span / 8)
+			clk.Advance(time.Duration(now-prev) * time.Millisecond)
+			q.mu.Lock()
+			due = q.advanceLocked(now, due[:0])
+			q.mu.Unlock()
+			for _, k := range due {
+				e, ok := expire[k]
+				if !ok {
+					t.Fatalf("levels=%d: item %d delivered twice or after Cancel", levels, k)
+				}
+				if e < prev || e > now {
+					t.Fatalf("levels=%d: item %d expiring at %d delivered in [%d, %d]", levels, k, e, prev, now)
+				}
+				if q.Cancel(timers[k]) {
+					t.Fatalf("Cancel succeeded on delivered item %d", k)
+				}
+				delete(expire, k)
+				delete(timers, k)
+			}
+			for k, e := range expire {
+				if e <= now {
+					t.Fatalf("levels=%d: item %d expiring at %d not delivered by %d", levels, k, e, now)
+				}
+			}
+			if q.Len() != len(expire) {
+				t.Fatalf("Len = %d, want %d", q.Len(), len(expire))
+			}
+		}
+	}
+}
+
+func TestDelayQueueCancelForeign(t *testing.T) {
+	a := newDelayQueue[int](time.Millisecond, newFakeClock())
+	b := newDelayQueue[int](time.Millisecond, newFakeClock())
+	ta := a.Add(1, time.Second)
+	tb := b.Add(2, time.Hour*24) // 在overflow里
+	if b.Cancel(ta) || a.Cancel(tb) {
+		t.Error("Cancel of another queue's timer succeeded")
+	}
+	if !a.Cancel(ta) || !b.Cancel(tb) || a.Cancel(ta) {
+		t.Error("Cancel should succeed exactly once")
+	}
+	if a.Len() != 0 || b.Len() != 0 {
+		t.Errorf("Len = %d, %d; want 0, 0", a.Len(), b.Len())
+	}
+}
+
+func TestDelayQueueReset(t *testing.T) {
+	clk := newFakeClock()
+	q := newDelayQueue[string](time.Millisecond, clk)
+	a := q.Add("a", 10*time.Millisecond)
+	b := q.Add("b", 20*time.Millisecond)
+	if !q.Reset(a, 30*time.Millisecond) {
+		t.Error("Reset of pending timer returned false")
+	}
+	advance := func(d time.Duration) []string {
+		clk.Advance(d)
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.advanceLocked(q.tickOf(clk.Now()), nil)
+	}
+	if due := advance(20 * time.Millisecond); len(due) != 1 || due[0] != "b" {
+		t.Fatalf("due = %q, want [b]", due)
+	}
+	// 已经送出的可以重新放入
+	if q.Reset(b, 5*time.Millisecond) {
+		t.Error("Reset of delivered timer returned true")
+	}
+	if due := advance(5 * time.Millisecond); len(due) != 1 || due[0] != "b" {
+		t.Fatalf("due = %q, want [b]", due)
+	}
+	other := newDelayQueue[string](time.Millisecond, clk)
+	if other.Reset(a, time.Millisecond) {
+		t.Error("Reset of another queue's timer succeeded")
+	}
+	if due := advance(5 * time.Millisecond); len(due) != 1 || due[0] != "a" {
+		t.Fatalf("due = %q, want [a]", due)
+	}
+	if q.Len() != 0 || other.Len() != 0 {
+		t.Errorf("Len = %d, %d; want 0, 0", q.Len(), other.Len())
+	}
+}
+
+func TestDelayQueueDeliver(t *testing.T) {
+	clk := newFakeClock()
+	q := NewDelayQueue[time.Duration](10*time.Millisecond, clk)
+	defer q.Stop()
+
+	delays := []time.Duration{35 * time.Millisecond, 0, 5 * time.Millisecond, 2 * time.Second, 35 * time.Millisecond}
+	start := clk.Now()
+	for _, d := range delays {
+		q.Add(d, d)
+	}
+	q.Cancel(q.Add(time.Millisecond, time.Millisecond))
+
+	var got []time.Duration
+	for len(got) < len(delays) {
+		select {
+		case d := <-q.C():
+			if elapsed := clk.Now().Sub(start); elapsed < d {
+				t.Fatalf("item with delay %v delivered after %v", d, elapsed)
+			}
+			got = append(got, d)
+		case <-time.After(time.Millisecond):
+			clk.Advance(5 * time.Millisecond)
+		}
+	}
+	want := []time.Duration{0, 5 * time.Millisecond, 35 * time.Millisecond, 35 * time.Millisecond, 2 * time.Second}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("delivered %v, want %v", got, want)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len = %d, want 0", q.Len())
+	}
+}
+
+func TestDelayQueueRealClock(t *testing.T) {
+	q := NewDelayQueue[int](time.Millisecond, nil)
+	start := time.Now()
+	q.Add(30, 30*time.Millisecond)
+	q.Add(10, 10*time.Millisecond)
+	q.Add(20, 20*time.Millisecond)
+	for _, want := range []int{10, 20, 30} {
+		v := <-q.C()
+		if v != want {
+			t.Fatalf("got %d, want %d", v, want)
+		}
+		if elapsed := time.Since(start); elapsed < time.Duration(v)*time.Millisecond {
+			t.Fatalf("item %d delivered early after %v", v, elapsed)
+		}
+	}
+	q.Add(0, time.Hour)
+	q.Stop()
+	q.Stop()
+	if q.Len() != 1 {
+		t.Errorf("Len after Stop = %d, want 1", q.Len())
+	}
+}
+
+// timerHeap 是用heap.Interface实现的定时器堆, 用来对比
+type heapTimer struct {
+	expire int64
+	index  int
+}
+
+type timerHeap []*heapTimer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].expire < h[j].expire }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*heapTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// lockedTimerHeap 和DelayQueue一样加锁并且从时钟算到期时间, 这样比较才公平
+type lockedTimerHeap struct {
+	mu sync.Mutex
+	h  timerHeap
+}
+
+func (h *lockedTimerHeap) add(d time.Duration) *heapTimer {
+	t := &heapTimer{expire: time.Now().Add(d).UnixNano()}
+	h.mu.Lock()
+	Push(&h.h, t)
+	h.mu.Unlock()
+	return t
+}
+
+func (h *lockedTimerHeap) cancel(t *heapTimer) {
+	h.mu.Lock()
+	Remove(&h.h, t.index)
+	h.mu.Unlock()
+}
+
+func (h *lockedTimerHeap) reschedule(t *heapTimer, d time.Duration) {
+	expire := time.Now().Add(d).UnixNano()
+	h.mu.Lock()
+	t.expire = expire
+	Fix(&h.h, t.index)
+	h.mu.Unlock()
+}
+
+var benchSizes = []struct {
+	name string
+	n    int
+}{{"1e3", 1e3}, {"1e5", 1e5}, {"1e6", 1e6}}
+
+// 到期时间在1到2分钟之间, 跑benchmark的时候都不会到期
+func benchDelay(r *rand.Rand) time.Duration {
+	return time.Minute + time.Duration(r.Int63n(int64(time.Minute)))
+}
+
+func BenchmarkDelayQueueAddCancel(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			r := rand.New(rand.NewSource(1))
+			q := NewDelayQueue[int](time.Millisecond, nil)
+			defer q.Stop()
+			for i := 0; i < size.n; i++ {
+				q.Add(i, benchDelay(r))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				q.Cancel(q.Add(i, benchDelay(r)))
+			}
+		})
+	}
+}
+
+func BenchmarkHeapAddCancel(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			r := rand.New(rand.NewSource(1))
+			h := &lockedTimerHeap{}
+			for i := 0; i < size.n; i++ {
+				h.add(benchDelay(r))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.cancel(h.add(benchDelay(r)))
+			}
+		})
+	}
+}
+
+// 续期: 时间轮用Reset, 堆修改到期时间后Fix
+func BenchmarkDelayQueueReschedule(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			r := rand.New(rand.NewSource(1))
+			q := NewDelayQueue[int](time.Millisecond, nil)
+			defer q.Stop()
+			timers := make([]*Timer[int], size.n)
+			for i := range timers {
+				timers[i] = q.Add(i, benchDelay(r))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				k := r.Intn(size.n)
+				q.Reset(timers[k], benchDelay(r))
+			}
+		})
+	}
+}
+
+func BenchmarkHeapReschedule(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			r := rand.New(rand.NewSource(1))
+			h := &lockedTimerHeap{}
+			timers := make([]*heapTimer, size.n)
+			for i := range timers {
+				timers[i] = h.add(benchDelay(r))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.reschedule(timers[r.Intn(size.n)], benchDelay(r))
+			}
+		})
+	}
+}
@@ -78,7 +78,14 @@ func Fix(h Interface, i int) {
 	up(h, i)
 }
 
-func up(h Interface, j int) {
+// lessSwapper 是up与down真正需要的方法
+// heap.Interface满足它, PriorityQueue等泛型的堆也用同一套算法
+type lessSwapper interface {
+	Less(i, j int) bool
+	Swap(i, j int)
+}
+
+func up(h lessSwapper, j int) {
 	for {
 		i := (j - 1) / 2
 		if i == j || !h.Less(j, i) {
@@ -89,7 +96,7 @@ func up(h Interface, j int) {
 	}
 }
 
-func down(h Interface, i, n int) {
+func down(h lessSwapper, i, n int) {
 	for {
 		j1 := 2*i + 1
 		if j1 >= n || j1 < 0 {
@@ -0,0 +1,116 @@
+package heap
+
+// IndexedHeap 是按key索引的最小堆, 每个key最多出现一次
+// 可以按key修改优先级或者删除, 适合Dijkstra的decrease-key, 或者按ID调度的场景
+// IndexedHeap不是并发安全的
+type IndexedHeap[K comparable, V any] struct {
+	data indexedData[K, V]
+}
+
+// indexedData 实现lessSwapper, key到下标的映射在Swap里维护
+// 这样up与down移动元素时, 映射自然就是对的
+type indexedData[K comparable, V any] struct {
+	keys  []K
+	vals  []V
+	index map[K]int
+	less  func(a, b V) bool
+}
+
+func (d *indexedData[K, V]) Less(i, j int) bool { return d.less(d.vals[i], d.vals[j]) }
+func (d *indexedData[K, V]) Swap(i, j int) {
+	d.keys[i], d.keys[j] = d.keys[j], d.keys[i]
+	d.vals[i], d.vals[j] = d.vals[j], d.vals[i]
+	d.index[d.keys[i]] = i
+	d.index[d.keys[j]] = j
+}
+
+// NewIndexedHeap 返回一个空的IndexedHeap, less(a, b)为true时a的优先级更高
+func NewIndexedHeap[K comparable, V any](less func(a, b V) bool) *IndexedHeap[K, V] {
+	return &IndexedHeap[K, V]{data: indexedData[K, V]{
+		index: make(map[K]int),
+		less:  less,
+	}}
+}
+
+// Len 返回元素的个数
+func (h *IndexedHeap[K, V]) Len() int {
+	return len(h.data.keys)
+}
+
+// Contains 判断key是否在堆里
+func (h *IndexedHeap[K, V]) Contains(key K) bool {
+	_, ok := h.data.index[key]
+	return ok
+}
+
+// Get 返回key的优先级
+func (h *IndexedHeap[K, V]) Get(key K) (v V, ok bool) {
+	i, ok := h.data.index[key]
+	if !ok {
+		return v, false
+	}
+	return h.data.vals[i], true
+}
+
+// Update 把key的优先级设为v, key不存在时加入, 复杂度为O(log(n))
+// 返回key原来是否存在
+func (h *IndexedHeap[K, V]) Update(key K, v V) bool {
+	d := &h.data
+	if i, ok := d.index[key]; ok {
+		d.vals[i] = v
+		down(d, i, len(d.keys))
+		up(d, d.index[key])
+		return true
+	}
+	n := len(d.keys)
+	d.keys = append(d.keys, key)
+	d.vals = append(d.vals, v)
+	d.index[key] = n
+	up(d, n)
+	return false
+}
+
+// PeekMin 返回优先级最高的元素, 但是不删除它
+func (h *IndexedHeap[K, V]) PeekMin() (key K, v V, ok bool) {
+	if len(h.data.keys) == 0 {
+		return key, v, false
+	}
+	return h.data.keys[0], h.data.vals[0], true
+}
+
+// PopMin 删除并返回优先级最高的元素, 复杂度为O(log(n))
+func (h *IndexedHeap[K, V]) PopMin() (key K, v V, ok bool) {
+	if len(h.data.keys) == 0 {
+		return key, v, false
+	}
+	key, v = h.removeAt(0)
+	return key, v, true
+}
+
+// Remove 删除key, 返回它的优先级, 复杂度为O(log(n))
+func (h *IndexedHeap[K, V]) Remove(key K) (v V, ok bool) {
+	i, ok := h.data.index[key]
+	if !ok {
+		return v, false
+	}
+	_, v = h.removeAt(i)
+	return v, true
+}
+
+func (h *IndexedHeap[K, V]) removeAt(i int) (K, V) {
+	d := &h.data
+	n := len(d.keys) - 1
+	if n != i {
+		d.Swap(i, n)
+		down(d, i, n)
+		up(d, i)
+	}
+	key, v := d.keys[n], d.vals[n]
+	var zk K
+	var zv V
+	d.keys[n], d.vals[n] = zk, zv // 不要让底层数组继续引用它们
+	d.keys = d.keys[:n]
+	d.vals = d.vals[:n]
+	delete(d.index, key)
+	return key, v
+}
@@ -0,0 +1,126 @@
+package heap
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+// check 检查IndexedHeap的所有不变量:
+// keys, vals与index的长度一致, index[keys[i]] == i, 每个节点不小于它的父节点
+func (h *IndexedHeap[K, V]) check() string {
+	d := &h.data
+	if len(d.keys) != len(d.vals) || len(d.keys) != len(d.index) {
+		return "length mismatch"
+	}
+	for i, k := range d.keys {
+		if j, ok := d.index[k]; !ok || j != i {
+			return "index out of sync"
+		}
+		if i > 0 && d.Less(i, (i-1)/2) {
+			return "heap invariant violated"
+		}
+	}
+	return ""
+}
+
+// TestIndexedHeapProperties 用随机的操作序列和一个map做对比
+// 每个uint16编码一个操作: 低两位是操作类型, 其它位是key与优先级
+func TestIndexedHeapProperties(t *testing.T) {
+	f := func(ops []uint16) bool {
+		h := NewIndexedHeap[int, int](func(a, b int) bool { return a < b })
+		model := map[int]int{}
+		for _, op := range ops {
+			key := int(op>>2) % 32
+			prio := int(op>>7) % 64
+			switch op & 3 {
+			case 0, 1:
+				_, existed := model[key]
+				if h.Update(key, prio) != existed {
+					t.Logf("Update(%d) existed mismatch", key)
+					return false
+				}
+				model[key] = prio
+			case 2:
+				v, ok := h.Remove(key)
+				mv, mok := model[key]
+				if ok != mok || v != mv {
+					t.Logf("Remove(%d) = %d, %v; model %d, %v", key, v, ok, mv, mok)
+					return false
+				}
+				delete(model, key)
+			case 3:
+				k, v, ok := h.PopMin()
+				if !ok {
+					if len(model) != 0 {
+						return false
+					}
+					break
+				}
+				for _, mv := range model {
+					if mv < v {
+						t.Logf("PopMin = %d, but model has %d", v, mv)
+						return false
+					}
+				}
+				if model[k] != v {
+					return false
+				}
+				delete(model, k)
+			}
+			if msg := h.check(); msg != "" {
+				t.Log(msg)
+				return false
+			}
+			if h.Len() != len(model) {
+				return false
+			}
+			for k, mv := range model {
+				if v, ok := h.Get(k); !ok || v != mv || !h.Contains(k) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIndexedHeapDijkstra(t *testing.T) {
+	// 有向图的边: from -> to: weight
+	graph := map[string]map[string]int{
+		"a": {"b": 7, "c": 9, "f": 14},
+		"b": {"c": 10, "d": 15},
+		"c": {"d": 11, "f": 2},
+		"d": {"e": 6},
+		"f": {"e": 9},
+	}
+	dist := map[string]int{}
+	h := NewIndexedHeap[string, int](func(a, b int) bool { return a < b })
+	h.Update("a", 0)
+	for h.Len() > 0 {
+		node, d, _ := h.PopMin()
+		dist[node] = d
+		for next, w := range graph[node] {
+			if _, done := dist[next]; done {
+				continue
+			}
+			if cur, ok := h.Get(next); !ok || d+w < cur {
+				h.Update(next, d+w)
+			}
+		}
+	}
+	want := map[string]int{"a": 0, "b": 7, "c": 9, "d": 20, "e": 20, "f": 11}
+	for k, v := range want {
+		if dist[k] != v {
+			t.Errorf("dist[%s] = %d, want %d", k, dist[k], v)
+		}
+	}
+	if _, _, ok := h.PopMin(); ok {
+		t.Error("PopMin on empty heap succeeded")
+	}
+	if _, ok := h.Remove("zzz"); ok {
+		t.Error("Remove of missing key succeeded")
+	}
+}
@@ -0,0 +1,114 @@
+package heap
+
+// PairingHeap 是配对堆: 一棵多叉树, 根是最小的元素
+// Push与Meld都只是把两棵树的根连起来, 复杂度为O(1); Pop把根的子树两两合并,
+// 均摊复杂度为O(log(n)). 适合插入多, 或者需要合并两个堆的场景
+// PairingHeap不是并发安全的
+type PairingHeap[T any] struct {
+	root *pairingNode[T]
+	n    int
+	less func(a, b T) bool
+}
+
+// pairingNode 用第一个子节点加兄弟链表表示多叉树
+type pairingNode[T any] struct {
+	value   T
+	child   *pairingNode[T]
+	sibling *pairingNode[T]
+}
+
+// NewPairingHeap 返回一个空的PairingHeap, less(a, b)为true时a先出堆
+func NewPairingHeap[T any](less func(a, b T) bool) *PairingHeap[T] {
+	return &PairingHeap[T]{less: less}
+}
+
+// Len 返回元素的个数
+func (h *PairingHeap[T]) Len() int {
+	return h.n
+}
+
+// Push 加入v, 复杂度为O(1)
+func (h *PairingHeap[T]) Push(v T) {
+	h.root = h.link(h.root, &pairingNode[T]{value: v})
+	h.n++
+}
+
+// Peek 返回最小的元素, 但是不删除它
+func (h *PairingHeap[T]) Peek() (v T, ok bool) {
+	if h.root == nil {
+		return v, false
+	}
+	return h.root.value, true
+}
+
+// Pop 删除并返回最小的元素, 均摊复杂度为O(log(n))
+func (h *PairingHeap[T]) Pop() (v T, ok bool) {
+	if h.root == nil {
+		return v, false
+	}
+	v = h.root.value
+	h.root = h.mergePairs(h.root.child)
+	h.n--
+	return v, true
+}
+
+// Meld 把other的所有元素移到h里, 复杂度为O(1), 之后other为空
+// 两个堆的less必须是一样的顺序
+func (h *PairingHeap[T]) Meld(other *PairingHeap[T]) {
+	if other == h {
+		return
+	}
+	h.root = h.link(h.root, other.root)
+	h.n += other.n
+	other.root = nil
+	other.n = 0
+}
+
+// link 把根较大的树挂到另一棵树的根下面, 返回新的根
+func (h *PairingHeap[T]) link(a, b *pairingNode[T]) *pairingNode[T] {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if h.less(b.value, a.value) {
+		a, b = b, a
+	}
+	b.sibling = a.child
+	a.child = b
+	return a
+}
+
+// mergePairs 把兄弟链表上的树合并成一棵
+// 先从左到右两两合并, 再从右到左依次合并, 不用递归
+func (h *PairingHeap[T]) mergePairs(first *pairingNode[T]) *pairingNode[T] {
+	if first == nil {
+		return nil
+	}
+	// 第一遍的结果用sibling串起来, 顺序是反的, 正好给第二遍用
+	var pairs *pairingNode[T]
+	for first != nil {
+		a, b := first, first.sibling
+		if b == nil {
+			a.sibling = pairs
+			pairs = a
+			break
+		}
+		first = b.sibling
+		a.sibling, b.sibling = nil, nil
+		m := h.link(a, b)
+		m.sibling = pairs
+		pairs = m
+	}
+	root := pairs
+	pairs = root.sibling
+	root.sibling = nil
+	for pairs != nil {
+		next := pairs.sibling
+		pairs.sibling = nil
+		root = h.link(root, pairs)
+		pairs = next
+	}
+	return root
+}
@@ -0,0 +1,92 @@
+package heap
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestPairingHeapMeld(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var all []int
+	h := NewPairingHeap(intLess)
+	for k := 0; k < 20; k++ {
+		other := NewPairingHeap(intLess)
+		var pending []int
+		for i := r.Intn(50); i > 0; i-- {
+			v := r.Intn(1000)
+			other.Push(v)
+			pending = append(pending, v)
+		}
+		// 合并之间也取出一些, 让树的形状不那么规整
+		if v, ok := h.Pop(); ok {
+			sort.Ints(all)
+			if v != all[0] {
+				t.Fatalf("Pop = %d, want %d", v, all[0])
+			}
+			all = all[1:]
+		}
+		h.Meld(other)
+		all = append(all, pending...)
+		if other.Len() != 0 {
+			t.Fatalf("other.Len = %d after Meld", other.Len())
+		}
+		if _, ok := other.Peek(); ok {
+			t.Fatal("other not empty after Meld")
+		}
+	}
+	h.Meld(h)
+	h.Meld(NewPairingHeap(intLess))
+	if h.Len() != len(all) {
+		t.Fatalf("Len = %d, want %d", h.Len(), len(all))
+	}
+	sort.Ints(all)
+	for _, want := range all {
+		if v, ok := h.Pop(); !ok || v != want {
+			t.Fatalf("Pop = %d, %v; want %d", v, ok, want)
+		}
+	}
+}
+
+// 合并benchMeldHeaps个各有benchMeldSize个元素的堆, 再取出最小的benchMeldSize个
+// 比如把各个worker的本地队列合并起来
+const (
+	benchMeldHeaps = 100
+	benchMeldSize  = 100
+)
+
+func BenchmarkPairingHeapMeld(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		h := NewPairingHeap(intLess)
+		for k := 0; k < benchMeldHeaps; k++ {
+			other := NewPairingHeap(intLess)
+			for j := 0; j < benchMeldSize; j++ {
+				other.Push(r.Int())
+			}
+			h.Meld(other)
+		}
+		for j := 0; j < benchMeldSize; j++ {
+			h.Pop()
+		}
+	}
+}
+
+// 二叉堆只能拼接以后重新Init, 每次合并的复杂度为O(n)
+func BenchmarkBinaryHeapMeld(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		h := &intHeap{}
+		for k := 0; k < benchMeldHeaps; k++ {
+			other := &intHeap{}
+			for j := 0; j < benchMeldSize; j++ {
+				Push(other, r.Int())
+			}
+			*h = append(*h, *other...)
+			Init(h)
+		}
+		for j := 0; j < benchMeldSize; j++ {
+			Pop(h)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package heap
+
+// PriorityQueue 是一个类型安全的最小堆, 不需要实现heap.Interface, 元素也不用装箱成interface{}
+// 最小的元素由构造时传入的less决定, 想要最大堆就把less反过来
+// PriorityQueue不是并发安全的
+type PriorityQueue[T any] struct {
+	data pqData[T]
+}
+
+// Item 是Push返回的句柄, 用来在之后修改优先级或者删除这个元素
+type Item[T any] struct {
+	Value T
+	index int // 在堆里的下标, 不在堆里时为-1
+}
+
+// Index 返回元素当前在堆里的下标, 已经被Pop或者Remove时返回-1
+func (it *Item[T]) Index() int {
+	return it.index
+}
+
+// pqData 实现lessSwapper, 让PriorityQueue可以复用up与down
+// Swap的同时维护每个Item的下标
+type pqData[T any] struct {
+	items []*Item[T]
+	less  func(a, b T) bool
+}
+
+func (d *pqData[T]) Less(i, j int) bool { return d.less(d.items[i].Value, d.items[j].Value) }
+func (d *pqData[T]) Swap(i, j int) {
+	d.items[i], d.items[j] = d.items[j], d.items[i]
+	d.items[i].index = i
+	d.items[j].index = j
+}
+
+// NewPriorityQueue 返回一个空的PriorityQueue, less(a, b)为true时a先出队
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{data: pqData[T]{less: less}}
+}
+
+// Len 返回元素的个数
+func (pq *PriorityQueue[T]) Len() int {
+	return len(pq.data.items)
+}
+
+// Push 加入v并返回它的句柄, 复杂度为O(log(n))
+func (pq *PriorityQueue[T]) Push(v T) *Item[T] {
+	it := &Item[T]{Value: v, index: len(pq.data.items)}
+	pq.data.items = append(pq.data.items, it)
+	up(&pq.data, it.index)
+	return it
+}
+
+// Peek 返回最小的元素, 但是不删除它
+// 队列为空时ok为false
+func (pq *PriorityQueue[T]) Peek() (v T, ok bool) {
+	if len(pq.data.items) == 0 {
+		return v, false
+	}
+	return pq.data.items[0].Value, true
+}
+
+// Pop 删除并返回最小的元素, 复杂度为O(log(n))
+// 队列为空时ok为false
+func (pq *PriorityQueue[T]) Pop() (v T, ok bool) {
+	if len(pq.data.items) == 0 {
+		return v, false
+	}
+	return pq.removeAt(0), true
+}
+
+// Fix 在it.Value被修改以后恢复堆的性质, 复杂度为O(log(n))
+// it已经不在堆里时什么也不做
+func (pq *PriorityQueue[T]) Fix(it *Item[T]) {
+	if !pq.contains(it) {
+		return
+	}
+	down(&pq.data, it.index, len(pq.data.items))
+	up(&pq.data, it.index)
+}
+
+// Update 把it的值改为v, 相当于修改it.Value再调用Fix
+func (pq *PriorityQueue[T]) Update(it *Item[T], v T) {
+	it.Value = v
+	pq.Fix(it)
+}
+
+// Remove 从堆里删除it, 复杂度为O(log(n))
+// it已经不在堆里时返回false
+func (pq *PriorityQueue[T]) Remove(it *Item[T]) bool {
+	if !pq.contains(it) {
+		return false
+	}
+	pq.removeAt(it.index)
+	return true
+}
+
+// contains 判断it是不是这个堆里的元素
+// 只看下标是不够的, 别的堆的句柄也可能有合法的下标
+func (pq *PriorityQueue[T]) contains(it *Item[T]) bool {
+	return it.index >= 0 && it.index < len(pq.data.items) && pq.data.items[it.index] == it
+}
+
+// removeAt 和heap.Remove一样: 和最后一个元素交换, 再调整交换过来的那个
+func (pq *PriorityQueue[T]) removeAt(i int) T {
+	d := &pq.data
+	n := len(d.items) - 1
+	if n != i {
+		d.Swap(i, n)
+		down(d, i, n)
+		up(d, i)
+	}
+	it := d.items[n]
+	d.items[n] = nil // 不要让底层数组继续引用它
+	d.items = d.items[:n]
+	it.index = -1
+	return it.Value
+}
@@ -0,0 +1,207 @@
+package heap
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func intLess(a, b int) bool { return a < b }
+
+// verify 检查堆的性质与每个句柄的下标
+func (pq *PriorityQueue[T]) verify(t *testing.T) {
+	t.Helper()
+	d := &pq.data
+	for i, it := range d.items {
+		if it.index != i {
+			t.Fatalf("item %d has index %d", i, it.index)
+		}
+		for _, j := range []int{2*i + 1, 2*i + 2} {
+			if j < len(d.items) && d.Less(j, i) {
+				t.Fatalf("heap invariant violated: [%d] < [%d]", j, i)
+			}
+		}
+	}
+}
+
+func TestPriorityQueueOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pq := NewPriorityQueue(intLess)
+	var want []int
+	for i := 0; i < 200; i++ {
+		v := r.Intn(100)
+		want = append(want, v)
+		pq.Push(v)
+		pq.verify(t)
+	}
+	sort.Ints(want)
+	if v, ok := pq.Peek(); !ok || v != want[0] {
+		t.Errorf("Peek = %d, %v; want %d", v, ok, want[0])
+	}
+	for i, w := range want {
+		v, ok := pq.Pop()
+		if !ok || v != w {
+			t.Fatalf("Pop #%d = %d, %v; want %d", i, v, ok, w)
+		}
+		pq.verify(t)
+	}
+	if _, ok := pq.Pop(); ok || pq.Len() != 0 {
+		t.Error("Pop on empty queue succeeded")
+	}
+	if _, ok := pq.Peek(); ok {
+		t.Error("Peek on empty queue succeeded")
+	}
+}
+
+type task struct {
+	name     string
+	priority int
+}
+
+func TestPriorityQueueHandles(t *testing.T) {
+	pq := NewPriorityQueue(func(a, b task) bool { return a.priority > b.priority })
+	items := map[string]*Item[task]{}
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		items[name] = pq.Push(task{name, i})
+	}
+	pq.Update(items["a"], task{"a", 10})
+	pq.verify(t)
+	items["e"].Value.priority = -1
+	pq.Fix(items["e"])
+	pq.verify(t)
+	if !pq.Remove(items["c"]) || pq.Remove(items["c"]) {
+		t.Error("Remove should succeed exactly once")
+	}
+	if items["c"].Index() != -1 {
+		t.Errorf("removed item has index %d", items["c"].Index())
+	}
+	pq.verify(t)
+
+	var got []string
+	for pq.Len() > 0 {
+		v, _ := pq.Pop()
+		got = append(got, v.name)
+	}
+	if s := strings.Join(got, ""); s != "adbe" {
+		t.Errorf("order = %s, want adbe", s)
+	}
+
+	// 已经出队的句柄, 以及别的队列的句柄, 都不能影响这个队列
+	other := NewPriorityQueue(func(a, b task) bool { return a.priority > b.priority })
+	other.Push(task{"x", 0})
+	pq.Fix(items["a"])
+	if pq.Remove(items["a"]) {
+		t.Error("Remove of popped item succeeded")
+	}
+	foreign := other.Push(task{"y", 1})
+	pq.Push(task{"z", 0})
+	pq.Push(task{"w", 0})
+	if pq.Remove(foreign) {
+		t.Error("Remove of foreign item succeeded")
+	}
+}
+
+func TestPriorityQueueRandomOps(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	pq := NewPriorityQueue(intLess)
+	var live []*Item[int]
+	for i := 0; i < 2000; i++ {
+		switch op := r.Intn(4); {
+		case op == 0 || len(live) == 0:
+			live = append(live, pq.Push(r.Intn(1000)))
+		case op == 1:
+			k := r.Intn(len(live))
+			pq.Update(live[k], r.Intn(1000))
+		case op == 2:
+			k := r.Intn(len(live))
+			pq.Remove(live[k])
+			live = append(live[:k], live[k+1:]...)
+		default:
+			min, _ := pq.Peek()
+			v, _ := pq.Pop()
+			if v != min {
+				t.Fatalf("Pop = %d, Peek said %d", v, min)
+			}
+			for k, it := range live {
+				if it.Index() == -1 {
+					live = append(live[:k], live[k+1:]...)
+					break
+				}
+			}
+		}
+		pq.verify(t)
+		if pq.Len() != len(live) {
+			t.Fatalf("Len = %d, want %d", pq.Len(), len(live))
+		}
+	}
+}
+
+// intHeap 是用heap.Interface实现的同样的堆, 用来对比
+type intHeap []int
+
+func (h intHeap) Len() int            { return len(h) }
+func (h intHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+const benchSize = 1000
+
+func BenchmarkPriorityQueuePushPop(b *testing.B) {
+	b.ReportAllocs()
+	pq := NewPriorityQueue(intLess)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchSize; j++ {
+			pq.Push((j * 7919) % benchSize)
+		}
+		for pq.Len() > 0 {
+			pq.Pop()
+		}
+	}
+}
+
+func BenchmarkInterfaceHeapPushPop(b *testing.B) {
+	b.ReportAllocs()
+	h := &intHeap{}
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchSize; j++ {
+			Push(h, (j*7919)%benchSize+benchSize) // 大于255的int装箱时一定会分配
+		}
+		for h.Len() > 0 {
+			Pop(h)
+		}
+	}
+}
+
+func BenchmarkPriorityQueueUpdate(b *testing.B) {
+	pq := NewPriorityQueue(intLess)
+	items := make([]*Item[int], benchSize)
+	for j := range items {
+		items[j] = pq.Push(j)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := items[i%benchSize]
+		pq.Update(it, (it.Value*7919)%benchSize)
+	}
+}
+
+func BenchmarkInterfaceHeapFix(b *testing.B) {
+	h := &intHeap{}
+	for j := 0; j < benchSize; j++ {
+		Push(h, j)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := i % benchSize
+		(*h)[k] = ((*h)[k] * 7919) % benchSize
+		Fix(h, k)
+	}
+}
@@ -0,0 +1,279 @@
+// Package cache 提供建立在container/list上的LRU与LFU缓存
+// 容量可以按元素个数或者总cost限制, 支持每个元素的TTL, 淘汰回调与命中统计
+//
+// LRU与LFU都不是并发安全的, 需要并发访问时用NewShardedLRU或者NewShardedLFU,
+// 它们把key按哈希分到多个各自加锁的分片里
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// Reason 是元素离开缓存的原因, 传给OnEvict
+type Reason int
+
+const (
+	Evicted Reason = iota // 容量不够被淘汰
+	Expired               // TTL到期
+	Removed               // 调用Remove或者Purge删除
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Evicted:
+		return "evicted"
+	case Expired:
+		return "expired"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Options 是LRU与LFU的配置, 零值表示不限容量, 不会过期
+type Options[K comparable, V any] struct {
+	// MaxEntries 是最多的元素个数, 0表示不限制
+	MaxEntries int
+	// MaxCost 是所有元素cost之和的上限, 0表示不限制
+	MaxCost int64
+	// Cost 返回一个元素的cost, 为nil时每个元素的cost都是1
+	Cost func(key K, value V) int64
+	// TTL 是Set放入的元素的存活时间, 0表示不会过期; SetWithTTL可以单独指定
+	TTL time.Duration
+	// OnEvict 在元素离开缓存时调用, 替换已有key的值时不调用
+	// 在分片缓存里调用时持有分片的锁, 不能再访问同一个缓存
+	OnEvict func(key K, value V, reason Reason)
+	// Now 返回当前时间, 为nil时使用time.Now, 测试时可以替换
+	Now func() time.Time
+}
+
+// Stats 是命中统计
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64 // 因为容量不够被淘汰的元素个数
+	Expirations uint64 // 因为TTL到期被删除的元素个数
+}
+
+// HitRate 返回命中率, 没有访问过时为0
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s *Stats) add(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Evictions += o.Evictions
+	s.Expirations += o.Expirations
+}
+
+// Cache 是LRU, LFU与Sharded共同的方法
+type Cache[K comparable, V any] interface {
+	Get(key K) (value V, ok bool)
+	Peek(key K) (value V, ok bool)
+	Set(key K, value V) bool
+	SetWithTTL(key K, value V, ttl time.Duration) bool
+	Remove(key K) bool
+	RemoveExpired() int
+	Len() int
+	Purge()
+	Stats() Stats
+}
+
+var (
+	_ Cache[int, int] = (*LRU[int, int])(nil)
+	_ Cache[int, int] = (*LFU[int, int])(nil)
+	_ Cache[int, int] = (*Sharded[int, int])(nil)
+)
+
+type entry[K comparable, V any] struct {
+	key    K
+	value  V
+	cost   int64
+	expire time.Time // 零值表示不会过期
+
+	elem  *list.Element // 在淘汰顺序链表里的位置, Value是*entry
+	freq  *list.Element // LFU: 所在的频率桶
+	count int           // LFU: 访问次数
+}
+
+// policy 决定淘汰的顺序
+type policy[K comparable, V any] interface {
+	add(e *entry[K, V])
+	touch(e *entry[K, V])
+	remove(e *entry[K, V])
+	// victim 返回下一个要淘汰的元素, 跳过skip, 没有时返回nil
+	victim(skip *entry[K, V]) *entry[K, V]
+}
+
+// cache 是LRU与LFU共用的实现, 只有淘汰顺序不一样
+type cache[K comparable, V any] struct {
+	opts   Options[K, V]
+	items  map[K]*entry[K, V]
+	policy policy[K, V]
+	cost   int64
+	stats  Stats
+}
+
+func (c *cache[K, V]) init(opts Options[K, V], p policy[K, V]) {
+	c.opts = opts
+	c.items = make(map[K]*entry[K, V])
+	c.policy = p
+}
+
+func (c *cache[K, V]) now() time.Time {
+	if c.opts.Now != nil {
+		return c.opts.Now()
+	}
+	return time.Now()
+}
+
+func (c *cache[K, V]) expired(e *entry[K, V], now time.Time) bool {
+	return !e.expire.IsZero() && !now.Before(e.expire)
+}
+
+// Get 返回key对应的值并记录一次访问
+// key不存在或者已经过期时ok为false, 过期的元素同时被删除
+func (c *cache[K, V]) Get(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if ok && c.expired(e, c.now()) {
+		c.removeEntry(e, Expired)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return value, false
+	}
+	c.stats.Hits++
+	c.policy.touch(e)
+	return e.value, true
+}
+
+// Peek 和Get一样, 但是不记录访问, 也不影响统计
+func (c *cache[K, V]) Peek(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if !ok || c.expired(e, c.now()) {
+		return value, false
+	}
+	return e.value, true
+}
+
+// Set 放入key与value, 使用Options.TTL, 见SetWithTTL
+func (c *cache[K, V]) Set(key K, value V) bool {
+	return c.SetWithTTL(key, value, c.opts.TTL)
+}
+
+// SetWithTTL 放入key与value, ttl以后过期, ttl<=0表示不会过期
+// 容量不够时先淘汰别的元素; value的cost超过MaxCost时放不进去,
+// 此时返回false, key原来的值也被删除
+func (c *cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	cost := int64(1)
+	if c.opts.Cost != nil {
+		cost = c.opts.Cost(key, value)
+	}
+	e, ok := c.items[key]
+	if c.opts.MaxCost > 0 && cost > c.opts.MaxCost {
+		if ok {
+			c.removeEntry(e, Removed)
+		}
+		return false
+	}
+
+	if ok {
+		// 替换: 先算作一次访问, 再为新的cost腾地方
+		c.cost -= e.cost
+		e.cost = 0
+		c.policy.touch(e)
+	} else {
+		e = &entry[K, V]{key: key}
+	}
+	for c.full(ok, cost) {
+		c.removeEntry(c.policy.victim(e), Evicted)
+	}
+	e.value = value
+	e.cost = cost
+	e.expire = time.Time{}
+	if ttl > 0 {
+		e.expire = c.now().Add(ttl)
+	}
+	c.cost += cost
+	if !ok {
+		c.items[key] = e
+		c.policy.add(e)
+	}
+	return true
+}
+
+// full 判断再放入cost以后是否会超出容量, exists表示key已经在缓存里
+func (c *cache[K, V]) full(exists bool, cost int64) bool {
+	n := len(c.items)
+	if !exists {
+		n++
+	}
+	return c.opts.MaxEntries > 0 && n > c.opts.MaxEntries ||
+		c.opts.MaxCost > 0 && c.cost+cost > c.opts.MaxCost
+}
+
+// Remove 删除key, key不存在时返回false
+func (c *cache[K, V]) Remove(key K) bool {
+	e, ok := c.items[key]
+	if ok {
+		c.removeEntry(e, Removed)
+	}
+	return ok
+}
+
+// RemoveExpired 删除所有过期的元素, 返回删除的个数, 复杂度为O(n)
+// 过期的元素在Get时才会被删除, 平时占着容量, 可以定期调用这个方法清理
+func (c *cache[K, V]) RemoveExpired() int {
+	now := c.now()
+	n := 0
+	for _, e := range c.items {
+		if c.expired(e, now) {
+			c.removeEntry(e, Expired)
+			n++
+		}
+	}
+	return n
+}
+
+// Len 返回元素的个数, 包括已经过期但是还没有删除的
+func (c *cache[K, V]) Len() int {
+	return len(c.items)
+}
+
+// Cost 返回所有元素的cost之和
+func (c *cache[K, V]) Cost() int64 {
+	return c.cost
+}
+
+// Purge 删除所有元素, 每个元素都会以Removed调用OnEvict
+func (c *cache[K, V]) Purge() {
+	for _, e := range c.items {
+		c.removeEntry(e, Removed)
+	}
+}
+
+// Stats 返回命中统计
+func (c *cache[K, V]) Stats() Stats {
+	return c.stats
+}
+
+func (c *cache[K, V]) removeEntry(e *entry[K, V], reason Reason) {
+	c.policy.remove(e)
+	delete(c.items, e.key)
+	c.cost -= e.cost
+	switch reason {
+	case Evicted:
+		c.stats.Evictions++
+	case Expired:
+		c.stats.Expirations++
+	}
+	if c.opts.OnEvict != nil {
+		c.opts.OnEvict(e.key, e.value, reason)
+	}
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type evictLog []string
+
+func (l *evictLog) onEvict(key string, value int, reason Reason) {
+	*l = append(*l, fmt.Sprintf("%s=%d %v", key, value, reason))
+}
+
+func (l *evictLog) check(t *testing.T, want ...string) {
+	t.Helper()
+	if fmt.Sprint(*l) != fmt.Sprint(want) {
+		t.Errorf("evicted %q, want %q", *l, want)
+	}
+	*l = nil
+}
+
+func TestLRU(t *testing.T) {
+	var log evictLog
+	c := NewLRU(Options[string, int]{MaxEntries: 3, OnEvict: log.onEvict})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a") // a变成最近访问的
+	c.Set("d", 4)
+	log.check(t, "b=2 evicted")
+	c.Peek("c") // Peek不影响顺序
+	c.Set("e", 5)
+	log.check(t, "c=3 evicted")
+	c.Set("a", 10) // 替换不调用OnEvict, 但是算一次访问
+	c.Set("f", 6)
+	log.check(t, "d=4 evicted")
+	if v, ok := c.Get("a"); !ok || v != 10 {
+		t.Errorf("Get(a) = %d, %v; want 10", v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("evicted key still present")
+	}
+	if !c.Remove("e") || c.Remove("e") {
+		t.Error("Remove should succeed exactly once")
+	}
+	log.check(t, "e=5 removed")
+	want := Stats{Hits: 2, Misses: 1, Evictions: 3}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+	c.Purge()
+	if c.Len() != 0 || len(log) != 2 {
+		t.Errorf("after Purge: Len = %d, evicted %q", c.Len(), log)
+	}
+}
+
+func TestLFU(t *testing.T) {
+	var log evictLog
+	c := NewLFU(Options[string, int]{MaxEntries: 3, OnEvict: log.onEvict})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	// c只被访问过一次
+	c.Set("d", 4)
+	log.check(t, "c=3 evicted")
+	// d只被访问过一次, b有两次
+	c.Set("e", 5)
+	log.check(t, "d=4 evicted")
+	c.Get("e")
+	c.Get("e") // a与e为3次, b为2次
+	c.Set("f", 6)
+	log.check(t, "b=2 evicted")
+	c.Get("f")
+	c.Get("f") // a, e, f都是3次, 次数相同时淘汰最久没有访问的a
+	c.Set("g", 7)
+	log.check(t, "a=1 evicted")
+
+	// 替换已有的key算一次访问, 而且不会淘汰它自己
+	c.Set("g", 70)
+	c.Set("g", 700)
+	c.Set("h", 8)
+	log.check(t, "e=5 evicted")
+	if v, ok := c.Get("g"); !ok || v != 700 {
+		t.Errorf("Get(g) = %d, %v; want 700", v, ok)
+	}
+}
+
+func TestCost(t *testing.T) {
+	for name, c := range map[string]Cache[string, int]{
+		"lru": NewLRU(Options[string, int]{MaxCost: 10, Cost: func(_ string, v int) int64 { return int64(v) }}),
+		"lfu": NewLFU(Options[string, int]{MaxCost: 10, Cost: func(_ string, v int) int64 { return int64(v) }}),
+	} {
+		c.Set("a", 4)
+		c.Set("b", 4)
+		c.Set("c", 4) // 总共12, 淘汰a
+		if _, ok := c.Peek("a"); ok || c.Len() != 2 {
+			t.Errorf("%s: a not evicted, Len = %d", name, c.Len())
+		}
+		if c.Set("big", 11) {
+			t.Errorf("%s: Set of value larger than MaxCost succeeded", name)
+		}
+		// 原来的值也被删除
+		if c.Set("b", 11) || c.Len() != 1 {
+			t.Errorf("%s: Len = %d after oversized replace, want 1", name, c.Len())
+		}
+		// 替换成更大的值时为它腾地方, 但是不淘汰它自己
+		c.Set("d", 2)
+		c.Set("c", 8)
+		if v, ok := c.Peek("c"); !ok || v != 8 || c.Len() != 2 {
+			t.Errorf("%s: Peek(c) = %d, %v, Len = %d", name, v, ok, c.Len())
+		}
+		c.Set("c", 10)
+		if c.Len() != 1 {
+			t.Errorf("%s: Len = %d, want 1", name, c.Len())
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var log evictLog
+	opts := Options[string, int]{
+		TTL:     time.Minute,
+		OnEvict: log.onEvict,
+		Now:     func() time.Time { return now },
+	}
+	for _, c := range []Cache[string, int]{NewLRU(opts), NewLFU(opts)} {
+		c.Set("a", 1)
+		c.SetWithTTL("b", 2, time.Hour)
+		c.SetWithTTL("c", 3, 0) // 不会过期
+		c.Set("d", 4)
+		now = now.Add(time.Minute)
+		if _, ok := c.Peek("a"); ok {
+			t.Error("Peek returned expired entry")
+		}
+		if _, ok := c.Get("a"); ok {
+			t.Error("Get returned expired entry")
+		}
+		log.check(t, "a=1 expired")
+		if n := c.RemoveExpired(); n != 1 {
+			t.Errorf("RemoveExpired = %d, want 1", n)
+		}
+		log.check(t, "d=4 expired")
+		now = now.Add(time.Hour)
+		if _, ok := c.Get("b"); ok {
+			t.Error("Get returned expired entry")
+		}
+		if v, ok := c.Get("c"); !ok || v != 3 {
+			t.Errorf("Get(c) = %d, %v", v, ok)
+		}
+		log.check(t, "b=2 expired")
+		want := Stats{Hits: 1, Misses: 2, Expirations: 3}
+		if got := c.Stats(); got != want {
+			t.Errorf("Stats = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func BenchmarkLRU(b *testing.B) {
+	c := NewLRU(Options[int, int]{MaxEntries: 1000})
+	for i := 0; i < b.N; i++ {
+		k := i % 2000
+		if _, ok := c.Get(k); !ok {
+			c.Set(k, i)
+		}
+	}
+}
+
+func BenchmarkLFU(b *testing.B) {
+	c := NewLFU(Options[int, int]{MaxEntries: 1000})
+	for i := 0; i < b.N; i++ {
+		k := i % 2000
+		if _, ok := c.Get(k); !ok {
+			c.Set(k, i)
+		}
+	}
+}
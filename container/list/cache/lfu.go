@@ -0,0 +1,74 @@
+package cache
+
+import "container/list"
+
+// LFU 是访问次数最少的元素先被淘汰的缓存, 次数相同时淘汰最久没有访问的
+// 所有操作都是O(1)
+// LFU不是并发安全的
+type LFU[K comparable, V any] struct {
+	cache[K, V]
+}
+
+// NewLFU 返回一个使用opts的空LFU
+func NewLFU[K comparable, V any](opts Options[K, V]) *LFU[K, V] {
+	c := new(LFU[K, V])
+	c.init(opts, new(lfuPolicy[K, V]))
+	return c
+}
+
+// lfuPolicy 把访问次数相同的元素放在同一个桶里, 桶按次数从小到大排列
+// 访问一次就把元素移到下一个桶的最前面, 从第一个桶的最后面淘汰
+type lfuPolicy[K comparable, V any] struct {
+	buckets list.List // Value是*freqBucket
+}
+
+type freqBucket struct {
+	count int
+	items list.List // Value是*entry, 最近访问的在前面
+}
+
+func (p *lfuPolicy[K, V]) add(e *entry[K, V]) {
+	e.count = 1
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*freqBucket).count != 1 {
+		front = p.buckets.PushFront(&freqBucket{count: 1})
+	}
+	e.freq = front
+	e.elem = front.Value.(*freqBucket).items.PushFront(e)
+}
+
+func (p *lfuPolicy[K, V]) touch(e *entry[K, V]) {
+	cur := e.freq
+	b := cur.Value.(*freqBucket)
+	next := cur.Next()
+	if next == nil || next.Value.(*freqBucket).count != e.count+1 {
+		next = p.buckets.InsertAfter(&freqBucket{count: e.count + 1}, cur)
+	}
+	b.items.Remove(e.elem)
+	if b.items.Len() == 0 {
+		p.buckets.Remove(cur)
+	}
+	e.count++
+	e.freq = next
+	e.elem = next.Value.(*freqBucket).items.PushFront(e)
+}
+
+func (p *lfuPolicy[K, V]) remove(e *entry[K, V]) {
+	b := e.freq.Value.(*freqBucket)
+	b.items.Remove(e.elem)
+	if b.items.Len() == 0 {
+		p.buckets.Remove(e.freq)
+	}
+	e.elem, e.freq = nil, nil
+}
+
+func (p *lfuPolicy[K, V]) victim(skip *entry[K, V]) *entry[K, V] {
+	for be := p.buckets.Front(); be != nil; be = be.Next() {
+		for el := be.Value.(*freqBucket).items.Back(); el != nil; el = el.Prev() {
+			if e := el.Value.(*entry[K, V]); e != skip {
+				return e
+			}
+		}
+	}
+	return nil
+}
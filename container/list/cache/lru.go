@@ -0,0 +1,43 @@
+package cache
+
+import "container/list"
+
+// LRU 是最近最少使用的元素先被淘汰的缓存
+// LRU不是并发安全的
+type LRU[K comparable, V any] struct {
+	cache[K, V]
+}
+
+// NewLRU 返回一个使用opts的空LRU
+func NewLRU[K comparable, V any](opts Options[K, V]) *LRU[K, V] {
+	c := new(LRU[K, V])
+	c.init(opts, new(lruPolicy[K, V]))
+	return c
+}
+
+// lruPolicy 按访问顺序排列元素, 最近访问的在前面, 从后面淘汰
+type lruPolicy[K comparable, V any] struct {
+	ll list.List
+}
+
+func (p *lruPolicy[K, V]) add(e *entry[K, V]) {
+	e.elem = p.ll.PushFront(e)
+}
+
+func (p *lruPolicy[K, V]) touch(e *entry[K, V]) {
+	p.ll.MoveToFront(e.elem)
+}
+
+func (p *lruPolicy[K, V]) remove(e *entry[K, V]) {
+	p.ll.Remove(e.elem)
+	e.elem = nil
+}
+
+func (p *lruPolicy[K, V]) victim(skip *entry[K, V]) *entry[K, V] {
+	for el := p.ll.Back(); el != nil; el = el.Prev() {
+		if e := el.Value.(*entry[K, V]); e != skip {
+			return e
+		}
+	}
+	return nil
+}
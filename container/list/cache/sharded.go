@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// Sharded 是并发安全的缓存, key按哈希分到多个分片里, 每个分片是一个加锁的LRU或者LFU
+// 不同分片上的操作互不阻塞; 容量平均分给各个分片, 所以淘汰只在分片内部进行
+type Sharded[K comparable, V any] struct {
+	seed   maphash.Seed
+	shards []shard[K, V]
+}
+
+type shard[K comparable, V any] struct {
+	sync.Mutex
+	c Cache[K, V]
+}
+
+// NewShardedLRU 返回一个有n个LRU分片的缓存, opts里的容量平均分给每个分片
+// n<=0时panic
+func NewShardedLRU[K comparable, V any](n int, opts Options[K, V]) *Sharded[K, V] {
+	return newSharded(n, opts, func(o Options[K, V]) Cache[K, V] { return NewLRU(o) })
+}
+
+// NewShardedLFU 返回一个有n个LFU分片的缓存, opts里的容量平均分给每个分片
+// n<=0时panic
+func NewShardedLFU[K comparable, V any](n int, opts Options[K, V]) *Sharded[K, V] {
+	return newSharded(n, opts, func(o Options[K, V]) Cache[K, V] { return NewLFU(o) })
+}
+
+func newSharded[K comparable, V any](n int, opts Options[K, V], newCache func(Options[K, V]) Cache[K, V]) *Sharded[K, V] {
+	if n <= 0 {
+		panic("cache: non-positive shard count")
+	}
+	// 向上取整, 总容量不会比要求的小
+	opts.MaxEntries = (opts.MaxEntries + n - 1) / n
+	opts.MaxCost = (opts.MaxCost + int64(n) - 1) / int64(n)
+	s := &Sharded[K, V]{
+		seed:   maphash.MakeSeed(),
+		shards: make([]shard[K, V], n),
+	}
+	for i := range s.shards {
+		s.shards[i].c = newCache(opts)
+	}
+	return s
+}
+
+func (s *Sharded[K, V]) shard(key K) *shard[K, V] {
+	return &s.shards[maphash.Comparable(s.seed, key)%uint64(len(s.shards))]
+}
+
+// Get 返回key对应的值并记录一次访问
+func (s *Sharded[K, V]) Get(key K) (V, bool) {
+	sh := s.shard(key)
+	sh.Lock()
+	defer sh.Unlock()
+	return sh.c.Get(key)
+}
+
+// Peek 和Get一样, 但是不记录访问, 也不影响统计
+func (s *Sharded[K, V]) Peek(key K) (V, bool) {
+	sh := s.shard(key)
+	sh.Lock()
+	defer sh.Unlock()
+	return sh.c.Peek(key)
+}
+
+// Set 放入key与value, 见LRU.SetWithTTL
+func (s *Sharded[K, V]) Set(key K, value V) bool {
+	sh := s.shard(key)
+	sh.Lock()
+	defer sh.Unlock()
+	return sh.c.Set(key, value)
+}
+
+// SetWithTTL 放入key与value, ttl以后过期, 见LRU.SetWithTTL
+func (s *Sharded[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	sh := s.shard(key)
+	sh.Lock()
+	defer sh.Unlock()
+	return sh.c.SetWithTTL(key, value, ttl)
+}
+
+// Remove 删除key, key不存在时返回false
+func (s *Sharded[K, V]) Remove(key K) bool {
+	sh := s.shard(key)
+	sh.Lock()
+	defer sh.Unlock()
+	return sh.c.Remove(key)
+}
+
+// RemoveExpired 删除所有过期的元素, 返回删除的个数, 每次只锁一个分片
+func (s *Sharded[K, V]) RemoveExpired() int {
+	n := 0
+	s.each(func(c Cache[K, V]) { n += c.RemoveExpired() })
+	return n
+}
+
+// Len 返回元素的个数, 各个分片不是同时统计的, 并发修改时只是一个近似值
+func (s *Sharded[K, V]) Len() int {
+	n := 0
+	s.each(func(c Cache[K, V]) { n += c.Len() })
+	return n
+}
+
+// Purge 删除所有元素
+func (s *Sharded[K, V]) Purge() {
+	s.each(func(c Cache[K, V]) { c.Purge() })
+}
+
+// Stats 返回所有分片的统计之和
+func (s *Sharded[K, V]) Stats() Stats {
+	var st Stats
+	s.each(func(c Cache[K, V]) { st.add(c.Stats()) })
+	return st
+}
+
+func (s *Sharded[K, V]) each(f func(c Cache[K, V])) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.Lock()
+		f(sh.c)
+		sh.Unlock()
+	}
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSharded(t *testing.T) {
+	for name, c := range map[string]*Sharded[int, int]{
+		"lru": NewShardedLRU(8, Options[int, int]{MaxEntries: 800}),
+		"lfu": NewShardedLFU(8, Options[int, int]{MaxEntries: 800}),
+	} {
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 2000; i++ {
+					k := g*2000 + i
+					c.Set(k, k)
+					if v, ok := c.Get(k); ok && v != k {
+						t.Errorf("%s: Get(%d) = %d", name, k, v)
+					}
+					c.Get(i) // 和别的goroutine访问同样的key
+				}
+			}(g)
+		}
+		wg.Wait()
+		// 每个分片最多100个
+		if n := c.Len(); n > 800 || n < 100 {
+			t.Errorf("%s: Len = %d", name, n)
+		}
+		st := c.Stats()
+		if st.Hits+st.Misses != 8*2000*2 {
+			t.Errorf("%s: %d lookups recorded, want %d", name, st.Hits+st.Misses, 8*2000*2)
+		}
+		c.Purge()
+		if c.Len() != 0 {
+			t.Errorf("%s: Len = %d after Purge", name, c.Len())
+		}
+	}
+}
+
+func BenchmarkShardedLRUParallel(b *testing.B) {
+	c := NewShardedLRU(16, Options[int, int]{MaxEntries: 10000})
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := i % 20000
+			if _, ok := c.Get(k); !ok {
+				c.Set(k, i)
+			}
+			i++
+		}
+	})
+}
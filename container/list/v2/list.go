@@ -0,0 +1,285 @@
+// Package list 和container/list一样实现双向链表, 但是元素的类型是泛型参数,
+// 访问Value不用再做类型断言; 另外提供了迭代器, Filter, Find与Clone
+//
+// 遍历:
+//
+//	for e := l.Front(); e != nil; e = e.Next() {
+//		// do something with e.Value
+//	}
+//
+// 或者
+//
+//	for v := range l.All() {
+//		// do something with v
+//	}
+package list
+
+import "iter"
+
+// Element 是链表里的一个元素
+type Element[T any] struct {
+	// 和container/list一样, 链表内部是一个环, &l.root既是最后一个元素的next,
+	// 也是第一个元素的prev
+	next, prev *Element[T]
+
+	list *List[T]
+
+	// Value 是元素里保存的值
+	Value T
+}
+
+// Next 返回下一个元素, 没有时返回nil
+func (e *Element[T]) Next() *Element[T] {
+	if p := e.next; e.list != nil && p != &e.list.root {
+		return p
+	}
+	return nil
+}
+
+// Prev 返回上一个元素, 没有时返回nil
+func (e *Element[T]) Prev() *Element[T] {
+	if p := e.prev; e.list != nil && p != &e.list.root {
+		return p
+	}
+	return nil
+}
+
+// List 是双向链表, 零值是一个可以直接使用的空链表
+type List[T any] struct {
+	root Element[T] // 哨兵, 只用到&root, root.prev与root.next
+	len  int
+}
+
+// Init 初始化或者清空l
+func (l *List[T]) Init() *List[T] {
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	l.len = 0
+	return l
+}
+
+// New 返回一个初始化好的空链表
+func New[T any]() *List[T] {
+	return new(List[T]).Init()
+}
+
+// Len 返回元素的个数, 复杂度为O(1)
+func (l *List[T]) Len() int {
+	return l.len
+}
+
+// Front 返回第一个元素, 链表为空时返回nil
+func (l *List[T]) Front() *Element[T] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.next
+}
+
+// Back 返回最后一个元素, 链表为空时返回nil
+func (l *List[T]) Back() *Element[T] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// lazyInit 让零值的List可以直接使用
+func (l *List[T]) lazyInit() {
+	if l.root.next == nil {
+		l.Init()
+	}
+}
+
+// insert 把e插到at后面
+func (l *List[T]) insert(e, at *Element[T]) *Element[T] {
+	e.prev = at
+	e.next = at.next
+	e.prev.next = e
+	e.next.prev = e
+	e.list = l
+	l.len++
+	return e
+}
+
+func (l *List[T]) insertValue(v T, at *Element[T]) *Element[T] {
+	return l.insert(&Element[T]{Value: v}, at)
+}
+
+func (l *List[T]) remove(e *Element[T]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.next = nil // 防止内存泄漏
+	e.prev = nil
+	e.list = nil
+	l.len--
+}
+
+// move 把e移到at后面
+func (l *List[T]) move(e, at *Element[T]) {
+	if e == at {
+		return
+	}
+	e.prev.next = e.next
+	e.next.prev = e.prev
+
+	e.prev = at
+	e.next = at.next
+	e.prev.next = e
+	e.next.prev = e
+}
+
+// Remove 删除e并返回e.Value, e不是l的元素时什么也不做
+func (l *List[T]) Remove(e *Element[T]) T {
+	if e.list == l {
+		l.remove(e)
+	}
+	return e.Value
+}
+
+// PushFront 在最前面插入v, 返回新的元素
+func (l *List[T]) PushFront(v T) *Element[T] {
+	l.lazyInit()
+	return l.insertValue(v, &l.root)
+}
+
+// PushBack 在最后面插入v, 返回新的元素
+func (l *List[T]) PushBack(v T) *Element[T] {
+	l.lazyInit()
+	return l.insertValue(v, l.root.prev)
+}
+
+// InsertBefore 在mark前面插入v, 返回新的元素
+// mark不是l的元素时什么也不做, 返回nil
+func (l *List[T]) InsertBefore(v T, mark *Element[T]) *Element[T] {
+	if mark.list != l {
+		return nil
+	}
+	return l.insertValue(v, mark.prev)
+}
+
+// InsertAfter 在mark后面插入v, 返回新的元素
+// mark不是l的元素时什么也不做, 返回nil
+func (l *List[T]) InsertAfter(v T, mark *Element[T]) *Element[T] {
+	if mark.list != l {
+		return nil
+	}
+	return l.insertValue(v, mark)
+}
+
+// MoveToFront 把e移到最前面, e不是l的元素时什么也不做
+func (l *List[T]) MoveToFront(e *Element[T]) {
+	if e.list != l || l.root.next == e {
+		return
+	}
+	l.move(e, &l.root)
+}
+
+// MoveToBack 把e移到最后面, e不是l的元素时什么也不做
+func (l *List[T]) MoveToBack(e *Element[T]) {
+	if e.list != l || l.root.prev == e {
+		return
+	}
+	l.move(e, l.root.prev)
+}
+
+// MoveBefore 把e移到mark前面, e或者mark不是l的元素, 或者e==mark时什么也不做
+func (l *List[T]) MoveBefore(e, mark *Element[T]) {
+	if e.list != l || e == mark || mark.list != l {
+		return
+	}
+	l.move(e, mark.prev)
+}
+
+// MoveAfter 把e移到mark后面, e或者mark不是l的元素, 或者e==mark时什么也不做
+func (l *List[T]) MoveAfter(e, mark *Element[T]) {
+	if e.list != l || e == mark || mark.list != l {
+		return
+	}
+	l.move(e, mark)
+}
+
+// PushBackList 把other的值依次复制到l的后面, l和other可以是同一个链表
+func (l *List[T]) PushBackList(other *List[T]) {
+	l.lazyInit()
+	for i, e := other.Len(), other.Front(); i > 0; i, e = i-1, e.Next() {
+		l.insertValue(e.Value, l.root.prev)
+	}
+}
+
+// PushFrontList 把other的值复制到l的前面, 顺序不变, l和other可以是同一个链表
+func (l *List[T]) PushFrontList(other *List[T]) {
+	l.lazyInit()
+	for i, e := other.Len(), other.Back(); i > 0; i, e = i-1, e.Prev() {
+		l.insertValue(e.Value, &l.root)
+	}
+}
+
+// All 返回从前往后遍历所有值的迭代器
+// 遍历时可以删除当前的元素
+func (l *List[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for e := l.Front(); e != nil; {
+			next := e.Next()
+			if !yield(e.Value) {
+				return
+			}
+			e = next
+		}
+	}
+}
+
+// Backward 返回从后往前遍历所有值的迭代器
+// 遍历时可以删除当前的元素
+func (l *List[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for e := l.Back(); e != nil; {
+			prev := e.Prev()
+			if !yield(e.Value) {
+				return
+			}
+			e = prev
+		}
+	}
+}
+
+// Elements 返回从前往后遍历所有元素的迭代器, 用来在遍历时修改或者删除元素
+func (l *List[T]) Elements() iter.Seq[*Element[T]] {
+	return func(yield func(*Element[T]) bool) {
+		for e := l.Front(); e != nil; {
+			next := e.Next()
+			if !yield(e) {
+				return
+			}
+			e = next
+		}
+	}
+}
+
+// Find 返回第一个满足match的元素, 没有时返回nil
+func (l *List[T]) Find(match func(T) bool) *Element[T] {
+	for e := l.Front(); e != nil; e = e.Next() {
+		if match(e.Value) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Filter 返回一个新的链表, 包含所有满足keep的值, 顺序不变, l不变
+func (l *List[T]) Filter(keep func(T) bool) *List[T] {
+	r := New[T]()
+	for e := l.Front(); e != nil; e = e.Next() {
+		if keep(e.Value) {
+			r.insertValue(e.Value, r.root.prev)
+		}
+	}
+	return r
+}
+
+// Clone 返回l的浅拷贝, 值按赋值复制
+func (l *List[T]) Clone() *List[T] {
+	r := New[T]()
+	r.PushBackList(l)
+	return r
+}
@@ -0,0 +1,190 @@
+package list
+
+import (
+	"slices"
+	"testing"
+)
+
+func checkListLen[T any](t *testing.T, l *List[T], n int) bool {
+	t.Helper()
+	if got := l.Len(); got != n {
+		t.Errorf("l.Len() = %d, want %d", got, n)
+		return false
+	}
+	return true
+}
+
+// checkList 检查链表的长度, 前后指针, 以及从前往后的值
+func checkList[T comparable](t *testing.T, l *List[T], want []T) {
+	t.Helper()
+	if !checkListLen(t, l, len(want)) {
+		return
+	}
+	if len(want) == 0 {
+		if l.Front() != nil || l.Back() != nil {
+			t.Errorf("empty list has Front or Back")
+		}
+		return
+	}
+	i := 0
+	root := &l.root
+	for e := l.Front(); e != nil; e = e.Next() {
+		if e.Value != want[i] {
+			t.Errorf("elt[%d].Value = %v, want %v", i, e.Value, want[i])
+		}
+		prev := root
+		if i > 0 {
+			prev = e.Prev()
+		}
+		if e.prev != prev {
+			t.Errorf("elt[%d].prev = %p, want %p", i, e.prev, prev)
+		}
+		i++
+	}
+	if got := slices.Collect(l.All()); !slices.Equal(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+	back := slices.Clone(want)
+	slices.Reverse(back)
+	if got := slices.Collect(l.Backward()); !slices.Equal(got, back) {
+		t.Errorf("Backward() = %v, want %v", got, back)
+	}
+}
+
+func TestList(t *testing.T) {
+	var l List[int] // 零值可以直接使用
+	checkList(t, &l, nil)
+
+	e := l.PushFront(1)
+	checkList(t, &l, []int{1})
+	l.MoveToFront(e)
+	l.MoveToBack(e)
+	checkList(t, &l, []int{1})
+	l.Remove(e)
+	checkList(t, &l, nil)
+
+	e2 := l.PushFront(2)
+	e1 := l.PushFront(1)
+	e3 := l.PushBack(3)
+	e4 := l.PushBack(4)
+	checkList(t, &l, []int{1, 2, 3, 4})
+
+	l.Remove(e2)
+	checkList(t, &l, []int{1, 3, 4})
+	l.MoveToFront(e3)
+	checkList(t, &l, []int{3, 1, 4})
+	l.MoveToFront(e1)
+	l.MoveToBack(e3)
+	checkList(t, &l, []int{1, 4, 3})
+	l.MoveToBack(e3) // 已经在最后
+	checkList(t, &l, []int{1, 4, 3})
+
+	e2 = l.InsertBefore(2, e1)
+	checkList(t, &l, []int{2, 1, 4, 3})
+	l.Remove(e2)
+	e2 = l.InsertAfter(2, e1)
+	checkList(t, &l, []int{1, 2, 4, 3})
+	l.MoveBefore(e4, e2)
+	checkList(t, &l, []int{1, 4, 2, 3})
+	l.MoveAfter(e1, e3)
+	checkList(t, &l, []int{4, 2, 3, 1})
+	l.MoveAfter(e1, e1)
+	l.MoveBefore(e4, e4)
+	checkList(t, &l, []int{4, 2, 3, 1})
+
+	// 遍历时删除当前元素
+	for e := range l.Elements() {
+		l.Remove(e)
+	}
+	checkList(t, &l, nil)
+}
+
+func TestExtending(t *testing.T) {
+	l1 := New[int]()
+	l2 := New[int]()
+	l1.PushBack(1)
+	l1.PushBack(2)
+	l2.PushBack(3)
+	l2.PushBack(4)
+
+	l3 := New[int]()
+	l3.PushBackList(l1)
+	l3.PushBackList(l2)
+	checkList(t, l3, []int{1, 2, 3, 4})
+	l3 = New[int]()
+	l3.PushFrontList(l2)
+	l3.PushFrontList(l1)
+	checkList(t, l3, []int{1, 2, 3, 4})
+
+	// 和自己拼接
+	l3.PushBackList(l3)
+	checkList(t, l3, []int{1, 2, 3, 4, 1, 2, 3, 4})
+	l1.PushFrontList(l1)
+	checkList(t, l1, []int{1, 2, 1, 2})
+}
+
+// 别的链表的元素被忽略, 和container/list一样
+func TestForeignElements(t *testing.T) {
+	l1 := New[int]()
+	l2 := New[int]()
+	e1 := l1.PushBack(1)
+	e2 := l2.PushBack(2)
+
+	if l1.InsertBefore(3, e2) != nil || l1.InsertAfter(3, e2) != nil {
+		t.Error("insert relative to a foreign element succeeded")
+	}
+	l1.MoveToFront(e2)
+	l1.MoveToBack(e2)
+	l1.MoveBefore(e1, e2)
+	l1.MoveAfter(e1, e2)
+	l1.MoveBefore(e2, e1)
+	if v := l1.Remove(e2); v != 2 {
+		t.Errorf("Remove of foreign element returned %d, want its value 2", v)
+	}
+	checkList(t, l1, []int{1})
+	checkList(t, l2, []int{2})
+
+	// 已经删除的元素也被忽略
+	l1.Remove(e1)
+	l1.Remove(e1)
+	l1.MoveToFront(e1)
+	if l1.InsertAfter(3, e1) != nil {
+		t.Error("insert relative to a removed element succeeded")
+	}
+	checkList(t, l1, nil)
+}
+
+func TestHelpers(t *testing.T) {
+	l := New[int]()
+	for i := 1; i <= 6; i++ {
+		l.PushBack(i)
+	}
+	even := func(v int) bool { return v%2 == 0 }
+	checkList(t, l.Filter(even), []int{2, 4, 6})
+	checkList(t, l, []int{1, 2, 3, 4, 5, 6})
+
+	if e := l.Find(func(v int) bool { return v > 3 }); e == nil || e.Value != 4 {
+		t.Errorf("Find = %v", e)
+	}
+	if e := l.Find(func(v int) bool { return v > 6 }); e != nil {
+		t.Errorf("Find = %v, want nil", e.Value)
+	}
+
+	c := l.Clone()
+	c.Remove(c.Front())
+	c.Front().Value = 20
+	checkList(t, c, []int{20, 3, 4, 5, 6})
+	checkList(t, l, []int{1, 2, 3, 4, 5, 6})
+
+	// 提前结束遍历
+	var got []int
+	for v := range l.Backward() {
+		if v < 4 {
+			break
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{6, 5, 4}) {
+		t.Errorf("Backward with break = %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+// Package queue 提供给生产者/消费者流水线用的无锁队列, 可以代替加锁的container/list
+//
+// Queue是Michael与Scott的无锁队列, 任意多个生产者与消费者可以并发使用, 不限长度;
+// SPSC是定长的环形队列, 只允许一个生产者与一个消费者, 但是不用CAS, 也不分配内存
+package queue
+
+import "sync/atomic"
+
+// Queue 是无界的多生产者多消费者无锁队列
+// 见Michael, Scott: Simple, Fast, and Practical Non-Blocking and Blocking
+// Concurrent Queue Algorithms, PODC 1996
+//
+// head总是指向一个哨兵节点, 真正的第一个元素是head.next;
+// 出队就是把head移到head.next, 后者成为新的哨兵
+type Queue[T any] struct {
+	head atomic.Pointer[node[T]]
+	_    [56]byte // head与tail分别被消费者与生产者修改, 放在不同的cache line里
+	tail atomic.Pointer[node[T]]
+	_    [56]byte
+	len  atomic.Int64
+}
+
+type node[T any] struct {
+	value T
+	next  atomic.Pointer[node[T]]
+}
+
+// New 返回一个空的Queue
+func New[T any]() *Queue[T] {
+	q := new(Queue[T])
+	dummy := new(node[T])
+	q.head.Store(dummy)
+	q.tail.Store(dummy)
+	return q
+}
+
+// Enqueue 把v放到队尾, 不会阻塞
+func (q *Queue[T]) Enqueue(v T) {
+	n := &node[T]{value: v}
+	for {
+		tail := q.tail.Load()
+		next := tail.next.Load()
+		if tail != q.tail.Load() {
+			continue
+		}
+		if next != nil {
+			// tail落后了, 帮别的生产者把它往前推
+			q.tail.CompareAndSwap(tail, next)
+			continue
+		}
+		if tail.next.CompareAndSwap(nil, n) {
+			// 失败也没关系, 说明别人已经推过了
+			q.tail.CompareAndSwap(tail, n)
+			break
+		}
+	}
+	q.len.Add(1)
+}
+
+// Dequeue 取出队头的元素, 队列为空时ok为false, 不会阻塞
+//
+// 出队以后元素所在的节点成为新的哨兵, 它的value要等下一次出队才会被释放:
+// 别的消费者可能还在读它, 不能在这里清零
+func (q *Queue[T]) Dequeue() (v T, ok bool) {
+	for {
+		head := q.head.Load()
+		tail := q.tail.Load()
+		next := head.next.Load()
+		if head != q.head.Load() {
+			continue
+		}
+		if next == nil {
+			return v, false
+		}
+		if head == tail {
+			// 有元素但是tail还没推过来, 先帮忙推
+			q.tail.CompareAndSwap(tail, next)
+			continue
+		}
+		v = next.value
+		if q.head.CompareAndSwap(head, next) {
+			q.len.Add(-1)
+			return v, true
+		}
+	}
+}
+
+// Len 返回元素的个数, 有并发修改时只是一个近似值
+func (q *Queue[T]) Len() int {
+	// 计数在链表修改之后才更新, 出队的计数可能先于入队的, 所以会短暂地为负
+	if n := q.len.Load(); n > 0 {
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"container/list"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	q := New[int]()
+	if _, ok := q.Dequeue(); ok || q.Len() != 0 {
+		t.Fatal("new queue not empty")
+	}
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+	if q.Len() != 10 {
+		t.Errorf("Len = %d, want 10", q.Len())
+	}
+	for i := 0; i < 10; i++ {
+		if v, ok := q.Dequeue(); !ok || v != i {
+			t.Fatalf("Dequeue = %d, %v; want %d", v, ok, i)
+		}
+	}
+	if _, ok := q.Dequeue(); ok {
+		t.Error("Dequeue on drained queue succeeded")
+	}
+}
+
+// TestQueueStress 多个生产者与消费者并发, 用-race运行
+// 每个元素恰好被取出一次, 同一个生产者的元素按顺序被每个消费者看到
+func TestQueueStress(t *testing.T) {
+	const producers, consumers, perProducer = 4, 4, 20000
+	q := New[[2]int]()
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.Enqueue([2]int{p, i})
+			}
+		}(p)
+	}
+
+	seen := make([][]int, consumers)
+	var done sync.WaitGroup
+	var remaining sync.WaitGroup
+	remaining.Add(producers * perProducer)
+	stop := make(chan struct{})
+	for c := 0; c < consumers; c++ {
+		done.Add(1)
+		go func(c int) {
+			defer done.Done()
+			seen[c] = make([]int, producers*perProducer)
+			last := make([]int, producers)
+			for p := range last {
+				last[p] = -1
+			}
+			for {
+				v, ok := q.Dequeue()
+				if !ok {
+					select {
+					case <-stop:
+						return
+					default:
+						runtime.Gosched()
+						continue
+					}
+				}
+				p, i := v[0], v[1]
+				if i <= last[p] {
+					t.Errorf("consumer %d saw producer %d item %d after %d", c, p, i, last[p])
+				}
+				last[p] = i
+				seen[c][p*perProducer+i]++
+				remaining.Done()
+			}
+		}(c)
+	}
+	wg.Wait()
+	remaining.Wait()
+	close(stop)
+	done.Wait()
+
+	for x := 0; x < producers*perProducer; x++ {
+		n := 0
+		for c := range seen {
+			n += seen[c][x]
+		}
+		if n != 1 {
+			t.Fatalf("item %d dequeued %d times", x, n)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len = %d, want 0", q.Len())
+	}
+}
+
+// lockedList 是加锁的list.List, 用来对比
+type lockedList struct {
+	mu sync.Mutex
+	l  list.List
+}
+
+func (q *lockedList) Enqueue(v int) {
+	q.mu.Lock()
+	q.l.PushBack(v)
+	q.mu.Unlock()
+}
+
+func (q *lockedList) Dequeue() (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e := q.l.Front()
+	if e == nil {
+		return 0, false
+	}
+	return q.l.Remove(e).(int), true
+}
+
+// 每个goroutine放入一个再取出一个
+func BenchmarkQueueParallel(b *testing.B) {
+	q := New[int]()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			q.Enqueue(1)
+			q.Dequeue()
+		}
+	})
+}
+
+func BenchmarkLockedListParallel(b *testing.B) {
+	q := new(lockedList)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			q.Enqueue(1)
+			q.Dequeue()
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package queue
+
+import "sync/atomic"
+
+// SPSC 是定长的单生产者单消费者环形队列
+// 同一时间只能有一个goroutine调用Enqueue, 一个goroutine调用Dequeue,
+// 这样head只被消费者修改, tail只被生产者修改, 不需要CAS
+type SPSC[T any] struct {
+	buf  []T
+	mask uint64
+	_    [32]byte
+	head atomic.Uint64 // 下一个要读的位置, 只被消费者修改
+	_    [56]byte
+	tail atomic.Uint64 // 下一个要写的位置, 只被生产者修改
+	_    [56]byte
+}
+
+// NewSPSC 返回一个至少能放下capacity个元素的SPSC, 容量向上取整到2的幂
+// capacity<=0时panic
+func NewSPSC[T any](capacity int) *SPSC[T] {
+	if capacity <= 0 {
+		panic("queue: non-positive capacity for NewSPSC")
+	}
+	c := 1
+	for c < capacity {
+		c <<= 1
+	}
+	return &SPSC[T]{buf: make([]T, c), mask: uint64(c - 1)}
+}
+
+// Cap 返回队列的容量
+func (q *SPSC[T]) Cap() int {
+	return len(q.buf)
+}
+
+// Enqueue 把v放到队尾, 队列满时返回false, 只能由生产者调用
+func (q *SPSC[T]) Enqueue(v T) bool {
+	tail := q.tail.Load()
+	if tail-q.head.Load() == uint64(len(q.buf)) {
+		return false
+	}
+	q.buf[tail&q.mask] = v
+	q.tail.Store(tail + 1) // 发布: 消费者看到新的tail时一定能看到写进去的值
+	return true
+}
+
+// Dequeue 取出队头的元素, 队列为空时ok为false, 只能由消费者调用
+func (q *SPSC[T]) Dequeue() (v T, ok bool) {
+	head := q.head.Load()
+	if head == q.tail.Load() {
+		return v, false
+	}
+	var zero T
+	i := head & q.mask
+	v = q.buf[i]
+	q.buf[i] = zero // 不要让缓冲区继续引用它
+	q.head.Store(head + 1)
+	return v, true
+}
+
+// Len 返回元素的个数, 有并发修改时只是一个近似值
+func (q *SPSC[T]) Len() int {
+	// 先读head, 这样tail只会更大, 结果不会是负数
+	head := q.head.Load()
+	return int(q.tail.Load() - head)
+}
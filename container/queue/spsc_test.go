@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestSPSC(t *testing.T) {
+	q := NewSPSC[int](5)
+	if q.Cap() != 8 {
+		t.Errorf("Cap = %d, want 8", q.Cap())
+	}
+	// 绕过缓冲区的末尾几圈
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 8; i++ {
+			if !q.Enqueue(i) {
+				t.Fatalf("Enqueue(%d) failed", i)
+			}
+		}
+		if q.Enqueue(8) {
+			t.Fatal("Enqueue on full queue succeeded")
+		}
+		if q.Len() != 8 {
+			t.Errorf("Len = %d, want 8", q.Len())
+		}
+		for i := 0; i < 8; i++ {
+			if v, ok := q.Dequeue(); !ok || v != i {
+				t.Fatalf("Dequeue = %d, %v; want %d", v, ok, i)
+			}
+		}
+		if _, ok := q.Dequeue(); ok {
+			t.Fatal("Dequeue on empty queue succeeded")
+		}
+	}
+}
+
+// TestSPSCStress 一个生产者一个消费者, 用-race运行, 元素必须按顺序全部收到
+func TestSPSCStress(t *testing.T) {
+	const n = 200000
+	q := NewSPSC[int](64)
+	go func() {
+		for i := 0; i < n; {
+			if q.Enqueue(i) {
+				i++
+			} else {
+				runtime.Gosched()
+			}
+		}
+	}()
+	for want := 0; want < n; {
+		v, ok := q.Dequeue()
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		if v != want {
+			t.Fatalf("Dequeue = %d, want %d", v, want)
+		}
+		want++
+	}
+}
+
+// 一个生产者一个消费者, 传b.N个元素
+func BenchmarkSPSC(b *testing.B) {
+	q := NewSPSC[int](1024)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < b.N; {
+			if _, ok := q.Dequeue(); ok {
+				i++
+			} else {
+				runtime.Gosched()
+			}
+		}
+		close(done)
+	}()
+	for i := 0; i < b.N; {
+		if q.Enqueue(i) {
+			i++
+		} else {
+			runtime.Gosched()
+		}
+	}
+	<-done
+}
+
+func BenchmarkLockedListSPSC(b *testing.B) {
+	q := new(lockedList)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < b.N; {
+			if _, ok := q.Dequeue(); ok {
+				i++
+			} else {
+				runtime.Gosched()
+			}
+		}
+		close(done)
+	}()
+	for i := 0; i < b.N; i++ {
+		q.Enqueue(i)
+	}
+	<-done
+}
@@ -0,0 +1,107 @@
+package ring
+
+import "testing"
+
+// verify 检查环的长度, 前后指针与元素的和
+func verify(t *testing.T, r *Ring, n int, sum int) {
+	t.Helper()
+	if k := r.Len(); k != n {
+		t.Errorf("r.Len() == %d; expected %d", k, n)
+	}
+	k, s := 0, 0
+	r.Do(func(p interface{}) {
+		k++
+		if x, ok := p.(int); ok {
+			s += x
+		}
+	})
+	if k != n {
+		t.Errorf("number of forward iterations == %d; expected %d", k, n)
+	}
+	if sum >= 0 && s != sum {
+		t.Errorf("forward ring sum = %d; expected %d", s, sum)
+	}
+	if r == nil {
+		return
+	}
+	if r.Next() != r.next || r.Prev() != r.prev {
+		t.Error("Next or Prev inconsistent with internal pointers")
+	}
+	for p, i := r, 0; i < n; p, i = p.next, i+1 {
+		if p.next.prev != p || p.prev.next != p {
+			t.Errorf("broken links at element %d", i)
+		}
+	}
+	if r.Move(n) != r || r.Move(-n) != r {
+		t.Error("Move by Len does not return to r")
+	}
+	if n > 0 && r.Move(1) != r.Next() {
+		t.Error("Move(1) != Next()")
+	}
+}
+
+func makeN(n int) *Ring {
+	r := New(n)
+	for i := 1; i <= n; i++ {
+		r.Value = i
+		r = r.Next()
+	}
+	return r
+}
+
+func sumN(n int) int { return (n*n + n) / 2 }
+
+func TestCornerCases(t *testing.T) {
+	var r0 *Ring
+	verify(t, r0, 0, 0)
+	var r1 Ring // 零值是一个元素的环
+	verify(t, &r1, 1, 0)
+	r1.Link(r0)
+	verify(t, &r1, 1, 0)
+	r1.Unlink(0)
+	verify(t, &r1, 1, 0)
+}
+
+func TestNewAndMove(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		verify(t, New(i), i, -1)
+	}
+	r := makeN(10)
+	verify(t, r, 10, sumN(10))
+	if v := r.Move(3).Value; v != 4 {
+		t.Errorf("Move(3).Value = %v, want 4", v)
+	}
+	if v := r.Move(-3).Value; v != 8 {
+		t.Errorf("Move(-3).Value = %v, want 8", v)
+	}
+}
+
+func TestLink(t *testing.T) {
+	// 不同的环: 合并
+	r1 := makeN(3)
+	r2 := makeN(4)
+	n := r1.Link(r2)
+	verify(t, r1, 7, sumN(3)+sumN(4))
+	if n.Value != 2 {
+		t.Errorf("Link returned element %v, want 2", n.Value)
+	}
+
+	// 同一个环: 删除中间的元素
+	r := makeN(10)
+	s := r.Link(r.Move(4)) // 删除2, 3, 4
+	verify(t, r, 7, sumN(10)-9)
+	verify(t, s, 3, 9)
+}
+
+func TestUnlink(t *testing.T) {
+	r := makeN(10)
+	s := r.Unlink(3)
+	verify(t, r, 7, sumN(10)-9)
+	verify(t, s, 3, 9)
+	if r.Unlink(0) != nil || r.Unlink(-1) != nil {
+		t.Error("Unlink(n <= 0) returned a ring")
+	}
+	verify(t, r, 7, sumN(10)-9)
+	r.Unlink(6)
+	verify(t, r, 1, 1)
+}
@@ -0,0 +1,371 @@
+// Package skiplist 实现基于跳表的有序map
+// 查找, 插入与删除的期望复杂度都是O(log(n)), 支持从某个key开始双向遍历,
+// 以及按排名访问: 第k个元素, 某个key排第几
+//
+// SkipList是并发安全的: 读操作之间不互相阻塞, 写操作独占
+package skiplist
+
+import (
+	"cmp"
+	"iter"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	maxLevel = 32
+	pFactor  = 4 // 每个节点以1/pFactor的概率再长高一层
+)
+
+type node[K any, V any] struct {
+	key   K
+	value V
+	prev  *node[K, V] // 第0层的前一个节点, 第一个节点为nil
+	next  []*node[K, V]
+	// span[i] 是从这个节点沿第i层走到next[i]时, 在第0层上跨过的节点数
+	// next[i]为nil时是到末尾的节点数加1, 用来计算排名
+	span    []int
+	deleted bool // 已经被删除, 迭代器停在上面时用来跳过
+}
+
+// SkipList 是按key排序的map
+type SkipList[K any, V any] struct {
+	mu      sync.RWMutex
+	compare func(a, b K) int
+	head    node[K, V] // 哨兵, 不保存数据
+	tail    *node[K, V]
+	level   int
+	length  int
+	rnd     *rand.Rand
+}
+
+// New 返回一个按<排序的空SkipList
+func New[K cmp.Ordered, V any]() *SkipList[K, V] {
+	return NewFunc[K, V](cmp.Compare[K])
+}
+
+// NewFunc 返回一个按compare排序的空SkipList
+// compare(a, b)在a<b时返回负数, a==b时返回0, a>b时返回正数
+func NewFunc[K any, V any](compare func(a, b K) int) *SkipList[K, V] {
+	l := &SkipList[K, V]{
+		compare: compare,
+		level:   1,
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	l.head.next = make([]*node[K, V], maxLevel)
+	l.head.span = make([]int, maxLevel)
+	l.head.span[0] = 1
+	return l
+}
+
+// SetSeed 设置决定节点高度的随机数种子, 同样的种子与操作序列得到同样的结构,
+// 测试时用来复现问题
+func (l *SkipList[K, V]) SetSeed(seed int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rnd = rand.New(rand.NewSource(seed))
+}
+
+func (l *SkipList[K, V]) randomLevel() int {
+	lvl := 1
+	for lvl < maxLevel && l.rnd.Intn(pFactor) == 0 {
+		lvl++
+	}
+	return lvl
+}
+
+// Len 返回元素的个数
+func (l *SkipList[K, V]) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.length
+}
+
+// findLess 返回第一个不满足before的节点之前的节点, 也就是最后一个满足before的节点
+// 找不到时返回&l.head; 同时返回它的排名(从1开始, head为0)
+func (l *SkipList[K, V]) findLess(before func(n *node[K, V]) bool) (*node[K, V], int) {
+	x, rank := &l.head, 0
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && before(x.next[i]) {
+			rank += x.span[i]
+			x = x.next[i]
+		}
+	}
+	return x, rank
+}
+
+// lower 返回第一个key>=key的节点, 没有时返回nil
+func (l *SkipList[K, V]) lower(key K) *node[K, V] {
+	x, _ := l.findLess(func(n *node[K, V]) bool { return l.compare(n.key, key) < 0 })
+	return x.next[0]
+}
+
+// upper 返回最后一个key<=key的节点, 没有时返回nil
+func (l *SkipList[K, V]) upper(key K) *node[K, V] {
+	x, _ := l.findLess(func(n *node[K, V]) bool { return l.compare(n.key, key) <= 0 })
+	if x == &l.head {
+		return nil
+	}
+	return x
+}
+
+// Get 返回key对应的值
+func (l *SkipList[K, V]) Get(key K) (value V, ok bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if n := l.lower(key); n != nil && l.compare(n.key, key) == 0 {
+		return n.value, true
+	}
+	return value, false
+}
+
+// Set 把key的值设为value, 返回key原来是否存在
+func (l *SkipList[K, V]) Set(key K, value V) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var update [maxLevel]*node[K, V]
+	var rank [maxLevel]int
+	x := &l.head
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.next[i] != nil && l.compare(x.next[i].key, key) < 0 {
+			rank[i] += x.span[i]
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	if n := x.next[0]; n != nil && l.compare(n.key, key) == 0 {
+		n.value = value
+		return true
+	}
+
+	lvl := l.randomLevel()
+	if lvl > l.level {
+		for i := l.level; i < lvl; i++ {
+			rank[i] = 0
+			update[i] = &l.head
+			l.head.span[i] = l.length + 1
+		}
+		l.level = lvl
+	}
+	n := &node[K, V]{
+		key:   key,
+		value: value,
+		next:  make([]*node[K, V], lvl),
+		span:  make([]int, lvl),
+	}
+	for i := 0; i < lvl; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+		// update[i]到n跨过rank[0]-rank[i]+1个节点, 原来的span被分成两段
+		n.span[i] = update[i].span[i] - (rank[0] - rank[i])
+		update[i].span[i] = rank[0] - rank[i] + 1
+	}
+	for i := lvl; i < l.level; i++ {
+		update[i].span[i]++
+	}
+	if update[0] != &l.head {
+		n.prev = update[0]
+	}
+	if n.next[0] != nil {
+		n.next[0].prev = n
+	} else {
+		l.tail = n
+	}
+	l.length++
+	return false
+}
+
+// Delete 删除key, 返回它的值, key不存在时ok为false
+func (l *SkipList[K, V]) Delete(key K) (value V, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var update [maxLevel]*node[K, V]
+	x := &l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && l.compare(x.next[i].key, key) < 0 {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	x = x.next[0]
+	if x == nil || l.compare(x.key, key) != 0 {
+		return value, false
+	}
+	for i := 0; i < l.level; i++ {
+		if update[i].next[i] == x {
+			update[i].span[i] += x.span[i] - 1
+			update[i].next[i] = x.next[i]
+		} else {
+			update[i].span[i]--
+		}
+	}
+	if x.next[0] != nil {
+		x.next[0].prev = x.prev
+	} else {
+		l.tail = x.prev
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.length--
+	// x自己的指针保持不变, 停在x上的迭代器还能继续走
+	x.deleted = true
+	return x.value, true
+}
+
+// At 返回排第i的元素(从0开始), i超出范围时ok为false, 复杂度为O(log(n))
+func (l *SkipList[K, V]) At(i int) (key K, value V, ok bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if i < 0 || i >= l.length {
+		return key, value, false
+	}
+	x, traversed := &l.head, 0
+	for lvl := l.level - 1; lvl >= 0; lvl-- {
+		for x.next[lvl] != nil && traversed+x.span[lvl] <= i+1 {
+			traversed += x.span[lvl]
+			x = x.next[lvl]
+		}
+		if traversed == i+1 {
+			break
+		}
+	}
+	return x.key, x.value, true
+}
+
+// Index 返回key的排名(从0开始), key不存在时ok为false, 复杂度为O(log(n))
+func (l *SkipList[K, V]) Index(key K) (i int, ok bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	x, rank := l.findLess(func(n *node[K, V]) bool { return l.compare(n.key, key) <= 0 })
+	if x == &l.head || l.compare(x.key, key) != 0 {
+		return 0, false
+	}
+	return rank - 1, true
+}
+
+// Iterator 指向SkipList里的一个元素, 可以向前或者向后移动
+//
+// 迭代器不持有锁, 移动时才加读锁, 所以遍历时可以修改SkipList;
+// 此时迭代器是弱一致的: 当前元素被删除以后还能继续移动, 但是不一定能看到新加的元素
+type Iterator[K any, V any] struct {
+	l *SkipList[K, V]
+	n *node[K, V]
+}
+
+// Seek 返回指向第一个key>=key的元素的迭代器
+func (l *SkipList[K, V]) Seek(key K) *Iterator[K, V] {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return &Iterator[K, V]{l, l.lower(key)}
+}
+
+// SeekLE 返回指向最后一个key<=key的元素的迭代器, 用来从key开始向后遍历
+func (l *SkipList[K, V]) SeekLE(key K) *Iterator[K, V] {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return &Iterator[K, V]{l, l.upper(key)}
+}
+
+// First 返回指向最小的元素的迭代器
+func (l *SkipList[K, V]) First() *Iterator[K, V] {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return &Iterator[K, V]{l, l.head.next[0]}
+}
+
+// Last 返回指向最大的元素的迭代器
+func (l *SkipList[K, V]) Last() *Iterator[K, V] {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return &Iterator[K, V]{l, l.tail}
+}
+
+// Valid 判断迭代器是否指向一个元素, 走过两端以后为false
+func (it *Iterator[K, V]) Valid() bool {
+	return it.n != nil
+}
+
+// Key 返回当前元素的key, 迭代器必须是Valid的
+func (it *Iterator[K, V]) Key() K {
+	return it.n.key
+}
+
+// Value 返回当前元素的值, 迭代器必须是Valid的
+func (it *Iterator[K, V]) Value() V {
+	it.l.mu.RLock()
+	defer it.l.mu.RUnlock()
+	return it.n.value
+}
+
+// Next 移到下一个元素
+func (it *Iterator[K, V]) Next() {
+	it.l.mu.RLock()
+	defer it.l.mu.RUnlock()
+	n := it.n.next[0]
+	for n != nil && n.deleted {
+		n = n.next[0]
+	}
+	it.n = n
+}
+
+// Prev 移到上一个元素
+func (it *Iterator[K, V]) Prev() {
+	it.l.mu.RLock()
+	defer it.l.mu.RUnlock()
+	n := it.n.prev
+	for n != nil && n.deleted {
+		n = n.prev
+	}
+	it.n = n
+}
+
+// All 返回按key从小到大遍历所有元素的迭代器, 遍历时可以修改SkipList
+func (l *SkipList[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for it := l.First(); it.Valid(); it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Backward 返回按key从大到小遍历所有元素的迭代器, 遍历时可以修改SkipList
+func (l *SkipList[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for it := l.Last(); it.Valid(); it.Prev() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Range 返回按key从小到大遍历lo<=key<hi的元素的迭代器
+func (l *SkipList[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for it := l.Seek(lo); it.Valid() && l.compare(it.Key(), hi) < 0; it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// RangeDesc 返回按key从大到小遍历lo<key<=hi的元素的迭代器
+func (l *SkipList[K, V]) RangeDesc(hi, lo K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for it := l.SeekLE(hi); it.Valid() && l.compare(it.Key(), lo) > 0; it.Prev() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package skiplist
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// verify 检查每一层都是有序的, span之和等于长度, 并且At, Index和模型一致
+func (l *SkipList[K, V]) verify(t *testing.T, model []K) {
+	t.Helper()
+	if l.Len() != len(model) {
+		t.Fatalf("Len = %d, want %d", l.Len(), len(model))
+	}
+	for i := 0; i < l.level; i++ {
+		total := 0
+		for x := &l.head; x != nil; x = x.next[i] {
+			total += x.span[i]
+			if n := x.next[i]; n != nil && x != &l.head && l.compare(x.key, n.key) >= 0 {
+				t.Fatalf("level %d out of order", i)
+			}
+		}
+		if total != l.length+1 {
+			t.Fatalf("level %d spans sum to %d, want %d", i, total, l.length+1)
+		}
+	}
+	for i, k := range model {
+		if got, _, ok := l.At(i); !ok || l.compare(got, k) != 0 {
+			t.Fatalf("At(%d) = %v, %v; want %v", i, got, ok, k)
+		}
+		if got, ok := l.Index(k); !ok || got != i {
+			t.Fatalf("Index(%v) = %d, %v; want %d", k, got, ok, i)
+		}
+	}
+	if _, _, ok := l.At(len(model)); ok {
+		t.Fatal("At(Len) succeeded")
+	}
+	var keys []K
+	for k := range l.All() {
+		keys = append(keys, k)
+	}
+	var back []K
+	for k := range l.Backward() {
+		back = append(back, k)
+	}
+	slices.Reverse(back)
+	equal := func(a, b []K) bool {
+		return slices.EqualFunc(a, b, func(x, y K) bool { return l.compare(x, y) == 0 })
+	}
+	if !equal(keys, model) || !equal(back, model) {
+		t.Fatalf("All = %v, Backward reversed = %v; want %v", keys, back, model)
+	}
+}
+
+func TestSkipListRandomOps(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	l := New[int, string]()
+	l.SetSeed(1)
+	model := map[int]bool{}
+	for i := 0; i < 3000; i++ {
+		k := r.Intn(500)
+		if r.Intn(3) > 0 {
+			if existed := l.Set(k, "v"); existed != model[k] {
+				t.Fatalf("Set(%d) = %v, want %v", k, existed, model[k])
+			}
+			model[k] = true
+		} else {
+			if _, ok := l.Delete(k); ok != model[k] {
+				t.Fatalf("Delete(%d) = %v, want %v", k, ok, model[k])
+			}
+			delete(model, k)
+		}
+		if i%100 == 0 {
+			var keys []int
+			for k := range model {
+				keys = append(keys, k)
+			}
+			sort.Ints(keys)
+			l.verify(t, keys)
+		}
+	}
+}
+
+func TestSkipListGetSet(t *testing.T) {
+	l := New[string, int]()
+	if _, ok := l.Get("a"); ok {
+		t.Error("Get on empty list succeeded")
+	}
+	l.Set("b", 2)
+	l.Set("a", 1)
+	l.Set("b", 20)
+	if v, ok := l.Get("b"); !ok || v != 20 {
+		t.Errorf("Get(b) = %d, %v; want 20", v, ok)
+	}
+	if _, ok := l.Index("c"); ok {
+		t.Error("Index of missing key succeeded")
+	}
+	if v, ok := l.Delete("a"); !ok || v != 1 {
+		t.Errorf("Delete(a) = %d, %v", v, ok)
+	}
+	l.verify(t, []string{"b"})
+}
+
+func collect(seq func(func(int, int) bool)) []int {
+	var keys []int
+	for k := range seq {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestSkipListSeekAndRange(t *testing.T) {
+	l := New[int, int]()
+	for k := 10; k <= 50; k += 10 {
+		l.Set(k, k*k)
+	}
+	tests := []struct {
+		key      int
+		seek, le int // 0表示没有
+	}{
+		{5, 10, 0},
+		{10, 10, 10},
+		{25, 30, 20},
+		{50, 50, 50},
+		{55, 0, 50},
+	}
+	for _, tt := range tests {
+		it := l.Seek(tt.key)
+		if got := 0; it.Valid() {
+			got = it.Key()
+			if got != tt.seek || it.Value() != got*got {
+				t.Errorf("Seek(%d) = %d", tt.key, got)
+			}
+		} else if tt.seek != 0 {
+			t.Errorf("Seek(%d) invalid, want %d", tt.key, tt.seek)
+		}
+		it = l.SeekLE(tt.key)
+		if it.Valid() != (tt.le != 0) || it.Valid() && it.Key() != tt.le {
+			t.Errorf("SeekLE(%d) wrong, want %d", tt.key, tt.le)
+		}
+	}
+
+	if got := collect(l.Range(15, 40)); !slices.Equal(got, []int{20, 30}) {
+		t.Errorf("Range(15, 40) = %v", got)
+	}
+	if got := collect(l.Range(10, 50)); !slices.Equal(got, []int{10, 20, 30, 40}) {
+		t.Errorf("Range(10, 50) = %v", got)
+	}
+	if got := collect(l.RangeDesc(40, 10)); !slices.Equal(got, []int{40, 30, 20}) {
+		t.Errorf("RangeDesc(40, 10) = %v", got)
+	}
+	if got := collect(l.RangeDesc(100, 0)); !slices.Equal(got, []int{50, 40, 30, 20, 10}) {
+		t.Errorf("RangeDesc(100, 0) = %v", got)
+	}
+
+	// 双向移动
+	it := l.Seek(30)
+	it.Prev()
+	it.Prev()
+	if it.Key() != 10 {
+		t.Errorf("after two Prev: %d, want 10", it.Key())
+	}
+	it.Prev()
+	if it.Valid() {
+		t.Error("Prev past the first element is still valid")
+	}
+}
+
+// 遍历时删除元素, 包括迭代器当前所在的元素与它后面的元素
+func TestSkipListModifyDuringIteration(t *testing.T) {
+	l := New[int, int]()
+	for k := 0; k < 10; k++ {
+		l.Set(k, k)
+	}
+	var got []int
+	for k := range l.All() {
+		got = append(got, k)
+		l.Delete(k)
+		l.Delete(k + 1)
+	}
+	if !slices.Equal(got, []int{0, 2, 4, 6, 8}) || l.Len() != 0 {
+		t.Errorf("visited %v, Len = %d", got, l.Len())
+	}
+
+	for k := 0; k < 10; k++ {
+		l.Set(k, k)
+	}
+	it := l.Seek(5)
+	l.Delete(5)
+	l.Delete(4)
+	it.Prev()
+	if it.Key() != 3 {
+		t.Errorf("Prev from deleted element = %d, want 3", it.Key())
+	}
+}
+
+func TestSkipListSeed(t *testing.T) {
+	levels := func() []int {
+		l := New[int, int]()
+		l.SetSeed(42)
+		for k := 0; k < 100; k++ {
+			l.Set(k, k)
+		}
+		var lv []int
+		for x := l.head.next[0]; x != nil; x = x.next[0] {
+			lv = append(lv, len(x.next))
+		}
+		return lv
+	}
+	if a, b := levels(), levels(); !slices.Equal(a, b) {
+		t.Error("same seed produced different structure")
+	}
+}
+
+func TestSkipListConcurrentReaders(t *testing.T) {
+	l := New[int, int]()
+	for k := 0; k < 1000; k++ {
+		l.Set(k, k)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if v, ok := l.Get(i); ok && v != i {
+					t.Errorf("Get(%d) = %d", i, v)
+				}
+				l.At(i)
+				for range l.Range(i, i+5) {
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i += 2 {
+			l.Delete(i)
+			l.Set(i+1000, i+1000)
+		}
+	}()
+	wg.Wait()
+	if l.Len() != 1000 {
+		t.Errorf("Len = %d, want 1000", l.Len())
+	}
+}
+
+func BenchmarkSkipListSet(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	l := New[int, int]()
+	for i := 0; i < b.N; i++ {
+		l.Set(r.Int(), i)
+	}
+}
+
+func BenchmarkSkipListGet(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	l := New[int, int]()
+	for i := 0; i < 100000; i++ {
+		l.Set(i, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Get(r.Intn(100000))
+	}
+}
@@ -0,0 +1,329 @@
+// Package csvdriver 是一个只读的数据库驱动, 用于直接查询导出的CSV/TSV文件
+//
+// 注册的名字为csv, DSN是一个目录:
+//
+//	db, err := sql.Open("csv", "/path/to/exports")
+//	rows, err := db.Query("SELECT name, age FROM users WHERE age >= ? LIMIT 10", 18)
+//
+// 目录下的每个.csv与.tsv文件是一张表, 表名是去掉扩展名的文件名,
+// 第一行是表头, 作为列名
+//
+// 只支持下面这种形式的查询:
+//
+//	SELECT * | col [, col ...] FROM table
+//		[WHERE col op value [AND col op value ...]]
+//		[LIMIT n]
+//
+// op可以是 = != <> < <= > >=, value可以是'字符串', 数字或者占位符?
+// 两边都能解析为数字时按数字比较, 否则按字符串比较
+// 文件是一行一行读的, 不会整个读到内存里
+package csvdriver
+
+import (
+	"bufio"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	sql.Register("csv", &Driver{})
+}
+
+// ErrReadOnly 是执行Exec或者开启事务时返回的错误
+var ErrReadOnly = errors.New("csvdriver: read-only driver")
+
+// Driver 实现了driver.Driver
+type Driver struct{}
+
+// Open 的name是CSV文件所在的目录
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("csvdriver: %s is not a directory", name)
+	}
+	return &conn{dir: name}, nil
+}
+
+type conn struct {
+	dir string
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	q, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{c: c, q: q}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) { return nil, ErrReadOnly }
+
+// tablePath 查找表对应的文件, .csv优先
+func (c *conn) tablePath(table string) (path string, comma rune, err error) {
+	if strings.ContainsAny(table, `/\`) {
+		return "", 0, fmt.Errorf("csvdriver: invalid table name %q", table)
+	}
+	for _, ext := range []struct {
+		ext   string
+		comma rune
+	}{{".csv", ','}, {".tsv", '\t'}} {
+		path = filepath.Join(c.dir, table+ext.ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, ext.comma, nil
+		}
+	}
+	return "", 0, fmt.Errorf("csvdriver: no such table: %s", table)
+}
+
+type stmt struct {
+	c *conn
+	q *query
+}
+
+func (s *stmt) Close() error { return nil }
+
+func (s *stmt) NumInput() int { return s.q.numInput }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, ErrReadOnly
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	path, comma, err := s.c.tablePath(s.q.table)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var r recordReader
+	if comma == '\t' {
+		r = &tsvReader{r: bufio.NewReader(f)}
+	} else {
+		cr := csv.NewReader(bufio.NewReader(f))
+		cr.FieldsPerRecord = -1
+		r = cr
+	}
+
+	header, err := r.Read()
+	if err == io.EOF {
+		f.Close()
+		return nil, fmt.Errorf("csvdriver: table %s has no header", s.q.table)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	rs := &rows{f: f, r: r, limit: s.q.limit}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // Excel导出的文件带BOM
+		}
+		if name == "" {
+			name = "col" + strconv.Itoa(i+1)
+		}
+		if _, dup := index[name]; dup {
+			f.Close()
+			return nil, fmt.Errorf("csvdriver: table %s has duplicate column %q", s.q.table, name)
+		}
+		index[name] = i
+		header[i] = name
+	}
+
+	if s.q.columns == nil {
+		rs.cols = header
+		rs.proj = make([]int, len(header))
+		for i := range header {
+			rs.proj[i] = i
+		}
+	} else {
+		rs.cols = s.q.columns
+		rs.proj = make([]int, len(s.q.columns))
+		for i, name := range s.q.columns {
+			j, ok := index[name]
+			if !ok {
+				f.Close()
+				return nil, fmt.Errorf("csvdriver: no such column: %s", name)
+			}
+			rs.proj[i] = j
+		}
+	}
+
+	for _, p := range s.q.where {
+		j, ok := index[p.column]
+		if !ok {
+			f.Close()
+			return nil, fmt.Errorf("csvdriver: no such column: %s", p.column)
+		}
+		v := p.value
+		if p.arg > 0 {
+			if p.arg > len(args) {
+				f.Close()
+				return nil, fmt.Errorf("csvdriver: missing argument %d", p.arg)
+			}
+			if args[p.arg-1] == nil {
+				// 和NULL比较在SQL里永远不成立, 当作""比较只会得到错误的结果
+				f.Close()
+				return nil, fmt.Errorf("csvdriver: argument %d is NULL, comparing with NULL is not supported", p.arg)
+			}
+			v = argString(args[p.arg-1])
+		}
+		rs.where = append(rs.where, boundPredicate{index: j, op: p.op, value: v})
+	}
+	return rs, nil
+}
+
+func argString(v driver.Value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
+
+type boundPredicate struct {
+	index int
+	op    string
+	value string
+}
+
+func (p boundPredicate) match(record []string) bool {
+	var field string
+	if p.index < len(record) {
+		field = record[p.index]
+	}
+	c := compare(field, p.value)
+	switch p.op {
+	case "=":
+		return c == 0
+	case "!=", "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// compare 两边都是数字就按数字比较
+func compare(a, b string) int {
+	fa, erra := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	fb, errb := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if erra == nil && errb == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// recordReader 每次读一行记录, 读完返回io.EOF
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// tsvReader 按制表符切分每一行
+// TSV一般不加引号, 所以不能用csv.Reader, 它会把开头的引号当作引用
+type tsvReader struct {
+	r *bufio.Reader
+}
+
+func (t *tsvReader) Read() ([]string, error) {
+	for {
+		line, err := t.r.ReadString('\n')
+		if err == io.EOF && line != "" {
+			// 最后一行没有换行符
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			// 和csv.Reader一样跳过空行
+			continue
+		}
+		return strings.Split(line, "\t"), nil
+	}
+}
+
+// rows 每次Next从文件里读一行, 直到找到满足WHERE的行
+type rows struct {
+	f     *os.File
+	r     recordReader
+	cols  []string
+	proj  []int // 输出的第i列对应记录里的第proj[i]个字段
+	where []boundPredicate
+	limit int // <0表示没有LIMIT
+	n     int // 已经返回的行数
+}
+
+func (rs *rows) Columns() []string { return rs.cols }
+
+func (rs *rows) Close() error {
+	if rs.f == nil {
+		return nil
+	}
+	err := rs.f.Close()
+	rs.f = nil
+	return err
+}
+
+func (rs *rows) Next(dest []driver.Value) error {
+	if rs.f == nil {
+		return io.EOF
+	}
+	if rs.limit >= 0 && rs.n >= rs.limit {
+		return io.EOF
+	}
+next:
+	for {
+		record, err := rs.r.Read()
+		if err != nil {
+			return err
+		}
+		for _, p := range rs.where {
+			if !p.match(record) {
+				continue next
+			}
+		}
+		for i, j := range rs.proj {
+			if j < len(record) {
+				dest[i] = record[j]
+			} else {
+				// 短行缺少的字段当作NULL
+				dest[i] = nil
+			}
+		}
+		rs.n++
+		return nil
+	}
+}
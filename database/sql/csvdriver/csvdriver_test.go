@@ -0,0 +1,172 @@
+package csvdriver
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func openTestDB(t *testing.T, files map[string]string) *sql.DB {
+	dir, err := ioutil.TempDir("", "csvdriver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := sql.Open("csv", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+	return db
+}
+
+// queryAll 把结果集里的每一行拼成a|b|c的形式, NULL写作<nil>
+func queryAll(t *testing.T, db *sql.DB, query string, args ...interface{}) []string {
+	t.Helper()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatalf("Query(%q): %v", query, err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for rows.Next() {
+		vals := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range vals {
+			dest[i] = &vals[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+		fields := make([]string, len(vals))
+		for i, v := range vals {
+			if v.Valid {
+				fields[i] = v.String
+			} else {
+				fields[i] = "<nil>"
+			}
+		}
+		out = append(out, strings.Join(fields, "|"))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+var users = "id,name,age\n1,alice,30\n2,bob,9\n3,\"carol, jr\",100\n4,dave\n"
+
+func TestSelect(t *testing.T) {
+	db := openTestDB(t, map[string]string{"users.csv": users})
+	tests := []struct {
+		query string
+		args  []interface{}
+		want  []string
+	}{
+		{"SELECT * FROM users", nil, []string{"1|alice|30", "2|bob|9", "3|carol, jr|100", "4|dave|<nil>"}},
+		{"SELECT name, id FROM users", nil, []string{"alice|1", "bob|2", "carol, jr|3", "dave|4"}},
+		// 数字按数值比较, 9 < 30 < 100
+		{"SELECT name FROM users WHERE age > 10", nil, []string{"alice", "carol, jr"}},
+		{"SELECT name FROM users WHERE age >= ? AND age <= ?", []interface{}{9, 30}, []string{"alice", "bob"}},
+		// 字符串按字典序比较
+		{"SELECT name FROM users WHERE name < 'c'", nil, []string{"alice", "bob"}},
+		{"SELECT id FROM users WHERE name = ?", []interface{}{"carol, jr"}, []string{"3"}},
+		{"SELECT id FROM users WHERE name != 'bob' AND name <> 'dave'", nil, []string{"1", "3"}},
+		// 短行缺少的字段按""比较
+		{"SELECT id FROM users WHERE age = ''", nil, []string{"4"}},
+		{"SELECT id FROM users LIMIT 2", nil, []string{"1", "2"}},
+		{"SELECT id FROM users WHERE age > 10 LIMIT 1", nil, []string{"1"}},
+		{"SELECT id FROM users LIMIT 0", nil, nil},
+	}
+	for _, tt := range tests {
+		got := queryAll(t, db, tt.query, tt.args...)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %v = %q; want %q", tt.query, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestTSVAndBOM(t *testing.T) {
+	db := openTestDB(t, map[string]string{
+		// TSV里的引号是普通字符, 最后一行没有换行符, 空行跳过
+		"notes.tsv": "\ufeffid\ttext\r\n1\t\"quoted\r\n\n2\ta,b\r\n3\tlast",
+		"bom.csv":   "\ufeffid,name\n7,x\n",
+	})
+	got := queryAll(t, db, "SELECT id, text FROM notes")
+	want := []string{`1|"quoted`, "2|a,b", "3|last"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("notes = %q; want %q", got, want)
+	}
+	got = queryAll(t, db, "SELECT id FROM bom WHERE id = 7")
+	if !reflect.DeepEqual(got, []string{"7"}) {
+		t.Errorf("bom = %q; want [7]", got)
+	}
+}
+
+// 同名的.csv优先于.tsv
+func TestCSVBeforeTSV(t *testing.T) {
+	db := openTestDB(t, map[string]string{
+		"t.csv": "a\ncsv\n",
+		"t.tsv": "a\ntsv\n",
+	})
+	if got := queryAll(t, db, "SELECT a FROM t"); !reflect.DeepEqual(got, []string{"csv"}) {
+		t.Errorf("SELECT a FROM t = %q; want [csv]", got)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	db := openTestDB(t, map[string]string{
+		"users.csv": users,
+		"dup.csv":   "a,a\n1,2\n",
+		"empty.csv": "",
+	})
+	tests := []struct {
+		query string
+		args  []interface{}
+		err   string
+	}{
+		{"SELECT * FROM missing", nil, "no such table: missing"},
+		{"SELECT nope FROM users", nil, "no such column: nope"},
+		{"SELECT * FROM users WHERE nope = 1", nil, "no such column: nope"},
+		{"SELECT * FROM dup", nil, `duplicate column "a"`},
+		{"SELECT * FROM empty", nil, "has no header"},
+		{"SELECT * FROM users WHERE age == 1", nil, "unsupported operator =="},
+		{"SELECT * FROM users WHERE name = ?", []interface{}{nil}, "argument 1 is NULL"},
+	}
+	for _, tt := range tests {
+		rows, err := db.Query(tt.query, tt.args...)
+		if err == nil {
+			rows.Close()
+			t.Errorf("Query(%q) succeeded; want error containing %q", tt.query, tt.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Query(%q) error = %q; want it to contain %q", tt.query, err, tt.err)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	db := openTestDB(t, map[string]string{"users.csv": users})
+	if _, err := db.Exec("SELECT * FROM users"); err != ErrReadOnly {
+		t.Errorf("Exec error = %v; want ErrReadOnly", err)
+	}
+	if _, err := db.Begin(); err != ErrReadOnly {
+		t.Errorf("Begin error = %v; want ErrReadOnly", err)
+	}
+}
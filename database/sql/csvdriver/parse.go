@@ -0,0 +1,286 @@
+package csvdriver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// query 是解析后的SELECT语句
+type query struct {
+	table    string
+	columns  []string // nil表示SELECT *
+	where    []predicate
+	limit    int // <0表示没有LIMIT
+	numInput int
+}
+
+// predicate 是WHERE里的一个条件, arg>0表示值来自第arg个占位符
+type predicate struct {
+	column string
+	op     string
+	value  string
+	arg    int
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokComma
+	tokStar
+	tokPlaceholder
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lexer 是一个很简单的词法分析器, 足够应付上面的语法
+type lexer struct {
+	s   string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.s) && unicode.IsSpace(rune(l.s[l.pos])) {
+		l.pos++
+	}
+	if l.pos >= len(l.s) {
+		return token{kind: tokEOF}, nil
+	}
+	start := l.pos
+	c := l.s[l.pos]
+	switch {
+	case c == ',':
+		l.pos++
+		return token{tokComma, ","}, nil
+	case c == '*':
+		l.pos++
+		return token{tokStar, "*"}, nil
+	case c == '?':
+		l.pos++
+		return token{tokPlaceholder, "?"}, nil
+	case c == ';':
+		// 末尾的分号忽略
+		l.pos++
+		return l.next()
+	case c == '=' || c == '<' || c == '>' || c == '!':
+		l.pos++
+		if l.pos < len(l.s) && (l.s[l.pos] == '=' || c == '<' && l.s[l.pos] == '>') {
+			l.pos++
+		}
+		op := l.s[start:l.pos]
+		if op == "!" {
+			return token{}, fmt.Errorf("csvdriver: unexpected %q at offset %d", op, start)
+		}
+		return token{tokOp, op}, nil
+	case c == '\'' || c == '"':
+		// '...'是字符串, "..."是带引号的列名, 两个连续的引号表示引号本身
+		var buf []byte
+		l.pos++
+		for {
+			if l.pos >= len(l.s) {
+				return token{}, fmt.Errorf("csvdriver: unterminated quote at offset %d", start)
+			}
+			if l.s[l.pos] == c {
+				if l.pos+1 < len(l.s) && l.s[l.pos+1] == c {
+					buf = append(buf, c)
+					l.pos += 2
+					continue
+				}
+				l.pos++
+				break
+			}
+			buf = append(buf, l.s[l.pos])
+			l.pos++
+		}
+		if c == '"' {
+			return token{tokIdent, string(buf)}, nil
+		}
+		return token{tokString, string(buf)}, nil
+	case c == '-' || c == '.' || c >= '0' && c <= '9':
+		l.pos++
+		for l.pos < len(l.s) && strings.IndexByte("0123456789.eE+-", l.s[l.pos]) >= 0 {
+			l.pos++
+		}
+		text := l.s[start:l.pos]
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return token{}, fmt.Errorf("csvdriver: bad number %q at offset %d", text, start)
+		}
+		return token{tokNumber, text}, nil
+	}
+	// 标识符可以是中文之类的非ASCII字符, 需要按rune处理
+	for l.pos < len(l.s) {
+		r, size := utf8.DecodeRuneInString(l.s[l.pos:])
+		if r != '_' && !unicode.IsLetter(r) && (l.pos == start || !unicode.IsDigit(r)) {
+			break
+		}
+		l.pos += size
+	}
+	if l.pos > start {
+		return token{tokIdent, l.s[start:l.pos]}, nil
+	}
+	return token{}, fmt.Errorf("csvdriver: unexpected %q at offset %d", c, start)
+}
+
+// parser 在lexer上多看一个token
+type parser struct {
+	l   lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.l.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	return p.tok.kind == tokIdent && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.isKeyword(kw) {
+		return p.errorf("expected %s", kw)
+	}
+	return p.advance()
+}
+
+func (p *parser) ident() (string, error) {
+	if p.tok.kind != tokIdent {
+		return "", p.errorf("expected identifier")
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	near := p.tok.text
+	if p.tok.kind == tokEOF {
+		near = "end of query"
+	}
+	return fmt.Errorf("csvdriver: "+format+" near %q", append(args, near)...)
+}
+
+func parse(s string) (*query, error) {
+	p := &parser{l: lexer{s: s}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	q := &query{limit: -1}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokStar {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	} else {
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			q.columns = append(q.columns, name)
+			if p.tok.kind != tokComma {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	table, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	q.table = table
+
+	if p.isKeyword("WHERE") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for {
+			pred, err := p.predicate(q)
+			if err != nil {
+				return nil, err
+			}
+			q.where = append(q.where, pred)
+			if !p.isKeyword("AND") {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if p.isKeyword("LIMIT") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokNumber {
+			return nil, p.errorf("expected number after LIMIT")
+		}
+		n, err := strconv.Atoi(p.tok.text)
+		if err != nil || n < 0 {
+			return nil, p.errorf("bad LIMIT")
+		}
+		q.limit = n
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return q, nil
+}
+
+func (p *parser) predicate(q *query) (predicate, error) {
+	var pred predicate
+	col, err := p.ident()
+	if err != nil {
+		return pred, err
+	}
+	pred.column = col
+	if p.tok.kind != tokOp {
+		return pred, p.errorf("expected comparison operator")
+	}
+	switch pred.op = p.tok.text; pred.op {
+	case "=", "!=", "<>", "<", "<=", ">", ">=":
+	default:
+		// lexer会把==当作一个token, 但它不是SQL的运算符
+		return pred, p.errorf("unsupported operator %s", pred.op)
+	}
+	if err := p.advance(); err != nil {
+		return pred, err
+	}
+	switch p.tok.kind {
+	case tokString, tokNumber:
+		pred.value = p.tok.text
+	case tokPlaceholder:
+		q.numInput++
+		pred.arg = q.numInput
+	default:
+		return pred, p.errorf("expected value")
+	}
+	return pred, p.advance()
+}
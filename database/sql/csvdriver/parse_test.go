@@ -0,0 +1,86 @@
+package csvdriver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want query
+	}{
+		{"SELECT * FROM users", query{table: "users", limit: -1}},
+		{"select name, age from users;", query{table: "users", columns: []string{"name", "age"}, limit: -1}},
+		{
+			`SELECT "first name" FROM "my table" WHERE age >= 18 AND name <> 'O''Brien' LIMIT 5`,
+			query{
+				table:   "my table",
+				columns: []string{"first name"},
+				where: []predicate{
+					{column: "age", op: ">=", value: "18"},
+					{column: "name", op: "<>", value: "O'Brien"},
+				},
+				limit: 5,
+			},
+		},
+		{
+			"SELECT 名字 FROM 用户 WHERE a = ? AND b != ? AND c < -1.5e3",
+			query{
+				table:   "用户",
+				columns: []string{"名字"},
+				where: []predicate{
+					{column: "a", op: "=", arg: 1},
+					{column: "b", op: "!=", arg: 2},
+					{column: "c", op: "<", value: "-1.5e3"},
+				},
+				limit:    -1,
+				numInput: 2,
+			},
+		},
+		{"SELECT * FROM t LIMIT 0", query{table: "t", limit: 0}},
+	}
+	for _, tt := range tests {
+		q, err := parse(tt.in)
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(*q, tt.want) {
+			t.Errorf("parse(%q) = %+v; want %+v", tt.in, *q, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{"", "expected SELECT"},
+		{"DELETE FROM t", "expected SELECT"},
+		{"SELECT , FROM t", "expected identifier"},
+		{"SELECT FROM t", "expected FROM"},
+		{"SELECT * t", "expected FROM"},
+		{"SELECT * FROM t WHERE a == 1", "unsupported operator =="},
+		{"SELECT * FROM t WHERE a ! 1", `unexpected "!"`},
+		{"SELECT * FROM t WHERE a = b", "expected value"},
+		{"SELECT * FROM t WHERE a 1", "expected comparison operator"},
+		{"SELECT * FROM t WHERE a = 'x", "unterminated quote"},
+		{"SELECT * FROM t LIMIT x", "expected number after LIMIT"},
+		{"SELECT * FROM t LIMIT -1", "bad LIMIT"},
+		{"SELECT * FROM t LIMIT 1.5", "bad LIMIT"},
+		{"SELECT * FROM t ORDER BY a", "unexpected token"},
+		{"SELECT * FROM t WHERE a = 1..2", "bad number"},
+	}
+	for _, tt := range tests {
+		_, err := parse(tt.in)
+		if err == nil {
+			t.Errorf("parse(%q) succeeded; want error containing %q", tt.in, tt.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parse(%q) error = %q; want it to contain %q", tt.in, err, tt.err)
+		}
+	}
+}
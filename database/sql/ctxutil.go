@@ -0,0 +1,206 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// 这个文件里是调用驱动的辅助函数
+// 驱动实现了带Context的接口就直接调用, 否则通过ctxShim调用老的接口
+// 所有的函数都会自己持有dc的锁, 调用者不要再锁
+
+// ctxShim 在一个新的goroutine里执行不支持context的调用fn
+// ctx先结束时立即返回ctx.Err(), 此时fn还在运行并且持有连接的锁,
+// fn返回后把它的结果交给cleanup释放(比如关闭Rows, 回滚Tx)
+// 调用者看到ctx.Err()时必须把连接当作坏连接, 见DB.releaseCtx
+func ctxShim(ctx context.Context, fn func() (interface{}, error), cleanup func(interface{})) (interface{}, error) {
+	if ctx.Done() == nil {
+		// context.Background()之类的永远不会被取消, 不用再起goroutine
+		return fn()
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	type result struct {
+		v   interface{}
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		ch <- result{v, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.v, r.err
+	case <-ctx.Done():
+		go func() {
+			r := <-ch
+			if r.err == nil && r.v != nil && cleanup != nil {
+				cleanup(r.v)
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func ctxDriverExec(ctx context.Context, dc *driverConn, query string, nvdargs []driver.NamedValue) (driver.Result, error) {
+	if execerCtx, ok := dc.ci.(driver.ExecerContext); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return execerCtx.ExecContext(ctx, query, nvdargs)
+	}
+	execer, ok := dc.ci.(driver.Execer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	dargs, err := namedValueToValue(nvdargs)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ctxShim(ctx, func() (interface{}, error) {
+		dc.Lock()
+		defer dc.Unlock()
+		return execer.Exec(query, dargs)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	resi, _ := v.(driver.Result)
+	return resi, nil
+}
+
+func ctxDriverQuery(ctx context.Context, dc *driverConn, query string, nvdargs []driver.NamedValue) (driver.Rows, error) {
+	if queryerCtx, ok := dc.ci.(driver.QueryerContext); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return queryerCtx.QueryContext(ctx, query, nvdargs)
+	}
+	queryer, ok := dc.ci.(driver.Queryer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	dargs, err := namedValueToValue(nvdargs)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ctxShim(ctx, func() (interface{}, error) {
+		dc.Lock()
+		defer dc.Unlock()
+		return queryer.Query(query, dargs)
+	}, closeRows(dc))
+	if err != nil {
+		return nil, err
+	}
+	rowsi, _ := v.(driver.Rows)
+	return rowsi, nil
+}
+
+func ctxDriverStmtExec(ctx context.Context, dc *driverConn, si driver.Stmt, nvdargs []driver.NamedValue) (driver.Result, error) {
+	if siCtx, ok := si.(driver.StmtExecContext); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return siCtx.ExecContext(ctx, nvdargs)
+	}
+	dargs, err := namedValueToValue(nvdargs)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ctxShim(ctx, func() (interface{}, error) {
+		dc.Lock()
+		defer dc.Unlock()
+		return si.Exec(dargs)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	resi, _ := v.(driver.Result)
+	return resi, nil
+}
+
+func ctxDriverStmtQuery(ctx context.Context, dc *driverConn, si driver.Stmt, nvdargs []driver.NamedValue) (driver.Rows, error) {
+	if siCtx, ok := si.(driver.StmtQueryContext); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return siCtx.QueryContext(ctx, nvdargs)
+	}
+	dargs, err := namedValueToValue(nvdargs)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ctxShim(ctx, func() (interface{}, error) {
+		dc.Lock()
+		defer dc.Unlock()
+		return si.Query(dargs)
+	}, closeRows(dc))
+	if err != nil {
+		return nil, err
+	}
+	rowsi, _ := v.(driver.Rows)
+	return rowsi, nil
+}
+
+var errTxOptions = errors.New("sql: driver does not support non-default isolation level or read-only transactions")
+
+func ctxDriverBegin(ctx context.Context, dc *driverConn, opts driver.TxOptions) (driver.Tx, error) {
+	if ciCtx, ok := dc.ci.(driver.ConnBeginTx); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return ciCtx.BeginTx(ctx, opts)
+	}
+	// 老的Begin没法传选项, 只能拒绝
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errTxOptions
+	}
+	v, err := ctxShim(ctx, func() (interface{}, error) {
+		dc.Lock()
+		defer dc.Unlock()
+		return dc.ci.Begin()
+	}, func(v interface{}) {
+		dc.Lock()
+		defer dc.Unlock()
+		v.(driver.Tx).Rollback()
+	})
+	if err != nil {
+		return nil, err
+	}
+	txi, _ := v.(driver.Tx)
+	return txi, nil
+}
+
+// ctxDriverPing 驱动没有实现Pinger时, 能拿到连接就认为是通的
+func ctxDriverPing(ctx context.Context, dc *driverConn) error {
+	pinger, ok := dc.ci.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	dc.Lock()
+	defer dc.Unlock()
+	return pinger.Ping(ctx)
+}
+
+func closeRows(dc *driverConn) func(interface{}) {
+	return func(v interface{}) {
+		dc.Lock()
+		defer dc.Unlock()
+		v.(driver.Rows).Close()
+	}
+}
+
+// namedValueToValue 把NamedValue转回老接口用的Value
+// 老接口不支持命名参数
+func namedValueToValue(named []driver.NamedValue) ([]driver.Value, error) {
+	dargs := make([]driver.Value, len(named))
+	for n, param := range named {
+		if len(param.Name) > 0 {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		dargs[n] = param.Value
+	}
+	return dargs, nil
+}
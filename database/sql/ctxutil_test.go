@@ -0,0 +1,409 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ctxConn 实现了带Context的接口, 调用时不会经过ctxShim
+type ctxConn struct {
+	fakeConn
+
+	mu      sync.Mutex
+	queries []string
+	opts    []driver.TxOptions
+}
+
+func (c *ctxConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.mu.Lock()
+	c.queries = append(c.queries, query)
+	c.mu.Unlock()
+	return &fakeRows{cols: []string{"n"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}}, nil
+}
+
+func (c *ctxConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.mu.Lock()
+	c.opts = append(c.opts, opts)
+	c.mu.Unlock()
+	return fakeTx{}, nil
+}
+
+// blockConn 只实现了老的Queryer与Begin, 调用会一直阻塞到unblock被关闭
+type blockConn struct {
+	fakeConn
+	started chan struct{}
+	unblock chan struct{}
+	rows    *trackRows
+	tx      *trackTx
+}
+
+func (c *blockConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	c.started <- struct{}{}
+	<-c.unblock
+	return c.rows, nil
+}
+
+func (c *blockConn) Begin() (driver.Tx, error) {
+	c.started <- struct{}{}
+	<-c.unblock
+	return c.tx, nil
+}
+
+// trackRows 与trackTx在被ctxShim清理时关闭done
+type trackRows struct {
+	fakeRows
+	done chan struct{}
+}
+
+func (r *trackRows) Close() error {
+	close(r.done)
+	return nil
+}
+
+type trackTx struct {
+	done chan struct{}
+}
+
+func (tx *trackTx) Commit() error { return nil }
+
+func (tx *trackTx) Rollback() error {
+	close(tx.done)
+	return nil
+}
+
+// connDriver 每次Open都返回newConn创建的连接
+type connDriver struct {
+	fakeDriver
+	newConn func(fc fakeConn) driver.Conn
+}
+
+func (d *connDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	d.opened++
+	d.mu.Unlock()
+	return d.newConn(fakeConn{d: &d.fakeDriver}), nil
+}
+
+func newBlockDB() (*DB, *connDriver, *blockConn) {
+	bc := &blockConn{
+		started: make(chan struct{}),
+		unblock: make(chan struct{}),
+		rows:    &trackRows{fakeRows: fakeRows{cols: []string{"x"}}, done: make(chan struct{})},
+		tx:      &trackTx{done: make(chan struct{})},
+	}
+	d := &connDriver{newConn: func(fc fakeConn) driver.Conn {
+		bc.fakeConn = fc
+		return bc
+	}}
+	return newTestDB(d), d, bc
+}
+
+func waitClosed(t *testing.T, d *connDriver, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for d.numClosed() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("driver closed %d connections; want %d", d.numClosed(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitDone(t *testing.T, done chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s was not cleaned up", what)
+	}
+}
+
+func TestQueryContextNative(t *testing.T) {
+	var cc *ctxConn
+	db := newTestDB(&connDriver{newConn: func(fc fakeConn) driver.Conn {
+		cc = &ctxConn{fakeConn: fc}
+		return cc
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, "SELECT n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for rows.Next() {
+		var n int64
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, n)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("rows = %v; want [1 2]", got)
+	}
+	if len(cc.queries) != 1 || cc.queries[0] != "SELECT n" {
+		t.Errorf("QueryContext calls = %q", cc.queries)
+	}
+	// Next走到头时已经关闭了Rows, 连接应该回到了连接池
+	if n := len(db.freeConn); n != 1 {
+		t.Errorf("len(freeConn) = %d; want 1", n)
+	}
+}
+
+func TestBeginTxNative(t *testing.T) {
+	var cc *ctxConn
+	db := newTestDB(&connDriver{newConn: func(fc fakeConn) driver.Conn {
+		cc = &ctxConn{fakeConn: fc}
+		return cc
+	}})
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, &TxOptions{Isolation: LevelSerializable, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != ErrTxDone {
+		t.Errorf("Rollback after Commit = %v; want ErrTxDone", err)
+	}
+	want := driver.TxOptions{Isolation: driver.IsolationLevel(LevelSerializable), ReadOnly: true}
+	if len(cc.opts) != 1 || cc.opts[0] != want {
+		t.Errorf("BeginTx options = %+v; want %+v", cc.opts, want)
+	}
+	if n := len(db.freeConn); n != 1 {
+		t.Errorf("len(freeConn) = %d; want 1", n)
+	}
+
+	// Conn上的事务使用同一个连接, 结束后连接仍然属于Conn
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err = c.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := c.QueryContext(ctx, "SELECT n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cc.opts) != 2 || cc.opts[1] != (driver.TxOptions{}) {
+		t.Errorf("BeginTx options = %+v; want default options", cc.opts)
+	}
+	if len(cc.queries) != 1 {
+		t.Errorf("QueryContext calls = %q; want 1", cc.queries)
+	}
+}
+
+// 驱动既没有QueryerContext也没有Queryer时, 先Prepare再查询
+func TestQueryContextPrepare(t *testing.T) {
+	db := newTestDB(&fakeDriver{})
+	rows, err := db.QueryContext(context.Background(), "SELECT x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols, err := rows.Columns(); err != nil || len(cols) != 1 || cols[0] != "x" {
+		t.Errorf("Columns() = %q, %v", cols, err)
+	}
+	if rows.Next() {
+		t.Error("Next() = true on an empty result")
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(db.freeConn); n != 1 {
+		t.Errorf("len(freeConn) = %d; want 1", n)
+	}
+}
+
+func TestQueryContextShimCancel(t *testing.T) {
+	db, d, bc := newBlockDB()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-bc.started
+		cancel()
+	}()
+
+	rows, err := db.QueryContext(ctx, "SELECT x")
+	if err != context.Canceled || rows != nil {
+		t.Fatalf("QueryContext = %v, %v; want nil, context.Canceled", rows, err)
+	}
+	// 放弃的调用返回之后, 它的Rows要被关闭, 连接被当作坏连接关闭
+	close(bc.unblock)
+	waitDone(t, bc.rows.done, "abandoned Rows")
+	waitClosed(t, d, 1)
+	if n := len(db.freeConn); n != 0 {
+		t.Errorf("len(freeConn) = %d; the abandoned connection must not be reused", n)
+	}
+}
+
+func TestBeginTxShimCancel(t *testing.T) {
+	db, d, bc := newBlockDB()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-bc.started
+		cancel()
+	}()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != context.Canceled || tx != nil {
+		t.Fatalf("BeginTx = %v, %v; want nil, context.Canceled", tx, err)
+	}
+	close(bc.unblock)
+	waitDone(t, bc.tx.done, "abandoned Tx")
+	waitClosed(t, d, 1)
+}
+
+func TestConnQueryContextShimCancel(t *testing.T) {
+	db, d, bc := newBlockDB()
+	c, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-bc.started
+		cancel()
+	}()
+
+	if _, err := c.QueryContext(ctx, "SELECT x"); err != context.Canceled {
+		t.Fatalf("QueryContext error = %v; want context.Canceled", err)
+	}
+	// Conn记住了连接已经坏了, Close时不会放回连接池
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	close(bc.unblock)
+	waitDone(t, bc.rows.done, "abandoned Rows")
+	waitClosed(t, d, 1)
+}
+
+// 老的Begin没法传选项, 非默认的选项要报错而不是被忽略
+func TestBeginTxShimOptions(t *testing.T) {
+	db, _, _ := newBlockDB()
+	if _, err := db.BeginTx(context.Background(), &TxOptions{ReadOnly: true}); err != errTxOptions {
+		t.Errorf("BeginTx(ReadOnly) error = %v; want errTxOptions", err)
+	}
+}
+
+// stmtConn 没有Execer与Queryer, 语句要先Prepare, 执行时一直阻塞到unblock被关闭
+type stmtConn struct {
+	fakeConn
+	started chan struct{}
+	unblock chan struct{}
+
+	mu     sync.Mutex
+	closed int // 关闭了的语句数
+}
+
+func (c *stmtConn) Prepare(query string) (driver.Stmt, error) {
+	return &blockStmt{fakeStmt: fakeStmt{q: query}, c: c}, nil
+}
+
+func (c *stmtConn) numStmtClosed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+type blockStmt struct {
+	fakeStmt
+	c *stmtConn
+}
+
+func (s *blockStmt) Close() error {
+	s.c.mu.Lock()
+	s.c.closed++
+	s.c.mu.Unlock()
+	return nil
+}
+
+func (s *blockStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.started <- struct{}{}
+	<-s.c.unblock
+	return s.fakeStmt.Exec(args)
+}
+
+func (s *blockStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.started <- struct{}{}
+	<-s.c.unblock
+	return s.fakeStmt.Query(args)
+}
+
+func newStmtDB() (*DB, *connDriver, *stmtConn) {
+	sc := &stmtConn{started: make(chan struct{}, 1), unblock: make(chan struct{})}
+	d := &connDriver{newConn: func(fc fakeConn) driver.Conn {
+		sc.fakeConn = fc
+		return sc
+	}}
+	return newTestDB(d), d, sc
+}
+
+// 先Prepare再执行时, 语句正常结束要马上关闭, 调用被放弃时要在连接关闭时关闭
+func TestPreparedStmtClosed(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		run  func(ctx context.Context, db *DB) error
+	}{
+		{"Exec", func(ctx context.Context, db *DB) error {
+			_, err := db.ExecContext(ctx, "UPDATE t")
+			return err
+		}},
+		{"Query", func(ctx context.Context, db *DB) error {
+			rows, err := db.QueryContext(ctx, "SELECT x")
+			if err == nil {
+				rows.Close()
+			}
+			return err
+		}},
+	} {
+		db, _, sc := newStmtDB()
+		close(sc.unblock)
+		if err := tt.run(context.Background(), db); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if n := sc.numStmtClosed(); n != 1 {
+			t.Errorf("%s: closed %d statements; want 1", tt.name, n)
+		}
+		if n := len(db.freeConn); n != 1 || len(db.freeConn[0].openStmt) != 0 {
+			t.Errorf("%s: statement still tracked after it was closed", tt.name)
+		}
+
+		db, d, sc := newStmtDB()
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-sc.started
+			cancel()
+		}()
+		if err := tt.run(ctx, db); err != context.Canceled {
+			t.Fatalf("%s: error = %v; want context.Canceled", tt.name, err)
+		}
+		if n := sc.numStmtClosed(); n != 0 {
+			t.Errorf("%s: closed %d statements while the abandoned call still uses it", tt.name, n)
+		}
+		close(sc.unblock)
+		waitClosed(t, d, 1)
+		if n := sc.numStmtClosed(); n != 1 {
+			t.Errorf("%s: closed %d statements after the connection was closed; want 1", tt.name, n)
+		}
+	}
+}
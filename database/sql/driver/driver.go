@@ -2,7 +2,10 @@
 // 这里的代码大多被用于sql包
 package driver
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 // Value是一个值(它是一个空接口), 驱动必须有能力去处理
 // 它要么是nil, 要么是以下类型的实例:
@@ -31,7 +34,7 @@ var ErrBadConn = errors.New("driver: bad connection")
 // Execer是一个可选的接口, 它可能被一个Conn实现
 // 如果Conn接口没有实现Execer, sql.DB.Exec会先生成一个prepared statement
 // 然后关闭
-var Execer interface {
+type Execer interface {
 	Exec(query string, args []Value) (Result, error)
 }
 
@@ -39,6 +42,58 @@ type Queryer interface {
 	Query(query string, args []Value) (Rows, error)
 }
 
+// ExecerContext 是Execer的context版本, 驱动实现了它时sql包优先使用它
+// 驱动必须在ctx被取消时尽快返回
+// 如果没有实现, sql包会在一个单独的goroutine里调用Execer, ctx被取消时直接返回ctx.Err()
+type ExecerContext interface {
+	ExecContext(ctx context.Context, query string, args []NamedValue) (Result, error)
+}
+
+// QueryerContext 是Queryer的context版本, 和ExecerContext一样
+type QueryerContext interface {
+	QueryContext(ctx context.Context, query string, args []NamedValue) (Rows, error)
+}
+
+// ConnBeginTx 是Conn.Begin的context版本, 同时支持事务选项
+// 如果驱动不支持opts里的隔离级别或者只读, 必须返回错误
+type ConnBeginTx interface {
+	BeginTx(ctx context.Context, opts TxOptions) (Tx, error)
+}
+
+// StmtExecContext 是Stmt.Exec的context版本
+type StmtExecContext interface {
+	ExecContext(ctx context.Context, args []NamedValue) (Result, error)
+}
+
+// StmtQueryContext 是Stmt.Query的context版本
+type StmtQueryContext interface {
+	QueryContext(ctx context.Context, args []NamedValue) (Rows, error)
+}
+
+// Pinger 是一个可选的接口, 用于检查连接是否还可用
+// 返回ErrBadConn时, sql包会把这个连接从连接池里去掉
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// NamedValue 是带有名字与位置的参数值
+// Name为空表示是位置参数, Ordinal从1开始
+type NamedValue struct {
+	Name    string
+	Ordinal int
+	Value   Value
+}
+
+// IsolationLevel 是事务的隔离级别, 0表示驱动的默认级别
+// 具体的值由sql包定义, 驱动只需要识别自己支持的
+type IsolationLevel int
+
+// TxOptions 是开启事务时的选项
+type TxOptions struct {
+	Isolation IsolationLevel
+	ReadOnly  bool
+}
+
 type Conn interface {
 	Prepare(query string) (Stmt, error)
 
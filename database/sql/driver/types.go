@@ -1,7 +1,8 @@
-package dirver
+package driver
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"time"
@@ -101,6 +102,165 @@ func (stringType) ConvertValue(v interface{}) (Value, error) {
 	return fmt.Sprintf("%v", v), nil
 }
 
+// Int64 把整数, 浮点数(必须是整数值), 字符串转为int64
+// 超出int64范围的值会返回overflow错误, 而不是悄悄截断
+var Int64 int64Type
+
+type int64Type struct{}
+
+var _ ValueConverter = int64Type{}
+
+func (int64Type) String() string { return "Int64" }
+
+func (int64Type) ConvertValue(v interface{}) (Value, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u64 := rv.Uint()
+		if u64 > math.MaxInt64 {
+			return nil, fmt.Errorf("sql/driver: value %d overflows int64", v)
+		}
+		return int64(u64), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("sql/driver: value %v has a fractional part, can't convert to int64", v)
+		}
+		// float64(math.MaxInt64)实际是2^63, 所以这里用>=
+		if f >= math.MaxInt64 || f < math.MinInt64 {
+			return nil, fmt.Errorf("sql/driver: value %v overflows int64", v)
+		}
+		return int64(f), nil
+	case reflect.String:
+		return parseInt64(rv.String(), v)
+	case reflect.Slice:
+		if b, ok := v.([]byte); ok {
+			return parseInt64(string(b), v)
+		}
+	}
+	return nil, fmt.Errorf("sql/driver: unsupported value %v (type %T) converting to int64", v, v)
+}
+
+func parseInt64(s string, v interface{}) (Value, error) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return nil, fmt.Errorf("sql/driver: value %q overflows int64", s)
+		}
+		return nil, fmt.Errorf("sql/driver: value %q can't be converted to int64", v)
+	}
+	return i, nil
+}
+
+// Float64 把数字和字符串转为float64
+// 整数无法被float64精确表示时(绝对值大于2^53, 并且低位不全是0)返回错误
+var Float64 float64Type
+
+type float64Type struct{}
+
+var _ ValueConverter = float64Type{}
+
+func (float64Type) String() string { return "Float64" }
+
+func (float64Type) ConvertValue(v interface{}) (Value, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// float64(i64)可能向上舍入到2^63, 这时转回int64的结果依赖于平台, 要先排除
+		i64 := rv.Int()
+		f := float64(i64)
+		if f >= 1<<63 || int64(f) != i64 {
+			return nil, fmt.Errorf("sql/driver: value %d can't be represented exactly as float64", v)
+		}
+		return f, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u64 := rv.Uint()
+		f := float64(u64)
+		if f >= 1<<64 || uint64(f) != u64 {
+			return nil, fmt.Errorf("sql/driver: value %d can't be represented exactly as float64", v)
+		}
+		return f, nil
+	case reflect.String:
+		return parseFloat64(rv.String(), v)
+	case reflect.Slice:
+		if b, ok := v.([]byte); ok {
+			return parseFloat64(string(b), v)
+		}
+	}
+	return nil, fmt.Errorf("sql/driver: unsupported value %v (type %T) converting to float64", v, v)
+}
+
+func parseFloat64(s string, v interface{}) (Value, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return nil, fmt.Errorf("sql/driver: value %q overflows float64", s)
+		}
+		return nil, fmt.Errorf("sql/driver: value %q can't be converted to float64", v)
+	}
+	return f, nil
+}
+
+// Time 接受time.Time, 或者RFC3339格式的字符串/字节切片
+var Time timeType
+
+type timeType struct{}
+
+var _ ValueConverter = timeType{}
+
+func (timeType) String() string { return "Time" }
+
+func (timeType) ConvertValue(v interface{}) (Value, error) {
+	switch s := v.(type) {
+	case time.Time:
+		return s, nil
+	case *time.Time:
+		if s == nil {
+			return nil, fmt.Errorf("sql/driver: nil *time.Time can't be converted to time")
+		}
+		return *s, nil
+	case string:
+		return parseTime(s)
+	case []byte:
+		return parseTime(string(s))
+	}
+	return nil, fmt.Errorf("sql/driver: unsupported value %v (type %T) converting to time", v, v)
+}
+
+func parseTime(s string) (Value, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, fmt.Errorf("sql/driver: value %q can't be converted to time: %v", s, err)
+	}
+	return t, nil
+}
+
+// Bytes 把string和[]byte转为[]byte
+// 注意这里会复制一份, 因为调用者可能会重用原来的切片
+var Bytes bytesType
+
+type bytesType struct{}
+
+var _ ValueConverter = bytesType{}
+
+func (bytesType) String() string { return "Bytes" }
+
+func (bytesType) ConvertValue(v interface{}) (Value, error) {
+	switch s := v.(type) {
+	case []byte:
+		b := make([]byte, len(s))
+		copy(b, s)
+		return b, nil
+	case string:
+		return []byte(s), nil
+	}
+	return nil, fmt.Errorf("sql/driver: unsupported value %v (type %T) converting to bytes", v, v)
+}
+
 type Null struct {
 	Converter ValueConverter
 }
@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type valueConverterTest struct {
+	c   ValueConverter
+	in  interface{}
+	out interface{}
+	err string
+}
+
+var now = time.Date(2015, 6, 1, 12, 30, 0, 500, time.UTC)
+
+var valueConverterTests = []valueConverterTest{
+	{Int64, int8(-3), int64(-3), ""},
+	{Int64, uint32(7), int64(7), ""},
+	{Int64, uint64(math.MaxInt64), int64(math.MaxInt64), ""},
+	{Int64, uint64(math.MaxInt64 + 1), nil, "overflows int64"},
+	{Int64, float64(42), int64(42), ""},
+	{Int64, 1.5, nil, "fractional part"},
+	{Int64, float64(1 << 63), nil, "overflows int64"},
+	{Int64, "-12", int64(-12), ""},
+	{Int64, []byte("99"), int64(99), ""},
+	{Int64, "9223372036854775808", nil, "overflows int64"},
+	{Int64, "abc", nil, "can't be converted to int64"},
+	{Int64, true, nil, "unsupported value"},
+
+	{Float64, float32(1.5), float64(1.5), ""},
+	{Float64, int64(1 << 53), float64(1 << 53), ""},
+	{Float64, int64(1<<53 + 1), nil, "exactly as float64"},
+	{Float64, uint64(1<<53 + 1), nil, "exactly as float64"},
+	// 超过2^53但是低位都是0的整数可以精确表示
+	{Float64, int64(1 << 54), float64(1 << 54), ""},
+	{Float64, int64(-1 << 63), float64(-1 << 63), ""},
+	{Float64, int64(1<<63 - 1), nil, "exactly as float64"},
+	{Float64, uint64(1 << 63), float64(1 << 63), ""},
+	{Float64, uint64(1<<64 - 1), nil, "exactly as float64"},
+	{Float64, "3.25", 3.25, ""},
+	{Float64, "1e400", nil, "overflows float64"},
+	{Float64, "x", nil, "can't be converted to float64"},
+
+	{Time, now, now, ""},
+	{Time, now.Format(time.RFC3339Nano), now, ""},
+	{Time, []byte(now.Format(time.RFC3339Nano)), now, ""},
+	{Time, "yesterday", nil, "can't be converted to time"},
+	{Time, 3, nil, "unsupported value"},
+
+	{Bytes, "foo", []byte("foo"), ""},
+	{Bytes, []byte("bar"), []byte("bar"), ""},
+	{Bytes, 1, nil, "unsupported value"},
+
+	{Null{Int64}, nil, nil, ""},
+	{NotNull{Int64}, nil, nil, "nil value not allowed"},
+}
+
+func TestValueConverters(t *testing.T) {
+	for i, tt := range valueConverterTests {
+		out, err := tt.c.ConvertValue(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("test %d: %v(%T) error = %v, want %q", i, tt.in, tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %v(%T) unexpected error: %v", i, tt.in, tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, tt.out) {
+			t.Errorf("test %d: %v(%T) = %v(%T), want %v(%T)", i, tt.in, tt.in, out, out, tt.out, tt.out)
+		}
+	}
+}
+
+func TestBytesCopies(t *testing.T) {
+	src := []byte("abc")
+	v, _ := Bytes.ConvertValue(src)
+	src[0] = 'x'
+	if got := string(v.([]byte)); got != "abc" {
+		t.Errorf("Bytes.ConvertValue shares memory with its input: got %q", got)
+	}
+}
@@ -0,0 +1,87 @@
+package sql
+
+import (
+	"database/sql/driver"
+	"io"
+	"sync"
+)
+
+// fakeDriver 是测试用的驱动, 只实现了database/sql/driver里最基本的接口
+// 语句不会真正执行, Exec总是成功, Query总是返回一个没有行的结果集
+type fakeDriver struct {
+	mu     sync.Mutex
+	opened int
+	closed int
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	d.opened++
+	d.mu.Unlock()
+	return &fakeConn{d: d}, nil
+}
+
+func (d *fakeDriver) numClosed() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{q: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	c.d.mu.Lock()
+	c.d.closed++
+	c.d.mu.Unlock()
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	q string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{cols: []string{"x"}}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newTestDB 返回一个使用d的DB, 这个包里没有Open, 只能直接构造
+func newTestDB(d driver.Driver) *DB {
+	return &DB{driver: d}
+}
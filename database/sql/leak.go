@@ -0,0 +1,252 @@
+package sql
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LeakDetector 在运行期检测连接泄漏
+// debugGetPut只能在编译期打开, 而且只会在重复putConn时打印
+// LeakDetector记录每次取出连接时的调用栈, 持有时间超过Threshold的连接会被报告,
+// 另外还会列出db.dep里还没有关闭的Rows/Stmt等
+//
+// 开启后每次取连接都要调用runtime.Stack, 开销不小, 不要在生产环境长期开着
+type LeakDetector struct {
+	// Threshold 连接被持有多久算是泄漏, <=0表示不检查持有时间
+	Threshold time.Duration
+	// Logger 为nil时输出到os.Stderr
+	Logger *log.Logger
+
+	mu        sync.Mutex
+	db        *DB
+	checkouts map[*driverConn]*checkout
+	stop      chan struct{}
+}
+
+// checkout 是一次取出连接的记录
+type checkout struct {
+	at       time.Time
+	stack    string
+	reported bool // 已经报告过了, 不重复报告
+}
+
+// LeakedConn 是一个持有时间超过Threshold的连接
+type LeakedConn struct {
+	Held  time.Duration
+	Stack string // 取出连接时的调用栈
+}
+
+// OpenDep 是某个类型还没有关闭的对象, 通常是没有Close的Rows或者Stmt
+type OpenDep struct {
+	Type string
+	Deps int // 这个类型还没有关闭的个数
+}
+
+// LeakReport 是某个时刻的泄漏检测结果
+type LeakReport struct {
+	InUse    int
+	Leaked   []LeakedConn
+	OpenDeps []OpenDep
+}
+
+// NewLeakDetector 返回一个LeakDetector, 需要通过DB.SetLeakDetector开启
+func NewLeakDetector(threshold time.Duration, logger *log.Logger) *LeakDetector {
+	return &LeakDetector{Threshold: threshold, Logger: logger}
+}
+
+// SetLeakDetector 开启运行期泄漏检测, ld为nil表示关闭
+// 设置了Threshold时会起一个goroutine定期检查
+func (db *DB) SetLeakDetector(ld *LeakDetector) {
+	db.mu.Lock()
+	old := db.leak
+	db.leak = ld
+	db.mu.Unlock()
+
+	if old != nil {
+		old.mu.Lock()
+		if old.stop != nil {
+			close(old.stop)
+			old.stop = nil
+		}
+		old.mu.Unlock()
+	}
+	if ld == nil {
+		return
+	}
+
+	ld.mu.Lock()
+	ld.db = db
+	ld.checkouts = make(map[*driverConn]*checkout)
+	if ld.Threshold > 0 {
+		ld.stop = make(chan struct{})
+		go ld.watch(ld.stop)
+	}
+	ld.mu.Unlock()
+}
+
+// noteGetLocked 必须在conn把dc交给调用者之前调用, 需要持有db.mu
+// 与putConn里的ld.put成对出现
+func (db *DB) noteGetLocked(dc *driverConn) {
+	if db.leak != nil {
+		db.leak.get(dc)
+	}
+}
+
+func (ld *LeakDetector) get(dc *driverConn) {
+	st := stack()
+	ld.mu.Lock()
+	if ld.checkouts != nil {
+		ld.checkouts[dc] = &checkout{at: time.Now(), stack: st}
+	}
+	ld.mu.Unlock()
+}
+
+func (ld *LeakDetector) put(dc *driverConn) {
+	ld.mu.Lock()
+	co := ld.checkouts[dc]
+	delete(ld.checkouts, dc)
+	ld.mu.Unlock()
+
+	if co != nil && co.reported {
+		ld.logf("sql: connection held for %v was finally returned", time.Since(co.at))
+	}
+}
+
+func (ld *LeakDetector) logf(format string, args ...interface{}) {
+	if ld.Logger != nil {
+		ld.Logger.Printf(format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// watch 每隔Threshold检查一次
+func (ld *LeakDetector) watch(stop chan struct{}) {
+	t := time.NewTicker(ld.Threshold)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			ld.Check()
+		}
+	}
+}
+
+// Check 把新发现的超时连接写到Logger里, 每个连接只报告一次
+func (ld *LeakDetector) Check() {
+	if ld.Threshold <= 0 {
+		return
+	}
+	now := time.Now()
+	var leaked []LeakedConn
+	ld.mu.Lock()
+	for _, co := range ld.checkouts {
+		if held := now.Sub(co.at); !co.reported && held > ld.Threshold {
+			co.reported = true
+			leaked = append(leaked, LeakedConn{Held: held, Stack: co.stack})
+		}
+	}
+	ld.mu.Unlock()
+
+	for _, lc := range leaked {
+		ld.logf("sql: connection held for %v (threshold %v), checked out at:\n%s", lc.Held, ld.Threshold, lc.Stack)
+	}
+}
+
+// Report 返回当前的检测结果, 包括已经报告过的连接
+func (ld *LeakDetector) Report() LeakReport {
+	var r LeakReport
+
+	ld.mu.Lock()
+	db := ld.db
+	ld.mu.Unlock()
+	if db != nil {
+		// 先拿db.mu再拿ld.mu, 与putConn里的顺序一致
+		db.mu.Lock()
+		counts := make(map[string]int)
+		for fc, deps := range db.dep {
+			for dep := range deps {
+				// driverConn依赖于自己, 表示连接还没有Close, 不算泄漏
+				if dep == fc {
+					continue
+				}
+				counts[fmt.Sprintf("%T", dep)]++
+			}
+		}
+		db.mu.Unlock()
+		for typ, n := range counts {
+			r.OpenDeps = append(r.OpenDeps, OpenDep{Type: typ, Deps: n})
+		}
+		sort.Sort(byDepType(r.OpenDeps))
+	}
+
+	now := time.Now()
+	ld.mu.Lock()
+	r.InUse = len(ld.checkouts)
+	for _, co := range ld.checkouts {
+		if held := now.Sub(co.at); ld.Threshold > 0 && held > ld.Threshold {
+			r.Leaked = append(r.Leaked, LeakedConn{Held: held, Stack: co.stack})
+		}
+	}
+	ld.mu.Unlock()
+	sort.Sort(byHeld(r.Leaked))
+	return r
+}
+
+// WriteTo 把报告以文本形式写出
+func (r LeakReport) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	fmt.Fprintf(cw, "connections in use: %d\n", r.InUse)
+	fmt.Fprintf(cw, "connections over threshold: %d\n", len(r.Leaked))
+	for _, lc := range r.Leaked {
+		fmt.Fprintf(cw, "\nheld for %v, checked out at:\n%s\n", lc.Held, lc.Stack)
+	}
+	fmt.Fprintf(cw, "\nunclosed dependencies: %d\n", len(r.OpenDeps))
+	for _, od := range r.OpenDeps {
+		fmt.Fprintf(cw, "%s: %d\n", od.Type, od.Deps)
+	}
+	return cw.n, cw.err
+}
+
+// Dump 把当前的检测结果以文本形式写到w, 方便挂到调试用的handler上, 例如
+//
+//	func(w http.ResponseWriter, r *http.Request) { ld.Dump(w) }
+func (ld *LeakDetector) Dump(w io.Writer) error {
+	_, err := ld.Report().WriteTo(w)
+	return err
+}
+
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+type byHeld []LeakedConn
+
+func (s byHeld) Len() int           { return len(s) }
+func (s byHeld) Less(i, j int) bool { return s[i].Held > s[j].Held }
+func (s byHeld) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type byDepType []OpenDep
+
+func (s byDepType) Len() int           { return len(s) }
+func (s byDepType) Less(i, j int) bool { return s[i].Type < s[j].Type }
+func (s byDepType) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
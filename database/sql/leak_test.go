@@ -0,0 +1,199 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer 给Logger用, watch所在的goroutine也可能写日志
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newLeakTestDB(t *testing.T) (*DB, *LeakDetector, *lockedBuffer) {
+	db := newTestDB(&fakeDriver{})
+	out := new(lockedBuffer)
+	// Threshold足够大, watch不会自己触发, 由测试调用Check
+	ld := NewLeakDetector(time.Hour, log.New(out, "", 0))
+	db.SetLeakDetector(ld)
+	t.Cleanup(func() { db.SetLeakDetector(nil) })
+	return db, ld, out
+}
+
+// backdate 把dc的取出时间往前调, 模拟持有了很久
+func backdate(ld *LeakDetector, dc *driverConn, d time.Duration) {
+	ld.mu.Lock()
+	ld.checkouts[dc].at = time.Now().Add(-d)
+	ld.mu.Unlock()
+}
+
+func TestLeakDetectorCheck(t *testing.T) {
+	db, ld, out := newLeakTestDB(t)
+	dc, err := db.conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := ld.Report(); r.InUse != 1 || len(r.Leaked) != 0 {
+		t.Fatalf("Report() = %+v; want 1 in use, none leaked", r)
+	}
+
+	backdate(ld, dc, 2*time.Hour)
+	ld.Check()
+	if got := out.String(); !strings.Contains(got, "connection held for") || !strings.Contains(got, "TestLeakDetectorCheck") {
+		t.Fatalf("Check() logged %q; want the checkout stack", got)
+	}
+	// 每个连接只报告一次
+	n := len(out.String())
+	ld.Check()
+	if len(out.String()) != n {
+		t.Errorf("second Check() logged again: %q", out.String()[n:])
+	}
+	r := ld.Report()
+	if r.InUse != 1 || len(r.Leaked) != 1 {
+		t.Fatalf("Report() = %+v; want 1 in use, 1 leaked", r)
+	}
+
+	dc.releaseConn(nil)
+	if !strings.Contains(out.String(), "was finally returned") {
+		t.Errorf("putConn of a reported connection logged %q", out.String())
+	}
+	if r := ld.Report(); r.InUse != 0 || len(r.Leaked) != 0 {
+		t.Errorf("Report() after put = %+v; want empty", r)
+	}
+}
+
+func TestLeakDetectorConnRequest(t *testing.T) {
+	db, ld, _ := newLeakTestDB(t)
+	db.maxOpen = 1
+	dc, err := db.conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan *driverConn)
+	go func() {
+		dc2, err := db.conn(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- dc2
+	}()
+	// 等到第二个conn开始排队
+	for {
+		db.mu.Lock()
+		n := len(db.connRequests)
+		db.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	dc.releaseConn(nil)
+	dc2 := <-got
+	// 通过connRequest交出去的连接同样要被记录
+	if r := ld.Report(); r.InUse != 1 {
+		t.Errorf("Report().InUse = %d after handing off to a waiter; want 1", r.InUse)
+	}
+	dc2.releaseConn(nil)
+	if r := ld.Report(); r.InUse != 0 {
+		t.Errorf("Report().InUse = %d; want 0", r.InUse)
+	}
+}
+
+func TestLastPutClearedOnClose(t *testing.T) {
+	db, _, _ := newLeakTestDB(t)
+	dc, err := db.conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc.releaseConn(nil)
+	db.mu.Lock()
+	n := len(db.lastPut)
+	db.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("len(lastPut) = %d after put; want 1", n)
+	}
+
+	dc, err = db.conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 坏连接会被关闭, 它的记录也要一起删掉
+	dc.releaseConn(driver.ErrBadConn)
+	db.mu.Lock()
+	n = len(db.lastPut)
+	db.mu.Unlock()
+	if n != 0 {
+		t.Errorf("len(lastPut) = %d after the connection was closed; want 0", n)
+	}
+	if c := db.driver.(*fakeDriver).numClosed(); c != 1 {
+		t.Errorf("driver closed %d connections; want 1", c)
+	}
+}
+
+func TestLeakDetectorDump(t *testing.T) {
+	db, ld, _ := newLeakTestDB(t)
+	dc, err := db.conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.releaseConn(nil)
+	backdate(ld, dc, 2*time.Hour)
+
+	var buf bytes.Buffer
+	if err := ld.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"connections in use: 1\n",
+		"connections over threshold: 1\n",
+		"TestLeakDetectorDump",
+		"unclosed dependencies: 0\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Dump() output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestLeakDetectorOpenDeps(t *testing.T) {
+	db, ld, _ := newLeakTestDB(t)
+	// fakeConn没有Queryer, 查询先Prepare, 语句与Rows都要列出来
+	rows, err := db.QueryContext(context.Background(), "SELECT x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OpenDep{{Type: "*sql.Rows", Deps: 1}, {Type: "*sql.fakeStmt", Deps: 1}}
+	if r := ld.Report(); !reflect.DeepEqual(r.OpenDeps, want) {
+		t.Errorf("Report().OpenDeps = %+v; want %+v", r.OpenDeps, want)
+	}
+	var buf bytes.Buffer
+	ld.Dump(&buf)
+	if !strings.Contains(buf.String(), "unclosed dependencies: 2\n*sql.Rows: 1\n") {
+		t.Errorf("Dump() output missing the open Rows:\n%s", buf.String())
+	}
+
+	rows.Close()
+	if r := ld.Report(); len(r.OpenDeps) != 0 || r.InUse != 0 {
+		t.Errorf("Report() after Rows.Close = %+v; want empty", r)
+	}
+}
@@ -0,0 +1,327 @@
+// Package migrate 按顺序执行目录里的SQL迁移文件
+//
+// 文件名的格式为NNN_name.up.sql与NNN_name.down.sql, NNN是版本号
+// 已经执行过的版本会记录在一张表里(默认为schema_migrations),
+// 每一个迁移都在自己的事务里执行, 失败时整个迁移回滚, 版本记录也不会写入
+//
+// 为了防止多个进程同时执行迁移, 执行前会往<表名>_lock里插入一行,
+// 插入失败并且这一行已经存在(主键冲突)就表示别的进程正在迁移
+// 注意语句里的占位符用的是?, 需要驱动支持
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTable 是记录已执行版本的默认表名
+const DefaultTable = "schema_migrations"
+
+// ErrLocked 表示另一个迁移进程持有锁
+var ErrLocked = errors.New("migrate: another migration is in progress")
+
+// Migration 表示一个版本的迁移, Down可以为空, 此时无法回滚
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status 是某个迁移的执行状态, 由Migrator.Status返回
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator 负责把Dir里的迁移应用到DB上
+type Migrator struct {
+	DB    *sql.DB
+	Dir   string
+	Table string // 为空时使用DefaultTable
+
+	mu sync.Mutex // 同一进程里的Migrator也不能并发执行
+}
+
+// New 返回一个使用默认表名的Migrator
+func New(db *sql.DB, dir string) *Migrator {
+	return &Migrator{DB: db, Dir: dir, Table: DefaultTable}
+}
+
+func (m *Migrator) table() string {
+	if m.Table == "" {
+		return DefaultTable
+	}
+	return m.Table
+}
+
+// Load 读取Dir里的迁移文件, 按版本号从小到大排序
+// 不符合命名格式的文件会被忽略
+func (m *Migrator) Load() ([]Migration, error) {
+	fis, err := ioutil.ReadDir(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, fi := range fis {
+		if fi.IsDir() {
+			continue
+		}
+		version, name, up, ok := parseFilename(fi.Name())
+		if !ok {
+			continue
+		}
+		body, err := ioutil.ReadFile(filepath.Join(m.Dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mg := byVersion[version]
+		if mg == nil {
+			mg = &Migration{Version: version, Name: name}
+			byVersion[version] = mg
+		} else if mg.Name != name {
+			return nil, fmt.Errorf("migrate: version %d used by both %q and %q", version, mg.Name, name)
+		}
+		if up {
+			mg.Up = string(body)
+		} else {
+			mg.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) has no up migration", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, *mg)
+	}
+	sort.Sort(byVersionAsc(migrations))
+	return migrations, nil
+}
+
+// parseFilename 解析NNN_name.up.sql, up为false表示是.down.sql
+func parseFilename(fn string) (version int, name string, up, ok bool) {
+	switch {
+	case strings.HasSuffix(fn, ".up.sql"):
+		fn, up = strings.TrimSuffix(fn, ".up.sql"), true
+	case strings.HasSuffix(fn, ".down.sql"):
+		fn = strings.TrimSuffix(fn, ".down.sql")
+	default:
+		return
+	}
+	i := strings.IndexByte(fn, '_')
+	if i <= 0 || i == len(fn)-1 {
+		return
+	}
+	v, err := strconv.Atoi(fn[:i])
+	if err != nil || v < 0 {
+		return
+	}
+	return v, fn[i+1:], up, true
+}
+
+type byVersionAsc []Migration
+
+func (s byVersionAsc) Len() int           { return len(s) }
+func (s byVersionAsc) Less(i, j int) bool { return s[i].Version < s[j].Version }
+func (s byVersionAsc) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Up 执行所有还没执行过的迁移, 返回执行的个数
+// 某个迁移失败时停止, 之前成功的迁移不会回滚
+func (m *Migrator) Up() (int, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	err = m.locked(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		for _, mg := range migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err := m.apply(mg, true); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Down 回滚最近执行的n个迁移, 返回回滚的个数
+func (m *Migrator) Down(n int) (int, error) {
+	if n <= 0 {
+		return 0, nil
+	}
+	migrations, err := m.Load()
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	err = m.locked(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && done < n; i-- {
+			mg := migrations[i]
+			if _, ok := applied[mg.Version]; !ok {
+				continue
+			}
+			if mg.Down == "" {
+				return fmt.Errorf("migrate: version %d (%s) has no down migration", mg.Version, mg.Name)
+			}
+			if err := m.apply(mg, false); err != nil {
+				return err
+			}
+			done++
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 返回Dir里每个迁移的执行状态
+func (m *Migrator) Status() ([]Status, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ensureTables(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, len(migrations))
+	for i, mg := range migrations {
+		at, ok := applied[mg.Version]
+		status[i] = Status{Migration: mg, Applied: ok, AppliedAt: at}
+	}
+	return status, nil
+}
+
+// Unlock 强制释放锁, 用于迁移进程崩溃后锁没有被释放的情况
+func (m *Migrator) Unlock() error {
+	_, err := m.DB.Exec("DELETE FROM " + m.table() + "_lock WHERE id = 1")
+	return err
+}
+
+func (m *Migrator) ensureTables() error {
+	if _, err := m.DB.Exec("CREATE TABLE IF NOT EXISTS " + m.table() +
+		" (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)"); err != nil {
+		return err
+	}
+	_, err := m.DB.Exec("CREATE TABLE IF NOT EXISTS " + m.table() + "_lock (id INTEGER PRIMARY KEY)")
+	return err
+}
+
+// locked 持有进程内与数据库里的锁执行fn
+func (m *Migrator) locked(fn func() error) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ensureTables(); err != nil {
+		return err
+	}
+	if _, err := m.DB.Exec("INSERT INTO " + m.table() + "_lock (id) VALUES (1)"); err != nil {
+		// 驱动的错误没法直接区分主键冲突与其它错误,
+		// 所以再查一次锁所在的行, 只有它确实存在时才是被别人锁住了
+		if m.lockHeld() {
+			return ErrLocked
+		}
+		return err
+	}
+	defer func() {
+		if uerr := m.Unlock(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+	return fn()
+}
+
+// lockHeld 报告锁所在的行是否存在, 查询出错时返回false
+func (m *Migrator) lockHeld() bool {
+	var n int
+	err := m.DB.QueryRow("SELECT COUNT(*) FROM " + m.table() + "_lock WHERE id = 1").Scan(&n)
+	return err == nil && n > 0
+}
+
+// applied 返回已执行的版本与执行时间
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	rows, err := m.DB.Query("SELECT version, applied_at FROM " + m.table())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version int
+			at      time.Time
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// apply 在一个事务里执行迁移并更新版本表
+func (m *Migrator) apply(mg Migration, up bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	body := mg.Up
+	if !up {
+		body = mg.Down
+	}
+	if _, err := tx.Exec(body); err != nil {
+		tx.Rollback()
+		return &Error{Migration: mg, Up: up, Err: err}
+	}
+	if up {
+		_, err = tx.Exec("INSERT INTO "+m.table()+" (version, name, applied_at) VALUES (?, ?, ?)",
+			mg.Version, mg.Name, time.Now().UTC())
+	} else {
+		_, err = tx.Exec("DELETE FROM "+m.table()+" WHERE version = ?", mg.Version)
+	}
+	if err != nil {
+		tx.Rollback()
+		return &Error{Migration: mg, Up: up, Err: err}
+	}
+	return tx.Commit()
+}
+
+// Error 表示某个迁移执行失败
+type Error struct {
+	Migration Migration
+	Up        bool
+	Err       error
+}
+
+func (e *Error) Error() string {
+	dir := "down"
+	if e.Up {
+		dir = "up"
+	}
+	return fmt.Sprintf("migrate: %03d_%s.%s.sql: %v", e.Migration.Version, e.Migration.Name, dir, e.Err)
+}
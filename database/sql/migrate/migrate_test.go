@@ -0,0 +1,300 @@
+package migrate
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memDriver 是一个只认识Migrator所用语句的内存驱动
+// 迁移本身的语句只记录到log里, 包含FAIL的语句会返回错误
+type memDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*memDB
+}
+
+type memDB struct {
+	mu       sync.Mutex
+	versions map[int64]time.Time
+	locked   bool
+	lockErr  error // 不为nil时, 插入锁的语句返回这个错误
+	log      []string
+}
+
+var memdb = &memDriver{dbs: make(map[string]*memDB)}
+
+func init() {
+	sql.Register("memdb", memdb)
+}
+
+func (d *memDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db := d.dbs[name]
+	if db == nil {
+		db = &memDB{versions: make(map[int64]time.Time)}
+		d.dbs[name] = db
+	}
+	return &memConn{db: db}, nil
+}
+
+type memConn struct {
+	db *memDB
+	tx *memTx
+}
+
+func (c *memConn) Prepare(query string) (driver.Stmt, error) {
+	return &memStmt{c: c, q: query}, nil
+}
+
+func (c *memConn) Close() error { return nil }
+
+func (c *memConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	tx := &memTx{c: c, versions: make(map[int64]time.Time), log: len(c.db.log)}
+	for v, at := range c.db.versions {
+		tx.versions[v] = at
+	}
+	c.tx = tx
+	return tx, nil
+}
+
+// memTx 在Begin时保存快照, Rollback时恢复
+type memTx struct {
+	c        *memConn
+	versions map[int64]time.Time
+	log      int
+}
+
+func (tx *memTx) Commit() error {
+	tx.c.tx = nil
+	return nil
+}
+
+func (tx *memTx) Rollback() error {
+	db := tx.c.db
+	db.mu.Lock()
+	db.versions = tx.versions
+	db.log = db.log[:tx.log]
+	db.mu.Unlock()
+	tx.c.tx = nil
+	return nil
+}
+
+type memStmt struct {
+	c *memConn
+	q string
+}
+
+func (s *memStmt) Close() error  { return nil }
+func (s *memStmt) NumInput() int { return -1 }
+
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch q := s.q; {
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS"):
+	case strings.HasPrefix(q, "INSERT INTO schema_migrations_lock"):
+		if db.lockErr != nil {
+			return nil, db.lockErr
+		}
+		if db.locked {
+			return nil, errors.New("memdb: duplicate primary key")
+		}
+		db.locked = true
+	case strings.HasPrefix(q, "DELETE FROM schema_migrations_lock"):
+		db.locked = false
+	case strings.HasPrefix(q, "INSERT INTO schema_migrations"):
+		db.versions[args[0].(int64)] = args[2].(time.Time)
+	case strings.HasPrefix(q, "DELETE FROM schema_migrations"):
+		delete(db.versions, args[0].(int64))
+	default:
+		if strings.Contains(q, "FAIL") {
+			return nil, errors.New("memdb: syntax error")
+		}
+		db.log = append(db.log, strings.TrimSpace(q))
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *memStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if strings.HasPrefix(s.q, "SELECT COUNT(*) FROM schema_migrations_lock") {
+		n := int64(0)
+		if db.locked {
+			n = 1
+		}
+		return &memRows{cols: []string{"count"}, rows: [][]driver.Value{{n}}}, nil
+	}
+	if !strings.HasPrefix(s.q, "SELECT version, applied_at FROM schema_migrations") {
+		return nil, errors.New("memdb: unsupported query " + s.q)
+	}
+	rows := &memRows{cols: []string{"version", "applied_at"}}
+	for v, at := range db.versions {
+		rows.rows = append(rows.rows, []driver.Value{v, at})
+	}
+	return rows, nil
+}
+
+type memRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *memRows) Columns() []string { return r.cols }
+func (r *memRows) Close() error      { return nil }
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newTestMigrator(t *testing.T, files map[string]string) (*Migrator, *memDB) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := sql.Open("memdb", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只用一个连接, 这样事务的快照不会被别的连接干扰
+	db.SetMaxOpenConns(1)
+	m := New(db, dir)
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+	c, _ := memdb.Open(t.Name())
+	return m, c.(*memConn).db
+}
+
+var files = map[string]string{
+	"001_users.up.sql":    "create users",
+	"001_users.down.sql":  "drop users",
+	"002_posts.up.sql":    "create posts",
+	"002_posts.down.sql":  "drop posts",
+	"010_index.up.sql":    "create index",
+	"010_index.down.sql":  "drop index",
+	"README.md":           "ignored",
+	"abc_bad.up.sql":      "ignored",
+	"003_nodown.down.txt": "ignored",
+}
+
+func TestUpDownStatus(t *testing.T) {
+	m, db := newTestMigrator(t, files)
+
+	n, err := m.Up()
+	if err != nil || n != 3 {
+		t.Fatalf("Up() = %d, %v; want 3, nil", n, err)
+	}
+	want := []string{"create users", "create posts", "create index"}
+	if strings.Join(db.log, ",") != strings.Join(want, ",") {
+		t.Errorf("log = %q, want %q", db.log, want)
+	}
+	if n, err := m.Up(); err != nil || n != 0 {
+		t.Errorf("second Up() = %d, %v; want 0, nil", n, err)
+	}
+
+	if n, err := m.Down(2); err != nil || n != 2 {
+		t.Fatalf("Down(2) = %d, %v; want 2, nil", n, err)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := []bool{true, false, false}
+	for i, st := range status {
+		if st.Applied != applied[i] {
+			t.Errorf("status[%d] (%03d_%s) applied = %v, want %v", i, st.Version, st.Name, st.Applied, applied[i])
+		}
+	}
+	if status[0].AppliedAt.IsZero() {
+		t.Errorf("status[0].AppliedAt is zero")
+	}
+	if db.locked {
+		t.Errorf("lock still held after Down")
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	m, db := newTestMigrator(t, map[string]string{
+		"001_ok.up.sql":   "ok",
+		"002_bad.up.sql":  "FAIL",
+		"003_next.up.sql": "next",
+	})
+	n, err := m.Up()
+	if n != 1 {
+		t.Errorf("Up() applied %d migrations, want 1", n)
+	}
+	merr, ok := err.(*Error)
+	if !ok || merr.Migration.Version != 2 {
+		t.Fatalf("Up() error = %v, want *Error for version 2", err)
+	}
+	if _, ok := db.versions[2]; ok {
+		t.Errorf("failed migration 2 recorded as applied")
+	}
+	if db.locked {
+		t.Errorf("lock still held after failed Up")
+	}
+}
+
+func TestLocked(t *testing.T) {
+	m, db := newTestMigrator(t, files)
+	db.locked = true
+	if _, err := m.Up(); err != ErrLocked {
+		t.Fatalf("Up() error = %v, want ErrLocked", err)
+	}
+	if err := m.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.Up(); err != nil || n != 3 {
+		t.Errorf("Up() after Unlock = %d, %v; want 3, nil", n, err)
+	}
+}
+
+// 插入锁失败但锁并不存在时, 要把驱动的错误原样返回, 而不是ErrLocked
+func TestLockInsertError(t *testing.T) {
+	m, db := newTestMigrator(t, files)
+	lockErr := errors.New("memdb: disk full")
+	db.lockErr = lockErr
+	if _, err := m.Up(); err != lockErr {
+		t.Fatalf("Up() error = %v, want %v", err, lockErr)
+	}
+	db.lockErr = nil
+	if n, err := m.Up(); err != nil || n != 3 {
+		t.Errorf("Up() after error cleared = %d, %v; want 3, nil", n, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	m, _ := newTestMigrator(t, map[string]string{"001_a.down.sql": "x"})
+	if _, err := m.Load(); err == nil {
+		t.Errorf("Load() with missing up migration succeeded")
+	}
+	m, _ = newTestMigrator(t, map[string]string{"001_a.up.sql": "x", "001_b.up.sql": "y"})
+	if _, err := m.Load(); err == nil {
+		t.Errorf("Load() with duplicate version succeeded")
+	}
+}
@@ -5,12 +5,15 @@
 package sql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
 	"runtime"
 	"sort"
 	"sync"
+	"time"
 )
 
 var drivers = make(map[string]driver.Driver)
@@ -49,7 +52,128 @@ func Drivers() []string {
 // 和Scan相关
 type RawBytes []byte
 
-// 一大堆处理Null的方法, 先跳过
+// 一大堆处理Null的方法
+// 数据库里的NULL没法直接Scan进string/int64这样的值类型, 所以需要带Valid字段的包装类型
+// 它们都同时实现了Scanner与driver.Valuer, 所以既能用于Scan, 也能作为Exec的参数
+
+// NullString 表示一个可能为NULL的string
+type NullString struct {
+	String string
+	Valid  bool // Valid为true表示String不是NULL
+}
+
+// Scan 实现了Scanner接口
+func (ns *NullString) Scan(value interface{}) error {
+	if value == nil {
+		ns.String, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return convertAssign(&ns.String, value)
+}
+
+// Value 实现了driver.Valuer接口
+func (ns NullString) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return ns.String, nil
+}
+
+// NullInt64 表示一个可能为NULL的int64
+type NullInt64 struct {
+	Int64 int64
+	Valid bool
+}
+
+func (n *NullInt64) Scan(value interface{}) error {
+	if value == nil {
+		n.Int64, n.Valid = 0, false
+		return nil
+	}
+	n.Valid = true
+	return convertAssign(&n.Int64, value)
+}
+
+func (n NullInt64) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Int64, nil
+}
+
+// NullFloat64 表示一个可能为NULL的float64
+type NullFloat64 struct {
+	Float64 float64
+	Valid   bool
+}
+
+func (n *NullFloat64) Scan(value interface{}) error {
+	if value == nil {
+		n.Float64, n.Valid = 0, false
+		return nil
+	}
+	n.Valid = true
+	return convertAssign(&n.Float64, value)
+}
+
+func (n NullFloat64) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Float64, nil
+}
+
+// NullBool 表示一个可能为NULL的bool
+type NullBool struct {
+	Bool  bool
+	Valid bool
+}
+
+func (n *NullBool) Scan(value interface{}) error {
+	if value == nil {
+		n.Bool, n.Valid = false, false
+		return nil
+	}
+	n.Valid = true
+	return convertAssign(&n.Bool, value)
+}
+
+func (n NullBool) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Bool, nil
+}
+
+// NullTime 表示一个可能为NULL的time.Time
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (n *NullTime) Scan(value interface{}) error {
+	if value == nil {
+		n.Time, n.Valid = time.Time{}, false
+		return nil
+	}
+	n.Valid = true
+	return convertAssign(&n.Time, value)
+}
+
+func (n NullTime) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Time, nil
+}
+
+// Scanner 是被Scan使用的接口
+// src的类型只可能是: int64, float64, bool, []byte, string, time.Time, nil
+// 注意[]byte是驱动所有的, 如果要在Scan之后继续使用, 必须复制一份
+type Scanner interface {
+	Scan(src interface{}) error
+}
 
 // 用于QueryRow结果为空的情况,用于代替*Row, 延时到Scan结束(这第一次见)
 var ErrNoRows = errors.New("sql: no rows in result set")
@@ -73,15 +197,14 @@ type DB struct {
 	freeConn     []*driverConn      //看名字应该是连接,而这个字段表示连接池里的空闲连接
 	connRequests []chan connRequest //这是一个channel, 表示要从连接池里取一个连接来
 	numOpen      int                //已经打开的连接数
-	pendingOpens int                //待定的连接数?
+	pendingOpens int                //已经开始在后台打开, 还没有交给connRequest的连接数
 
-	// 这里解释似乎很复杂
-	openerCh chan struct{} //使用了空struct, 特点是size=0
-	closed   bool
-	dep      map[finalCloser]depSet
-	lastPut  map[*driverConn]string
-	maxIdle  int //保持的最大空闲连接数, 0表示黑夜空闲连接数, 负数表示无限
-	maxOpen  int //最多连接数,但感觉会超过这个设置, 直到暴出too many connections错误, <=0表示无限
+	closed  bool
+	dep     map[finalCloser]depSet
+	lastPut map[*driverConn]string
+	maxIdle int           //保持的最大空闲连接数, 0表示黑夜空闲连接数, 负数表示无限
+	maxOpen int           //最多连接数,但感觉会超过这个设置, 直到暴出too many connections错误, <=0表示无限
+	leak    *LeakDetector // 为nil表示没有开启泄漏检测, 见leak.go
 }
 
 const debugGetPut = false
@@ -96,12 +219,22 @@ func (db *DB) putConn(dc *driverConn, err error) {
 		if debugGetPut {
 			fmt.Printf("putConn(%v) DUPLICATE was : %s\n\nPREVIOUS was: %s", dc, stack(), db.lastPut[dc])
 		}
+		if db.leak != nil {
+			db.leak.logf("sql: putConn(%p) DUPLICATE was: %s\n\nPREVIOUS was: %s", dc, stack(), db.lastPut[dc])
+		}
 		panic("sql: connection returned that was never out")
 	}
-	if debugGetPut {
+	// debugGetPut是编译期的开关, 开启了LeakDetector时运行期也记录
+	if debugGetPut || db.leak != nil {
+		if db.lastPut == nil {
+			db.lastPut = make(map[*driverConn]string)
+		}
 		db.lastPut[dc] = stack()
 	}
-	db.inUse = false
+	if db.leak != nil {
+		db.leak.put(dc)
+	}
+	dc.inUse = false
 
 	for _, fn := range dc.onPut {
 		fn()
@@ -125,6 +258,7 @@ func (db *DB) putConn(dc *driverConn, err error) {
 	}
 }
 
+// maybeOpenNewConnections 在连接被关闭或者打开失败后, 为排队的connRequest在后台打开新连接
 // 需要保证db.mu.Lock()
 func (db *DB) maybeOpenNewConnections() {
 	numRequests := len(db.connRequests) - db.pendingOpens
@@ -137,11 +271,650 @@ func (db *DB) maybeOpenNewConnections() {
 	for numRequests > 0 {
 		db.pendingOpens++
 		numRequests--
-		db.openerCh <- struct{}{} //向此字段发空struct
+		// 调用者持有db.mu, 不能在这里打开连接
+		go db.openNewConnection()
+	}
+}
+
+// openNewConnection 打开一个连接交给排队的connRequest
+// 打开失败时把错误交给connRequest, 没有人排队了就关掉
+func (db *DB) openNewConnection() {
+	ci, err := db.driver.Open(db.dsn)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.pendingOpens--
+	if err != nil {
+		db.putConnDBLocked(nil, err)
+		db.maybeOpenNewConnections()
+		return
+	}
+	db.numOpen++
+	dc := &driverConn{db: db, ci: ci}
+	if db.putConnDBLocked(dc, nil) {
+		db.addDepLocked(dc, dc)
+		return
 	}
+	db.numOpen--
+	ci.Close()
 }
 
+// putConnDBLocked 把dc交给一个正在等待的connRequest, 或者放入空闲连接池
+// 返回false表示dc没有地方放, 调用者需要关闭它
+// 需要持有db.mu
 func (db *DB) putConnDBLocked(dc *driverConn, err error) bool {
+	if db.closed {
+		return false
+	}
+	if db.maxOpen > 0 && db.numOpen > db.maxOpen {
+		return false
+	}
+	if c := len(db.connRequests); c > 0 {
+		req := db.connRequests[0]
+		copy(db.connRequests, db.connRequests[1:])
+		db.connRequests = db.connRequests[:c-1]
+		if err == nil {
+			dc.inUse = true
+			db.noteGetLocked(dc)
+		}
+		req <- connRequest{conn: dc, err: err}
+		return true
+	} else if err == nil && db.maxIdleConnsLocked() > len(db.freeConn) {
+		db.freeConn = append(db.freeConn, dc)
+		return true
+	}
+	return false
+}
+
+const defaultMaxIdleConns = 2
+
+func (db *DB) maxIdleConnsLocked() int {
+	n := db.maxIdle
+	switch {
+	case n == 0:
+		return defaultMaxIdleConns
+	case n < 0:
+		return 0
+	default:
+		return n
+	}
+}
+
+var errDBClosed = errors.New("sql: database is closed")
+
+// conn 从连接池里取一个连接
+// 有空闲的连接就直接用, 已经达到maxOpen就排队等别人putConn, 否则新开一个
+// 在等待的时候ctx被取消会返回ctx.Err()
+func (db *DB) conn(ctx context.Context) (*driverConn, error) {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return nil, errDBClosed
+	}
+	select {
+	case <-ctx.Done():
+		db.mu.Unlock()
+		return nil, ctx.Err()
+	default:
+	}
+
+	if n := len(db.freeConn); n > 0 {
+		dc := db.freeConn[0]
+		copy(db.freeConn, db.freeConn[1:])
+		db.freeConn = db.freeConn[:n-1]
+		dc.inUse = true
+		db.noteGetLocked(dc)
+		db.mu.Unlock()
+		return dc, nil
+	}
+
+	if db.maxOpen > 0 && db.numOpen >= db.maxOpen {
+		// 带缓冲, putConnDBLocked发送时不会阻塞
+		req := make(chan connRequest, 1)
+		db.connRequests = append(db.connRequests, req)
+		db.mu.Unlock()
+
+		select {
+		case ret := <-req:
+			return ret.conn, ret.err
+		case <-ctx.Done():
+			db.mu.Lock()
+			for i, r := range db.connRequests {
+				if r == req {
+					db.connRequests = append(db.connRequests[:i], db.connRequests[i+1:]...)
+					break
+				}
+			}
+			db.mu.Unlock()
+			// 可能在取消的同时已经拿到了连接, 要还回去
+			select {
+			case ret := <-req:
+				if ret.err == nil && ret.conn != nil {
+					db.putConn(ret.conn, nil)
+				}
+			default:
+			}
+			return nil, ctx.Err()
+		}
+	}
+
+	db.numOpen++
+	db.mu.Unlock()
+	ci, err := db.driver.Open(db.dsn)
+	if err != nil {
+		db.mu.Lock()
+		db.numOpen--
+		db.maybeOpenNewConnections()
+		db.mu.Unlock()
+		return nil, err
+	}
+	db.mu.Lock()
+	dc := &driverConn{db: db, ci: ci, inUse: true}
+	db.addDepLocked(dc, dc)
+	db.noteGetLocked(dc)
+	db.mu.Unlock()
+	return dc, nil
+}
+
+// PingContext 检查数据库是否还能连上, 必要时会新建一个连接
+// 驱动实现了driver.Pinger时会调用它
+func (db *DB) PingContext(ctx context.Context) error {
+	dc, err := db.conn(ctx)
+	if err != nil {
+		return err
+	}
+	err = ctxDriverPing(ctx, dc)
+	db.releaseCtx(ctx, dc, err)
+	return err
+}
+
+// ExecContext 执行一个不返回结果集的语句
+// 驱动没有实现Execer时, 会先Prepare再执行
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	dc, err := db.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := execDC(ctx, dc, query, args)
+	db.releaseCtx(ctx, dc, err)
+	return res, err
+}
+
+// releaseCtx 把连接还回连接池
+// 如果是因为ctx结束而返回的, 驱动里的调用可能还在另一个goroutine里进行(见ctxShim),
+// 这个连接就不能再给别人用了, 当作坏连接关闭. 关闭时要等那个调用结束, 所以放到goroutine里
+func (db *DB) releaseCtx(ctx context.Context, dc *driverConn, err error) {
+	if err != nil && err == ctx.Err() {
+		go dc.releaseConn(driver.ErrBadConn)
+		return
+	}
+	dc.releaseConn(err)
+}
+
+// execDC 在dc上执行query, 不负责释放dc
+func execDC(ctx context.Context, dc *driverConn, query string, args []interface{}) (Result, error) {
+	nvdargs, err := driverArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	resi, err := ctxDriverExec(ctx, dc, query, nvdargs)
+	if err != driver.ErrSkip {
+		if err != nil {
+			return nil, err
+		}
+		return driverResult{dc, resi}, nil
+	}
+
+	dc.Lock()
+	si, err := dc.preparedLocked(query)
+	dc.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	dc.addDep(si)
+	resi, err = ctxDriverStmtExec(ctx, dc, si, nvdargs)
+	if err != nil && err == ctx.Err() {
+		// si还在被放弃的调用使用, 留在openStmt里等连接关闭时(finalClose)再关
+		dc.removeDep(si)
+		return nil, err
+	}
+	dc.closeStmt(si)
+	if err != nil {
+		return nil, err
+	}
+	return driverResult{dc, resi}, nil
+}
+
+// driverArgs 把用户传入的参数转为驱动能接受的NamedValue
+func driverArgs(args []interface{}) ([]driver.NamedValue, error) {
+	nvdargs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("sql: converting argument #%d's type: %v", i, err)
+		}
+		nvdargs[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nvdargs, nil
+}
+
+// Result 是Exec的执行结果
+type Result interface {
+	LastInsertId() (int64, error)
+	RowsAffected() (int64, error)
+}
+
+// driverResult 在调用driver.Result的方法时持有连接的锁
+type driverResult struct {
+	sync.Locker
+	resi driver.Result
+}
+
+func (dr driverResult) LastInsertId() (int64, error) {
+	dr.Lock()
+	defer dr.Unlock()
+	return dr.resi.LastInsertId()
+}
+
+func (dr driverResult) RowsAffected() (int64, error) {
+	dr.Lock()
+	defer dr.Unlock()
+	return dr.resi.RowsAffected()
+}
+
+// ErrConnDone 表示Conn已经被Close了
+var ErrConnDone = errors.New("sql: connection is already closed")
+
+// Conn 表示从连接池里拿出来的一个独占连接
+// 在Close之前, 这个连接不会被别人使用, 适合需要在同一个连接上执行多条语句的场景(比如会话变量)
+// 用完必须调用Close把连接还给连接池
+type Conn struct {
+	db *DB
+
+	mu   sync.Mutex // 保护下面的字段
+	dc   *driverConn
+	done bool
+	bad  bool // 有调用因为ctx被放弃了, 关闭时不能再放回连接池
+}
+
+// Conn 从连接池里取一个独占的连接
+func (db *DB) Conn(ctx context.Context) (*Conn, error) {
+	dc, err := db.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{db: db, dc: dc}, nil
+}
+
+func (c *Conn) grabConn() (*driverConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done {
+		return nil, ErrConnDone
+	}
+	return c.dc, nil
+}
+
+func (c *Conn) noteErr(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	if err == ctx.Err() || err == driver.ErrBadConn {
+		c.mu.Lock()
+		c.bad = true
+		c.mu.Unlock()
+	}
+}
+
+// PingContext 检查这个连接是否还可用
+func (c *Conn) PingContext(ctx context.Context) error {
+	dc, err := c.grabConn()
+	if err != nil {
+		return err
+	}
+	err = ctxDriverPing(ctx, dc)
+	c.noteErr(ctx, err)
+	return err
+}
+
+// ExecContext 在这个连接上执行一个不返回结果集的语句
+func (c *Conn) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	dc, err := c.grabConn()
+	if err != nil {
+		return nil, err
+	}
+	res, err := execDC(ctx, dc, query, args)
+	c.noteErr(ctx, err)
+	return res, err
+}
+
+// Close 把连接还给连接池, 重复调用返回ErrConnDone
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	if c.done {
+		c.mu.Unlock()
+		return ErrConnDone
+	}
+	c.done = true
+	dc, bad := c.dc, c.bad
+	c.dc = nil
+	c.mu.Unlock()
+
+	if bad {
+		go c.db.putConn(dc, driver.ErrBadConn)
+		return nil
+	}
+	c.db.putConn(dc, nil)
+	return nil
+}
+
+// QueryContext 执行一个返回结果集的查询
+// 返回的Rows持有一个连接, 用完必须Close, 连接才会回到连接池
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	dc, err := db.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queryDC(ctx, dc, func(err error) { db.releaseCtx(ctx, dc, err) }, query, args)
+}
+
+// QueryContext 在这个连接上执行一个返回结果集的查询
+// 返回的Rows必须在Conn.Close之前关闭
+func (c *Conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	dc, err := c.grabConn()
+	if err != nil {
+		return nil, err
+	}
+	return queryDC(ctx, dc, func(err error) { c.noteErr(ctx, err) }, query, args)
+}
+
+// queryDC 在dc上执行query, 出错时或者Rows关闭时调用releaseConn
+// 驱动没有实现Queryer时, 会先Prepare再执行, Prepare出来的语句在Rows关闭时一起关
+func queryDC(ctx context.Context, dc *driverConn, releaseConn func(error), query string, args []interface{}) (*Rows, error) {
+	nvdargs, err := driverArgs(args)
+	if err != nil {
+		releaseConn(err)
+		return nil, err
+	}
+	rowsi, err := ctxDriverQuery(ctx, dc, query, nvdargs)
+	if err != driver.ErrSkip {
+		if err != nil {
+			releaseConn(err)
+			return nil, err
+		}
+		rs := &Rows{dc: dc, releaseConn: releaseConn, rowsi: rowsi}
+		dc.addDep(rs)
+		return rs, nil
+	}
+
+	dc.Lock()
+	si, err := dc.preparedLocked(query)
+	dc.Unlock()
+	if err != nil {
+		releaseConn(err)
+		return nil, err
+	}
+	dc.addDep(si)
+	rowsi, err = ctxDriverStmtQuery(ctx, dc, si, nvdargs)
+	if err != nil {
+		// 与execDC一样, 被放弃的调用还在用si, 留给finalClose去关
+		if err != ctx.Err() {
+			dc.closeStmt(si)
+		} else {
+			dc.removeDep(si)
+		}
+		releaseConn(err)
+		return nil, err
+	}
+	rs := &Rows{dc: dc, releaseConn: releaseConn, rowsi: rowsi, closeStmt: si}
+	dc.addDep(rs)
+	return rs, nil
+}
+
+// Rows 是查询的结果集, 用Next逐行前进, 用Scan读取当前行
+type Rows struct {
+	dc          *driverConn
+	releaseConn func(error)
+	rowsi       driver.Rows
+	closeStmt   driver.Stmt // 不为nil时, Close的时候一起关闭
+
+	mu       sync.Mutex // 保护下面的字段
+	closed   bool
+	lastcols []driver.Value
+	lasterr  error // 最后一次Next的错误, 正常结束时为io.EOF
+}
+
+// Next 前进到下一行, 没有更多的行或者出错时返回false并关闭Rows
+// 出错的原因用Err查看
+func (rs *Rows) Next() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed {
+		return false
+	}
+	if rs.lastcols == nil {
+		rs.lastcols = make([]driver.Value, len(rs.rowsi.Columns()))
+	}
+	rs.dc.Lock()
+	rs.lasterr = rs.rowsi.Next(rs.lastcols)
+	rs.dc.Unlock()
+	if rs.lasterr != nil {
+		rs.closeLocked()
+		return false
+	}
+	return true
+}
+
+// Err 返回遍历时遇到的错误, 正常结束时为nil
+func (rs *Rows) Err() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.lasterr == io.EOF {
+		return nil
+	}
+	return rs.lasterr
+}
+
+// Columns 返回列名
+func (rs *Rows) Columns() ([]string, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed {
+		return nil, errors.New("sql: Rows are closed")
+	}
+	rs.dc.Lock()
+	defer rs.dc.Unlock()
+	return rs.rowsi.Columns(), nil
+}
+
+// Scan 把当前行的各列复制到dest里, 规则见convertAssign
+func (rs *Rows) Scan(dest ...interface{}) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed {
+		return errors.New("sql: Rows are closed")
+	}
+	if rs.lastcols == nil {
+		return errors.New("sql: Scan called without calling Next")
+	}
+	if len(dest) != len(rs.lastcols) {
+		return fmt.Errorf("sql: expected %d destination arguments in Scan, not %d", len(rs.lastcols), len(dest))
+	}
+	for i, sv := range rs.lastcols {
+		if err := convertAssign(dest[i], sv); err != nil {
+			return fmt.Errorf("sql: Scan error on column index %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭Rows并释放连接, 可以重复调用
+func (rs *Rows) Close() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.closeLocked()
+}
+
+func (rs *Rows) closeLocked() error {
+	if rs.closed {
+		return nil
+	}
+	rs.closed = true
+	rs.dc.Lock()
+	err := rs.rowsi.Close()
+	rs.dc.Unlock()
+	if rs.closeStmt != nil {
+		rs.dc.closeStmt(rs.closeStmt)
+	}
+	rs.dc.removeDep(rs)
+	rs.releaseConn(err)
+	return err
+}
+
+// IsolationLevel 是事务的隔离级别, 驱动不支持指定的级别时BeginTx会返回错误
+type IsolationLevel int
+
+// 这些值会原样传给驱动的driver.TxOptions
+const (
+	LevelDefault IsolationLevel = iota
+	LevelReadUncommitted
+	LevelReadCommitted
+	LevelWriteCommitted
+	LevelRepeatableRead
+	LevelSnapshot
+	LevelSerializable
+	LevelLinearizable
+)
+
+// TxOptions 是BeginTx的选项, 为nil时使用驱动的默认值
+type TxOptions struct {
+	Isolation IsolationLevel
+	ReadOnly  bool
+}
+
+func (opts *TxOptions) driverOptions() driver.TxOptions {
+	if opts == nil {
+		return driver.TxOptions{}
+	}
+	return driver.TxOptions{Isolation: driver.IsolationLevel(opts.Isolation), ReadOnly: opts.ReadOnly}
+}
+
+// ErrTxDone 表示事务已经Commit或者Rollback过了
+var ErrTxDone = errors.New("sql: transaction has already been committed or rolled back")
+
+// BeginTx 开启一个事务, 事务会独占一个连接直到Commit或者Rollback
+// ctx只控制开启事务这一步, 之后的语句用各自的ctx
+func (db *DB) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
+	dc, err := db.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txi, err := ctxDriverBegin(ctx, dc, opts.driverOptions())
+	if err != nil {
+		db.releaseCtx(ctx, dc, err)
+		return nil, err
+	}
+	return &Tx{dc: dc, txi: txi, releaseConn: dc.releaseConn}, nil
+}
+
+// BeginTx 在这个连接上开启一个事务, 事务结束之前不要在Conn上执行别的语句
+func (c *Conn) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
+	dc, err := c.grabConn()
+	if err != nil {
+		return nil, err
+	}
+	txi, err := ctxDriverBegin(ctx, dc, opts.driverOptions())
+	if err != nil {
+		c.noteErr(ctx, err)
+		return nil, err
+	}
+	// 连接属于Conn, 事务结束时不还给连接池, 只把坏连接的状态告诉Conn
+	return &Tx{dc: dc, txi: txi, releaseConn: func(err error) { c.noteErr(context.Background(), err) }}, nil
+}
+
+// Tx 是一个进行中的事务, 必须以Commit或者Rollback结束
+type Tx struct {
+	dc          *driverConn
+	txi         driver.Tx
+	releaseConn func(error) // 事务结束时调用, 参数为driver.ErrBadConn表示连接不能再用了
+
+	mu   sync.Mutex // 保护下面的字段
+	done bool
+	bad  bool // 有调用因为ctx被放弃了
+}
+
+func (tx *Tx) grabConn() (*driverConn, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	return tx.dc, nil
+}
+
+func (tx *Tx) noteErr(ctx context.Context, err error) {
+	if err != nil && (err == ctx.Err() || err == driver.ErrBadConn) {
+		tx.mu.Lock()
+		tx.bad = true
+		tx.mu.Unlock()
+	}
+}
+
+// end 结束事务, fn是Commit或者Rollback
+func (tx *Tx) end(fn func() error) error {
+	tx.mu.Lock()
+	if tx.done {
+		tx.mu.Unlock()
+		return ErrTxDone
+	}
+	tx.done = true
+	bad := tx.bad
+	tx.mu.Unlock()
+
+	if bad {
+		// 被放弃的调用可能还持有dc的锁, 不能在这里等它
+		go tx.releaseConn(driver.ErrBadConn)
+		return driver.ErrBadConn
+	}
+	tx.dc.Lock()
+	err := fn()
+	tx.dc.Unlock()
+	if err == driver.ErrBadConn {
+		tx.releaseConn(driver.ErrBadConn)
+	} else {
+		tx.releaseConn(nil)
+	}
+	return err
+}
+
+// Commit 提交事务
+func (tx *Tx) Commit() error {
+	return tx.end(tx.txi.Commit)
+}
+
+// Rollback 回滚事务
+func (tx *Tx) Rollback() error {
+	return tx.end(tx.txi.Rollback)
+}
+
+// ExecContext 在事务里执行一个不返回结果集的语句
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	dc, err := tx.grabConn()
+	if err != nil {
+		return nil, err
+	}
+	res, err := execDC(ctx, dc, query, args)
+	tx.noteErr(ctx, err)
+	return res, err
+}
+
+// QueryContext 在事务里执行一个返回结果集的查询
+// 返回的Rows必须在Commit或者Rollback之前关闭
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	dc, err := tx.grabConn()
+	if err != nil {
+		return nil, err
+	}
+	return queryDC(ctx, dc, func(err error) { tx.noteErr(ctx, err) }, query, args)
 }
 
 // 一个带锁的实现了driver.Conn接口的对象
@@ -173,6 +946,30 @@ func (dc *driverConn) removeOpenStmt(si driver.Stmt) {
 	delete(dc.openStmt, si)
 }
 
+// closeStmt 关闭preparedLocked得到的si, 并移除它的记录与依赖
+func (dc *driverConn) closeStmt(si driver.Stmt) {
+	dc.removeOpenStmt(si)
+	dc.Lock()
+	si.Close()
+	dc.Unlock()
+	dc.removeDep(si)
+}
+
+// addDep 记录dep在dc上还没有关闭, 例如Rows与prepared statement, LeakDetector.Report会列出它们
+func (dc *driverConn) addDep(dep interface{}) {
+	dc.db.mu.Lock()
+	dc.db.addDepLocked(dc, dep)
+	dc.db.mu.Unlock()
+}
+
+// removeDep 与addDep成对出现, dc已经Close并且dep是最后一个依赖时会调用finalClose
+func (dc *driverConn) removeDep(dep interface{}) error {
+	dc.db.mu.Lock()
+	fn := dc.db.removeDepLocked(dc, dep)
+	dc.db.mu.Unlock()
+	return fn()
+}
+
 // 是指prepared statement locked?
 // yes
 func (dc *driverConn) preparedLocked(query string) (driver.Stmt, error) {
@@ -193,7 +990,7 @@ func (dc *driverConn) closeDBLocked() func() error {
 		return func() error { return errors.New("sql: duplicate driverConn close") }
 	}
 	dc.closed = true
-	return dc.db.rremoveDepLocked(dc, dc)
+	return dc.db.removeDepLocked(dc, dc)
 }
 
 func (dc *driverConn) Close() error {
@@ -207,7 +1004,7 @@ func (dc *driverConn) Close() error {
 
 	dc.db.mu.Lock()
 	dc.dbmuClosed = true
-	fn := dc.db.remoceDepLocked(dc, dc)
+	fn := dc.db.removeDepLocked(dc, dc)
 	dc.db.mu.Unlock()
 	return fn()
 }
@@ -226,6 +1023,7 @@ func (dc *driverConn) finalClose() error {
 	dc.Unlock()
 
 	dc.db.mu.Lock()
+	delete(dc.db.lastPut, dc)
 	dc.db.numOpen--
 	dc.db.maybeOpenNewConnections()
 	dc.db.mu.Unlock()
@@ -252,6 +1050,40 @@ type finalCloser interface {
 	finalClose() error
 }
 
+// addDepLocked 记录x依赖于dep, x在所有dep都被移除之后才会真正关闭
+// 需要持有db.mu
+func (db *DB) addDepLocked(x finalCloser, dep interface{}) {
+	if db.dep == nil {
+		db.dep = make(map[finalCloser]depSet)
+	}
+	xdep := db.dep[x]
+	if xdep == nil {
+		xdep = make(depSet)
+		db.dep[x] = xdep
+	}
+	xdep[dep] = true
+}
+
+// removeDepLocked 移除x对dep的依赖, 返回的函数在x没有依赖了时调用x.finalClose
+// 返回函数而不是直接关闭, 是为了让调用者在释放db.mu之后再执行
+func (db *DB) removeDepLocked(x finalCloser, dep interface{}) func() error {
+	xdep, ok := db.dep[x]
+	if !ok {
+		panic(fmt.Sprintf("unpaired removeDep: no deps for %T", x))
+	}
+	l0 := len(xdep)
+	delete(xdep, dep)
+	switch len(xdep) {
+	case l0:
+		panic(fmt.Sprintf("unpaired removeDep: no %T dep on %T", dep, x))
+	case 0:
+		delete(db.dep, x)
+		return x.finalClose
+	default:
+		return func() error { return nil }
+	}
+}
+
 func stack() string {
 	var buf [2 << 10]byte
 	return string(buf[:runtime.Stack(buf[:], false)])
@@ -0,0 +1,229 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestNullString(t *testing.T) {
+	var ns NullString
+	if err := ns.Scan("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if !ns.Valid || ns.String != "foo" {
+		t.Errorf("Scan(\"foo\") = %+v", ns)
+	}
+	if err := ns.Scan([]byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if !ns.Valid || ns.String != "bar" {
+		t.Errorf("Scan([]byte(\"bar\")) = %+v", ns)
+	}
+	if err := ns.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Valid || ns.String != "" {
+		t.Errorf("Scan(nil) = %+v", ns)
+	}
+	if v, err := ns.Value(); err != nil || v != nil {
+		t.Errorf("Value() of NULL = %v, %v; want nil, nil", v, err)
+	}
+	ns = NullString{String: "x", Valid: true}
+	if v, err := ns.Value(); err != nil || v != "x" {
+		t.Errorf("Value() = %v, %v; want x, nil", v, err)
+	}
+}
+
+func TestNullInt64(t *testing.T) {
+	var n NullInt64
+	if err := n.Scan(int64(42)); err != nil {
+		t.Fatal(err)
+	}
+	if !n.Valid || n.Int64 != 42 {
+		t.Errorf("Scan(42) = %+v", n)
+	}
+	if err := n.Scan([]byte("-7")); err != nil {
+		t.Fatal(err)
+	}
+	if n.Int64 != -7 {
+		t.Errorf("Scan(\"-7\") = %+v", n)
+	}
+	if err := n.Scan("abc"); err == nil {
+		t.Error("Scan(\"abc\") should fail")
+	}
+	if err := n.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if n.Valid || n.Int64 != 0 {
+		t.Errorf("Scan(nil) = %+v", n)
+	}
+	if v, err := n.Value(); err != nil || v != nil {
+		t.Errorf("Value() of NULL = %v, %v", v, err)
+	}
+	n = NullInt64{Int64: 3, Valid: true}
+	if v, err := n.Value(); err != nil || v != int64(3) {
+		t.Errorf("Value() = %v, %v; want 3, nil", v, err)
+	}
+}
+
+func TestNullFloat64(t *testing.T) {
+	var n NullFloat64
+	if err := n.Scan(1.5); err != nil {
+		t.Fatal(err)
+	}
+	if !n.Valid || n.Float64 != 1.5 {
+		t.Errorf("Scan(1.5) = %+v", n)
+	}
+	if err := n.Scan("2.25"); err != nil {
+		t.Fatal(err)
+	}
+	if n.Float64 != 2.25 {
+		t.Errorf("Scan(\"2.25\") = %+v", n)
+	}
+	if err := n.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if n.Valid {
+		t.Errorf("Scan(nil) = %+v", n)
+	}
+	n = NullFloat64{Float64: 0.5, Valid: true}
+	if v, err := n.Value(); err != nil || v != 0.5 {
+		t.Errorf("Value() = %v, %v; want 0.5, nil", v, err)
+	}
+}
+
+func TestNullBool(t *testing.T) {
+	var n NullBool
+	for _, src := range []interface{}{true, int64(1), "true", []byte("1")} {
+		n = NullBool{}
+		if err := n.Scan(src); err != nil {
+			t.Fatalf("Scan(%#v): %v", src, err)
+		}
+		if !n.Valid || !n.Bool {
+			t.Errorf("Scan(%#v) = %+v", src, n)
+		}
+	}
+	if err := n.Scan("maybe"); err == nil {
+		t.Error("Scan(\"maybe\") should fail")
+	}
+	if err := n.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if n.Valid || n.Bool {
+		t.Errorf("Scan(nil) = %+v", n)
+	}
+	if v, err := n.Value(); err != nil || v != nil {
+		t.Errorf("Value() of NULL = %v, %v", v, err)
+	}
+	n = NullBool{Bool: true, Valid: true}
+	if v, err := n.Value(); err != nil || v != true {
+		t.Errorf("Value() = %v, %v; want true, nil", v, err)
+	}
+}
+
+func TestNullTime(t *testing.T) {
+	want := time.Date(2017, 3, 4, 5, 6, 7, 8, time.UTC)
+	for _, src := range []interface{}{want, want.Format(time.RFC3339Nano), []byte(want.Format(time.RFC3339Nano))} {
+		var n NullTime
+		if err := n.Scan(src); err != nil {
+			t.Fatalf("Scan(%#v): %v", src, err)
+		}
+		if !n.Valid || !n.Time.Equal(want) {
+			t.Errorf("Scan(%#v) = %+v; want %v", src, n, want)
+		}
+	}
+	var n NullTime
+	if err := n.Scan("yesterday"); err == nil {
+		t.Error("Scan(\"yesterday\") should fail")
+	}
+	if err := n.Scan(int64(1)); err == nil {
+		t.Error("Scan(int64) should fail")
+	}
+	if err := n.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if n.Valid || !n.Time.IsZero() {
+		t.Errorf("Scan(nil) = %+v", n)
+	}
+	if v, err := n.Value(); err != nil || v != nil {
+		t.Errorf("Value() of NULL = %v, %v", v, err)
+	}
+	n = NullTime{Time: want, Valid: true}
+	v, err := n.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tv, ok := v.(time.Time); !ok || !tv.Equal(want) {
+		t.Errorf("Value() = %v; want %v", v, want)
+	}
+}
+
+// Null类型的Value结果要能被默认的参数转换接受, 这样才能作为Exec的参数
+func TestNullValuer(t *testing.T) {
+	for _, v := range []driver.Valuer{
+		NullString{String: "a", Valid: true},
+		NullInt64{Int64: 1, Valid: true},
+		NullFloat64{Float64: 1, Valid: true},
+		NullBool{Bool: true, Valid: true},
+		NullTime{Time: time.Now(), Valid: true},
+		NullString{},
+	} {
+		if _, err := driver.DefaultParameterConverter.ConvertValue(v); err != nil {
+			t.Errorf("ConvertValue(%T): %v", v, err)
+		}
+	}
+}
+
+// 连接因为坏掉或者ctx被取消而关闭之后, 排队的conn要拿到一个新打开的连接
+func TestConnRequestAfterDiscard(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tt := range []struct {
+		name    string
+		release func(db *DB, dc *driverConn)
+	}{
+		{"ErrBadConn", func(db *DB, dc *driverConn) { dc.releaseConn(driver.ErrBadConn) }},
+		{"canceled", func(db *DB, dc *driverConn) { db.releaseCtx(canceled, dc, canceled.Err()) }},
+	} {
+		d := &fakeDriver{}
+		db := &DB{driver: d, maxOpen: 1}
+		dc, err := db.conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(chan error, 1)
+		go func() {
+			dc2, err := db.conn(context.Background())
+			if err == nil {
+				dc2.releaseConn(nil)
+			}
+			got <- err
+		}()
+		for {
+			db.mu.Lock()
+			n := len(db.connRequests)
+			db.mu.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		tt.release(db, dc)
+		select {
+		case err := <-got:
+			if err != nil {
+				t.Errorf("%s: waiting conn: %v", tt.name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: waiting conn was never served", tt.name)
+		}
+		db.mu.Lock()
+		numOpen, free := db.numOpen, len(db.freeConn)
+		db.mu.Unlock()
+		if numOpen != 1 || free != 1 || d.numClosed() != 1 {
+			t.Errorf("%s: numOpen = %d, freeConn = %d, closed = %d; want 1, 1, 1", tt.name, numOpen, free, d.numClosed())
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package json
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 规范化的JSON(RFC 8785, JSON Canonicalization Scheme)
+// 同一个值总是编码成同样的字节, 可以用来签名或者计算哈希:
+//
+//	没有空白;
+//	对象的key在每一层都按UTF-16编码单元排序;
+//	数字按ECMAScript的规则输出最短的能还原的形式, 例如1e+21, 1e-7, 0.1;
+//	字符串只转义", \与控制字符, 其它字符(包括<, >, &与非ASCII字符)原样输出
+
+// MarshalCanonical 和Marshal一样, 但是输出规范化的JSON
+func MarshalCanonical(v interface{}) ([]byte, error) {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+	if err := e.marshal(v, false); err != nil {
+		return nil, err
+	}
+	return Canonicalize(e.Bytes())
+}
+
+// Canonicalize 把已有的JSON文档重新编码成规范化的形式
+// 文档里不能有非法的UTF-8, 也不能有超出float64范围的数字
+func Canonicalize(raw []byte) ([]byte, error) {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+	if err := e.canonicalize(raw); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.Bytes()...), nil
+}
+
+// canonicalize 把raw规范化以后写进e
+func (e *encodeState) canonicalize(raw []byte) error {
+	if !utf8.Valid(raw) {
+		return &InvalidUTF8Error{string(raw)}
+	}
+	v, err := decodeTree(raw)
+	if err != nil {
+		return err
+	}
+	return e.canonicalValue(v)
+}
+
+func (e *encodeState) canonicalValue(v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		e.WriteString("null")
+	case bool:
+		if x {
+			e.WriteString("true")
+		} else {
+			e.WriteString("false")
+		}
+	case string:
+		e.canonicalString(x)
+	case Number:
+		f, err := strconv.ParseFloat(string(x), 64)
+		if err != nil {
+			return &UnsupportedValueError{Str: "number " + string(x) + " out of range"}
+		}
+		return e.canonicalNumber(f)
+	case float64:
+		return e.canonicalNumber(x)
+	case []interface{}:
+		e.WriteByte('[')
+		for i, elem := range x {
+			if i > 0 {
+				e.WriteByte(',')
+			}
+			if err := e.canonicalValue(elem); err != nil {
+				return err
+			}
+		}
+		e.WriteByte(']')
+	case map[string]interface{}:
+		keys := make(utf16Keys, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Sort(keys)
+		e.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				e.WriteByte(',')
+			}
+			e.canonicalString(k)
+			e.WriteByte(':')
+			if err := e.canonicalValue(x[k]); err != nil {
+				return err
+			}
+		}
+		e.WriteByte('}')
+	default:
+		return &UnsupportedValueError{Str: "unexpected " + kindName(v)}
+	}
+	return nil
+}
+
+// canonicalNumber 按ECMAScript的Number.prototype.toString输出f
+// 和floatEncoder的规则一样, 只是-0输出为0
+func (e *encodeState) canonicalNumber(f float64) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return &UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	if f == 0 {
+		e.WriteByte('0')
+		return nil
+	}
+	abs := math.Abs(f)
+	fmt := byte('f')
+	if abs < 1e-6 || abs >= 1e21 {
+		fmt = 'e'
+	}
+	b := strconv.AppendFloat(e.scratch[:0], f, fmt, -1, 64)
+	if fmt == 'e' {
+		// e-07 改成 e-7
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	e.Write(b)
+	return nil
+}
+
+// canonicalString 按RFC 8785输出字符串
+// 有短转义形式的控制字符用短形式, 其它的用小写的\u00xx
+func (e *encodeState) canonicalString(s string) {
+	e.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			continue
+		}
+		if start < i {
+			e.WriteString(s[start:i])
+		}
+		switch c {
+		case '"', '\\':
+			e.WriteByte('\\')
+			e.WriteByte(c)
+		case '\b':
+			e.WriteString(`\b`)
+		case '\f':
+			e.WriteString(`\f`)
+		case '\n':
+			e.WriteString(`\n`)
+		case '\r':
+			e.WriteString(`\r`)
+		case '\t':
+			e.WriteString(`\t`)
+		default:
+			e.WriteString(`\u00`)
+			e.WriteByte(hex[c>>4])
+			e.WriteByte(hex[c&0xF])
+		}
+		start = i + 1
+	}
+	if start < len(s) {
+		e.WriteString(s[start:])
+	}
+	e.WriteByte('"')
+}
+
+// utf16Keys 按UTF-16编码单元排序, 这和按UTF-8字节排序在BMP以外的字符上结果不同
+type utf16Keys []string
+
+func (k utf16Keys) Len() int      { return len(k) }
+func (k utf16Keys) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k utf16Keys) Less(i, j int) bool {
+	a, b := utf16.Encode([]rune(k[i])), utf16.Encode([]rune(k[j]))
+	for n := 0; n < len(a) && n < len(b); n++ {
+		if a[n] != b[n] {
+			return a[n] < b[n]
+		}
+	}
+	return len(a) < len(b)
+}
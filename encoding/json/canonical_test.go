@@ -0,0 +1,90 @@
+package json
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+var canonicalTests = []struct {
+	in, want string
+}{
+	{` { "b" : 1 , "a" : [ true , false , null ] } `, `{"a":[true,false,null],"b":1}`},
+	{`{"z":{"y":1,"x":2},"a":{}}`, `{"a":{},"z":{"x":2,"y":1}}`},
+	// RFC 8785 3.2.2.3
+	{`[333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001, -0, 1e21, 1e-7, 1e-6, 100]`,
+		`[333333333.3333333,1e+30,4.5,0.002,1e-27,0,1e+21,1e-7,0.000001,100]`},
+	// RFC 8785 3.2.2.2
+	{`"\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/"`, `"` + "\xe2\x82\xac" + `$\u000f\nA'B\"\\\\\"/"`},
+	{`"<&>\b\f\r\t\u0001"`, `"<&>\b\f\r\t\u0001"`},
+}
+
+func TestCanonicalize(t *testing.T) {
+	for _, tt := range canonicalTests {
+		got, err := Canonicalize([]byte(tt.in))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Canonicalize(%s) = %s, %v; want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCanonicalKeyOrder(t *testing.T) {
+	// RFC 8785 3.2.3: 按UTF-16排序, U+1F600(代理对D83D DE00)排在U+FB33前面
+	m := map[string]int{}
+	for i, r := range []rune{0x20ac, '\r', 0xfb33, '1', 0x1f600, 0x80, 0xf6} {
+		m[string(r)] = i
+	}
+	b, err := MarshalCanonical(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []rune
+	for _, r := range string(b) {
+		if r == '"' || r == '{' || r == '}' || r == ':' || r == ',' || r == '\\' || '0' <= r && r <= '9' || r == 'r' {
+			continue
+		}
+		keys = append(keys, r)
+	}
+	want := []rune{0x80, 0xf6, 0x20ac, 0x1f600, 0xfb33}
+	if string(keys) != string(want) {
+		t.Errorf("key order = %q, want %q (output %s)", string(keys), string(want), b)
+	}
+	if !bytes.HasPrefix(b, []byte(`{"\r":1,"1":3,`)) {
+		t.Errorf("output %s", b)
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	v := struct {
+		B string            `json:"b"`
+		A map[string]uint64 `json:"a"`
+	}{"<x>", map[string]uint64{"n": 1 << 60}}
+	b, err := MarshalCanonical(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超过2^53的整数按float64输出, 和ECMAScript一致
+	if want := `{"a":{"n":1152921504606847000},"b":"<x>"}`; string(b) != want {
+		t.Errorf("MarshalCanonical = %s, want %s", b, want)
+	}
+	if _, err := MarshalCanonical(math.Inf(1)); err == nil {
+		t.Error("MarshalCanonical(Inf) succeeded")
+	}
+	if _, err := Canonicalize([]byte("\"\xff\"")); err == nil {
+		t.Error("Canonicalize accepted invalid UTF-8")
+	}
+	if _, err := Canonicalize([]byte("1e400")); err == nil {
+		t.Error("Canonicalize accepted 1e400")
+	}
+}
+
+func TestEncoderCanonical(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetCanonical(true)
+	enc.SetIndent("", "  ")
+	enc.Encode(map[string]interface{}{"b": 1.0, "a": "<"})
+	if want := "{\"a\":\"<\",\"b\":1}\n"; buf.String() != want {
+		t.Errorf("Encode = %q, want %q", buf.String(), want)
+	}
+}
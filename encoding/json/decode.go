@@ -0,0 +1,1069 @@
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Unmarshal 把JSON数据解码到v指向的值里, v必须是非nil的指针
+//
+// 规则和Marshal相反:
+// 解码到指针时, JSON的null把指针置为nil, 否则指针为nil时先分配一个新值;
+// 解码到实现了Unmarshaler的值时调用它的UnmarshalJSON, null也会传给它;
+// 解码到结构体时, key与字段名(或者tag里的名字)匹配, 先精确匹配, 再不区分大小写匹配,
+// 没有对应字段的key被忽略(除非Decoder.DisallowUnknownFields);
+// 解码到interface{}时, 使用下面的类型:
+//
+//	bool, float64(UseNumber时为Number), string, []interface{}, map[string]interface{}, nil
+//
+// 类型不匹配的值会被跳过, 剩下的继续解码, 最后返回第一个UnmarshalTypeError
+// JSON本身不合法时返回SyntaxError, 此时v不会被修改
+func Unmarshal(data []byte, v interface{}) error {
+	// 先检查一遍语法, 后面解码的时候就可以假设数据是合法的
+	var d decodeState
+	err := checkValid(data, &d.scan)
+	if err != nil {
+		return err
+	}
+
+	d.init(data)
+	return d.unmarshal(v)
+}
+
+// Unmarshaler 是可以自己解码JSON的类型
+// 传入的是一个完整的JSON值, 如果要在返回后继续使用, 必须复制一份
+// 按约定, UnmarshalJSON([]byte("null"))什么也不做
+type Unmarshaler interface {
+	UnmarshalJSON([]byte) error
+}
+
+// UnmarshalTypeError 表示JSON值不能赋给某个Go类型
+type UnmarshalTypeError struct {
+	Value  string       // JSON值的描述, 比如"bool", "array", "number -5"
+	Type   reflect.Type // 不能赋值的Go类型
+	Offset int64        // 出错的值在输入里的位置
+	Struct string       // 所在的结构体的名字
+	Field  string       // 从最外层开始的字段路径, 用.分隔
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Struct != "" || e.Field != "" {
+		return "json: cannot unmarshal " + e.Value + " into Go struct field " + e.Struct + "." + e.Field + " of type " + e.Type.String()
+	}
+	return "json: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+}
+
+// InvalidUnmarshalError 表示传给Unmarshal的参数不是非nil指针
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "json: Unmarshal(nil)"
+	}
+
+	if e.Type.Kind() != reflect.Ptr {
+		return "json: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "json: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// Number 是一个JSON数字的原始文本
+// Decoder.UseNumber之后, 解码到interface{}的数字是Number而不是float64, 这样大整数不会丢精度
+type Number string
+
+// String 返回数字的原始文本
+func (n Number) String() string { return string(n) }
+
+// Float64 把数字解析为float64
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 把数字解析为int64
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Uint64 把数字解析为uint64, 超过int64范围的ID之类的大整数用它
+func (n Number) Uint64() (uint64, error) {
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+// Rat 把数字精确地解析为有理数, 小数不会像float64那样有舍入误差
+func (n Number) Rat() (*big.Rat, bool) {
+	return new(big.Rat).SetString(string(n))
+}
+
+var numberType = reflect.TypeOf(Number(""))
+
+// isValidNumber 判断s是不是一个合法的JSON数字
+func isValidNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	// 可选的负号
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+
+	// 整数部分
+	switch {
+	default:
+		return false
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// 小数部分
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// 指数部分
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	return s == ""
+}
+
+// decodeState 是解码时的状态
+// 数据在解码之前已经用checkValid检查过了, 所以这里直接递归下降, 不需要再处理语法错误
+type decodeState struct {
+	data []byte
+	off  int // 下一个要读的字节
+	scan scanner
+
+	errorContext struct {
+		Struct     reflect.Type
+		FieldStack []string
+	}
+	savedError error
+
+	useNumber             bool
+	disallowUnknownFields bool
+	strict                bool // 拒绝重复的key与非法的UTF-8, 见checkStrict
+}
+
+func (d *decodeState) init(data []byte) *decodeState {
+	d.data = data
+	d.off = 0
+	d.savedError = nil
+	d.errorContext.Struct = nil
+	d.errorContext.FieldStack = d.errorContext.FieldStack[:0]
+	return d
+}
+
+func (d *decodeState) unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	if d.strict {
+		if err := checkStrict(d.data); err != nil {
+			return err
+		}
+	}
+
+	// rv本身是不能Set的, 交给indirect去取Elem
+	if err := d.value(rv); err != nil {
+		return d.addErrorContext(err)
+	}
+	return d.savedError
+}
+
+// saveError 记录第一个不致命的错误, 解码继续进行
+func (d *decodeState) saveError(err error) {
+	if d.savedError == nil {
+		d.savedError = d.addErrorContext(err)
+	}
+}
+
+// addErrorContext 给UnmarshalTypeError加上所在的结构体与字段
+func (d *decodeState) addErrorContext(err error) error {
+	if d.errorContext.Struct != nil || len(d.errorContext.FieldStack) > 0 {
+		switch err := err.(type) {
+		case *UnmarshalTypeError:
+			if d.errorContext.Struct != nil {
+				err.Struct = d.errorContext.Struct.Name()
+			}
+			err.Field = strings.Join(d.errorContext.FieldStack, ".")
+			return err
+		}
+	}
+	return err
+}
+
+func (d *decodeState) skipSpace() {
+	for d.off < len(d.data) && isSpace(d.data[d.off]) {
+		d.off++
+	}
+}
+
+// skipString 跳过d.off处的字符串, 包括两边的引号
+func (d *decodeState) skipString() {
+	i := d.off + 1
+	for i < len(d.data) {
+		switch d.data[i] {
+		case '\\':
+			i += 2
+			continue
+		case '"':
+			d.off = i + 1
+			return
+		}
+		i++
+	}
+	d.off = len(d.data)
+}
+
+// skipLiteral 跳过数字或者true/false/null
+func (d *decodeState) skipLiteral() {
+	for d.off < len(d.data) {
+		switch c := d.data[d.off]; {
+		case c == ',' || c == '}' || c == ']' || c == ':' || isSpace(c):
+			return
+		}
+		d.off++
+	}
+}
+
+// skip 跳过d.off处的一个完整的值
+func (d *decodeState) skip() {
+	d.skipSpace()
+	depth := 0
+	for d.off < len(d.data) {
+		switch d.data[d.off] {
+		case '"':
+			d.skipString()
+			if depth == 0 {
+				return
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			d.off++
+			if depth == 0 {
+				return
+			}
+			continue
+		default:
+			if depth == 0 {
+				d.skipLiteral()
+				return
+			}
+		}
+		d.off++
+	}
+}
+
+// rawValue 跳过一个值, 返回它的原始字节
+func (d *decodeState) rawValue() []byte {
+	d.skipSpace()
+	start := d.off
+	d.skip()
+	return d.data[start:d.off]
+}
+
+// value 把d.off处的值解码到v, v无效时跳过这个值
+// 返回的错误是致命的(比如UnmarshalJSON返回的错误), 类型不匹配的错误用saveError记录
+func (d *decodeState) value(v reflect.Value) error {
+	d.skipSpace()
+	if !v.IsValid() {
+		d.skip()
+		return nil
+	}
+	switch d.data[d.off] {
+	case '{':
+		return d.object(v)
+	case '[':
+		return d.array(v)
+	default:
+		start := d.off
+		if d.data[d.off] == '"' {
+			d.skipString()
+		} else {
+			d.skipLiteral()
+		}
+		return d.literalStore(d.data[start:d.off], v, false)
+	}
+}
+
+// indirect 顺着指针往下走, 必要时分配新值, 直到遇到非指针
+// 中途遇到实现了Unmarshaler或者TextUnmarshaler的值就返回它
+// decodingNull为true时, 在最后一个可以设置为nil的指针处停下
+func indirect(v reflect.Value, decodingNull bool) (Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
+	// 可寻址的非指针值, 先取地址, 这样指针接收者的UnmarshalJSON也能找到
+	if v.Kind() != reflect.Ptr && v.Type().Name() != "" && v.CanAddr() {
+		v = v.Addr()
+	}
+	for {
+		// interface里放的是非nil指针时, 解码到这个指针所指的值里
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Ptr && !e.IsNil() && (!decodingNull || e.Elem().Kind() == reflect.Ptr) {
+				v = e
+				continue
+			}
+		}
+
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+
+		if decodingNull && v.CanSet() {
+			break
+		}
+
+		// 指向自己的interface, 比如 var x interface{}; x = &x
+		if v.Elem().Kind() == reflect.Interface && v.Elem().Elem() == v {
+			v = v.Elem()
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(Unmarshaler); ok {
+				return u, nil, reflect.Value{}
+			}
+			if !decodingNull {
+				if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+					return nil, u, reflect.Value{}
+				}
+			}
+		}
+		v = v.Elem()
+	}
+	return nil, nil, v
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// array 解码一个数组, 可以解码到slice, array与interface{}
+func (d *decodeState) array(v reflect.Value) error {
+	u, ut, pv := indirect(v, false)
+	if u != nil {
+		return u.UnmarshalJSON(d.rawValue())
+	}
+	if ut != nil {
+		d.saveError(&UnmarshalTypeError{Value: "array", Type: v.Type(), Offset: int64(d.off)})
+		d.skip()
+		return nil
+	}
+	v = pv
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(d.arrayInterface()))
+			return nil
+		}
+		fallthrough
+	default:
+		d.saveError(&UnmarshalTypeError{Value: "array", Type: v.Type(), Offset: int64(d.off)})
+		d.skip()
+		return nil
+	case reflect.Array, reflect.Slice:
+	}
+
+	d.off++ // [
+	i := 0
+	for {
+		d.skipSpace()
+		if d.data[d.off] == ']' {
+			d.off++
+			break
+		}
+
+		if v.Kind() == reflect.Slice {
+			// 容量不够时按1.5倍扩容
+			if i >= v.Cap() {
+				newcap := v.Cap() + v.Cap()/2
+				if newcap < 4 {
+					newcap = 4
+				}
+				newv := reflect.MakeSlice(v.Type(), v.Len(), newcap)
+				reflect.Copy(newv, v)
+				v.Set(newv)
+			}
+			if i >= v.Len() {
+				v.SetLen(i + 1)
+			}
+		}
+
+		if i < v.Len() {
+			if err := d.value(v.Index(i)); err != nil {
+				return err
+			}
+		} else {
+			// 数组的长度不够, 多出来的元素丢掉
+			d.skip()
+		}
+		i++
+
+		d.skipSpace()
+		if d.data[d.off] == ',' {
+			d.off++
+		}
+	}
+
+	if i < v.Len() {
+		if v.Kind() == reflect.Array {
+			// 数组剩下的元素置零
+			z := reflect.Zero(v.Type().Elem())
+			for ; i < v.Len(); i++ {
+				v.Index(i).Set(z)
+			}
+		} else {
+			v.SetLen(i)
+		}
+	}
+	if i == 0 && v.Kind() == reflect.Slice && v.IsNil() {
+		// []解码为空slice, 而不是nil
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	}
+	return nil
+}
+
+// object 解码一个对象, 可以解码到map, struct与interface{}
+func (d *decodeState) object(v reflect.Value) error {
+	u, ut, pv := indirect(v, false)
+	if u != nil {
+		return u.UnmarshalJSON(d.rawValue())
+	}
+	if ut != nil {
+		d.saveError(&UnmarshalTypeError{Value: "object", Type: v.Type(), Offset: int64(d.off)})
+		d.skip()
+		return nil
+	}
+	v = pv
+	t := v.Type()
+
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		v.Set(reflect.ValueOf(d.objectInterface()))
+		return nil
+	}
+
+	var fields []field
+
+	// map的key可以是字符串, 整数, 或者实现了TextUnmarshaler的类型
+	switch v.Kind() {
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+				d.saveError(&UnmarshalTypeError{Value: "object", Type: t, Offset: int64(d.off)})
+				d.skip()
+				return nil
+			}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+	case reflect.Struct:
+		fields = cachedTypeFields(t)
+	default:
+		d.saveError(&UnmarshalTypeError{Value: "object", Type: t, Offset: int64(d.off)})
+		d.skip()
+		return nil
+	}
+
+	origErrorContext := d.errorContext
+
+	d.off++ // {
+	for {
+		d.skipSpace()
+		if d.data[d.off] == '}' {
+			d.off++
+			break
+		}
+
+		// key
+		keyStart := d.off
+		d.skipString()
+		key, ok := unquoteBytes(d.data[keyStart:d.off])
+		if !ok {
+			return errPhase
+		}
+		d.skipSpace()
+		d.off++ // :
+		d.skipSpace()
+
+		if v.Kind() == reflect.Map {
+			elemType := t.Elem()
+			mapElem := reflect.New(elemType).Elem()
+			if err := d.value(mapElem); err != nil {
+				return err
+			}
+			kv := d.mapKey(key, t.Key(), keyStart)
+			if kv.IsValid() {
+				v.SetMapIndex(kv, mapElem)
+			}
+		} else {
+			var subv reflect.Value
+			f := lookupField(fields, key)
+			if f != nil {
+				subv = v
+				for _, i := range f.index {
+					if subv.Kind() == reflect.Ptr {
+						if subv.IsNil() {
+							// 嵌入的是未导出结构体的指针, 没法分配
+							if !subv.CanSet() {
+								d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem()))
+								subv = reflect.Value{}
+								break
+							}
+							subv.Set(reflect.New(subv.Type().Elem()))
+						}
+						subv = subv.Elem()
+					}
+					subv = subv.Field(i)
+				}
+				d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
+				d.errorContext.Struct = t
+			} else if d.disallowUnknownFields {
+				d.saveError(fmt.Errorf("json: unknown field %q", key))
+			}
+
+			if f != nil && f.quoted && subv.IsValid() {
+				if err := d.quotedValue(subv); err != nil {
+					return err
+				}
+			} else if err := d.value(subv); err != nil {
+				return err
+			}
+
+			// FieldStack的底层数组可以复用, 只恢复长度
+			d.errorContext.FieldStack = d.errorContext.FieldStack[:len(origErrorContext.FieldStack)]
+			d.errorContext.Struct = origErrorContext.Struct
+		}
+
+		d.skipSpace()
+		if d.data[d.off] == ',' {
+			d.off++
+		}
+	}
+	return nil
+}
+
+// lookupField 先精确匹配, 再不区分大小写匹配
+func lookupField(fields []field, key []byte) *field {
+	for i := range fields {
+		if fields[i].name == string(key) {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if bytes.EqualFold([]byte(fields[i].name), key) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// mapKey 把JSON对象的key转为map的key类型, 失败时返回无效的Value
+func (d *decodeState) mapKey(key []byte, kt reflect.Type, keyStart int) reflect.Value {
+	switch {
+	case reflect.PtrTo(kt).Implements(textUnmarshalerType):
+		kv := reflect.New(kt)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText(key); err != nil {
+			d.saveError(err)
+			return reflect.Value{}
+		}
+		return kv.Elem()
+	case kt.Kind() == reflect.String:
+		return reflect.ValueOf(string(key)).Convert(kt)
+	}
+	s := string(key)
+	switch kt.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || reflect.Zero(kt).OverflowInt(n) {
+			d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: kt, Offset: int64(keyStart + 1)})
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(n).Convert(kt)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || reflect.Zero(kt).OverflowUint(n) {
+			d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: kt, Offset: int64(keyStart + 1)})
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(n).Convert(kt)
+	}
+	return reflect.Value{}
+}
+
+// quotedValue 处理,string选项: 值被放在一个JSON字符串里
+func (d *decodeState) quotedValue(v reflect.Value) error {
+	start := d.off
+	if d.data[d.off] != '"' {
+		d.skip()
+		if string(d.data[start:d.off]) == "null" {
+			// null按没有,string处理
+			return d.literalStore(d.data[start:d.off], v, false)
+		}
+		d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal unquoted value into %v", v.Type()))
+		return nil
+	}
+	d.skipString()
+	s, ok := unquoteBytes(d.data[start:d.off])
+	if !ok {
+		return errPhase
+	}
+	return d.literalStore(s, v, true)
+}
+
+// errPhase 表示数据在checkValid之后被改了, 或者解码器有bug
+var errPhase = fmt.Errorf("JSON decoder out of sync - data changing underfoot?")
+
+// literalStore 把字符串, 数字, true/false/null解码到v
+// fromQuoted为true表示item来自,string选项的字符串内容, 可能不是合法的JSON
+func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool) error {
+	if len(item) == 0 {
+		// 只有,string的情况下才会是空的
+		d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type()))
+		return nil
+	}
+	isNull := item[0] == 'n'
+	u, ut, pv := indirect(v, isNull)
+	if u != nil {
+		return u.UnmarshalJSON(item)
+	}
+	if ut != nil {
+		if item[0] != '"' {
+			if fromQuoted {
+				d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type()))
+				return nil
+			}
+			val := "number"
+			switch item[0] {
+			case 'n':
+				val = "null"
+			case 't', 'f':
+				val = "bool"
+			}
+			d.saveError(&UnmarshalTypeError{Value: val, Type: v.Type(), Offset: int64(d.off)})
+			return nil
+		}
+		s, ok := unquoteBytes(item)
+		if !ok {
+			if fromQuoted {
+				return fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type())
+			}
+			return errPhase
+		}
+		return ut.UnmarshalText(s)
+	}
+
+	v = pv
+
+	switch c := item[0]; c {
+	case 'n': // null
+		if fromQuoted && string(item) != "null" {
+			d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type()))
+			break
+		}
+		// null只对可以为nil的类型有效, 其它类型忽略
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+	case 't', 'f': // true, false
+		value := item[0] == 't'
+		if fromQuoted && string(item) != "true" && string(item) != "false" {
+			d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type()))
+			break
+		}
+		switch v.Kind() {
+		default:
+			if fromQuoted {
+				d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type()))
+			} else {
+				d.saveError(&UnmarshalTypeError{Value: "bool", Type: v.Type(), Offset: int64(d.off)})
+			}
+		case reflect.Bool:
+			v.SetBool(value)
+		case reflect.Interface:
+			if v.NumMethod() == 0 {
+				v.Set(reflect.ValueOf(value))
+			} else {
+				d.saveError(&UnmarshalTypeError{Value: "bool", Type: v.Type(), Offset: int64(d.off)})
+			}
+		}
+
+	case '"': // string
+		s, ok := unquoteBytes(item)
+		if !ok {
+			if fromQuoted {
+				return fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type())
+			}
+			return errPhase
+		}
+		switch v.Kind() {
+		default:
+			d.saveError(&UnmarshalTypeError{Value: "string", Type: v.Type(), Offset: int64(d.off)})
+		case reflect.Slice:
+			// []byte是用base64编码的
+			if v.Type().Elem().Kind() != reflect.Uint8 {
+				d.saveError(&UnmarshalTypeError{Value: "string", Type: v.Type(), Offset: int64(d.off)})
+				break
+			}
+			b := make([]byte, base64.StdEncoding.DecodedLen(len(s)))
+			n, err := base64.StdEncoding.Decode(b, s)
+			if err != nil {
+				d.saveError(err)
+				break
+			}
+			v.SetBytes(b[:n])
+		case reflect.String:
+			if v.Type() == numberType && !isValidNumber(string(s)) {
+				return fmt.Errorf("json: invalid number literal, trying to unmarshal %q into Number", item)
+			}
+			v.SetString(string(s))
+		case reflect.Interface:
+			if v.NumMethod() == 0 {
+				v.Set(reflect.ValueOf(string(s)))
+			} else {
+				d.saveError(&UnmarshalTypeError{Value: "string", Type: v.Type(), Offset: int64(d.off)})
+			}
+		}
+
+	default: // number
+		if c != '-' && (c < '0' || c > '9') {
+			if fromQuoted {
+				return fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type())
+			}
+			return errPhase
+		}
+		s := string(item)
+		if fromQuoted && !isValidNumber(s) {
+			return fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type())
+		}
+		switch v.Kind() {
+		default:
+			if v.Kind() == reflect.String && v.Type() == numberType {
+				v.SetString(s)
+				break
+			}
+			if fromQuoted {
+				return fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type())
+			}
+			d.saveError(&UnmarshalTypeError{Value: "number", Type: v.Type(), Offset: int64(d.off)})
+		case reflect.Interface:
+			n, err := d.convertNumber(s)
+			if err != nil {
+				d.saveError(err)
+				break
+			}
+			if v.NumMethod() != 0 {
+				d.saveError(&UnmarshalTypeError{Value: "number", Type: v.Type(), Offset: int64(d.off)})
+				break
+			}
+			v.Set(reflect.ValueOf(n))
+
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v.OverflowInt(n) {
+				d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: v.Type(), Offset: int64(d.off)})
+				break
+			}
+			v.SetInt(n)
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil || v.OverflowUint(n) {
+				d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: v.Type(), Offset: int64(d.off)})
+				break
+			}
+			v.SetUint(n)
+
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(s, v.Type().Bits())
+			if err != nil || v.OverflowFloat(n) {
+				d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: v.Type(), Offset: int64(d.off)})
+				break
+			}
+			v.SetFloat(n)
+		}
+	}
+	return nil
+}
+
+// convertNumber 把数字转为float64, UseNumber时转为Number
+func (d *decodeState) convertNumber(s string) (interface{}, error) {
+	if d.useNumber {
+		return Number(s), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, &UnmarshalTypeError{Value: "number " + s, Type: reflect.TypeOf(0.0), Offset: int64(d.off)}
+	}
+	return f, nil
+}
+
+// 下面的xxxInterface用于解码到interface{}, 不需要reflect
+
+// valueInterface 返回d.off处的值
+func (d *decodeState) valueInterface() interface{} {
+	d.skipSpace()
+	switch d.data[d.off] {
+	case '{':
+		return d.objectInterface()
+	case '[':
+		return d.arrayInterface()
+	default:
+		return d.literalInterface()
+	}
+}
+
+func (d *decodeState) arrayInterface() []interface{} {
+	v := make([]interface{}, 0)
+	d.off++ // [
+	for {
+		d.skipSpace()
+		if d.data[d.off] == ']' {
+			d.off++
+			return v
+		}
+		v = append(v, d.valueInterface())
+		d.skipSpace()
+		if d.data[d.off] == ',' {
+			d.off++
+		}
+	}
+}
+
+func (d *decodeState) objectInterface() map[string]interface{} {
+	m := make(map[string]interface{})
+	d.off++ // {
+	for {
+		d.skipSpace()
+		if d.data[d.off] == '}' {
+			d.off++
+			return m
+		}
+		start := d.off
+		d.skipString()
+		key, ok := unquote(d.data[start:d.off])
+		if !ok {
+			panic(errPhase)
+		}
+		d.skipSpace()
+		d.off++ // :
+		m[key] = d.valueInterface()
+		d.skipSpace()
+		if d.data[d.off] == ',' {
+			d.off++
+		}
+	}
+}
+
+func (d *decodeState) literalInterface() interface{} {
+	start := d.off
+	if d.data[d.off] == '"' {
+		d.skipString()
+	} else {
+		d.skipLiteral()
+	}
+	item := d.data[start:d.off]
+
+	switch c := item[0]; c {
+	case 'n':
+		return nil
+	case 't', 'f':
+		return c == 't'
+	case '"':
+		s, ok := unquote(item)
+		if !ok {
+			panic(errPhase)
+		}
+		return s
+	default:
+		n, err := d.convertNumber(string(item))
+		if err != nil {
+			d.saveError(err)
+		}
+		return n
+	}
+}
+
+// getu4 解析\uXXXX, 失败返回-1
+func getu4(s []byte) rune {
+	if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+		return -1
+	}
+	var r rune
+	for _, c := range s[2:6] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return -1
+		}
+		r = r*16 + rune(c)
+	}
+	return r
+}
+
+// unquote 把带引号的JSON字符串转为Go字符串
+func unquote(s []byte) (t string, ok bool) {
+	s, ok = unquoteBytes(s)
+	t = string(s)
+	return
+}
+
+// unquoteBytes 去掉引号并处理转义, 不合法的UTF-8替换为U+FFFD
+// 没有转义并且都是合法UTF-8时, 直接返回s的子切片, 不分配内存
+func unquoteBytes(s []byte) (t []byte, ok bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return
+	}
+	s = s[1 : len(s)-1]
+
+	// 先看看有没有需要处理的字符
+	r := 0
+	for r < len(s) {
+		c := s[r]
+		if c == '\\' || c == '"' || c < ' ' {
+			break
+		}
+		if c < utf8.RuneSelf {
+			r++
+			continue
+		}
+		rr, size := utf8.DecodeRune(s[r:])
+		if rr == utf8.RuneError && size == 1 {
+			break
+		}
+		r += size
+	}
+	if r == len(s) {
+		return s, true
+	}
+
+	b := make([]byte, len(s)+2*utf8.UTFMax)
+	w := copy(b, s[0:r])
+	for r < len(s) {
+		// 一个字符最多写utf8.UTFMax个字节, 不够了就扩容
+		if w >= len(b)-2*utf8.UTFMax {
+			nb := make([]byte, (len(b)+utf8.UTFMax)*2)
+			copy(nb, b[0:w])
+			b = nb
+		}
+		switch c := s[r]; {
+		case c == '\\':
+			r++
+			if r >= len(s) {
+				return
+			}
+			switch s[r] {
+			default:
+				return
+			case '"', '\\', '/', '\'':
+				b[w] = s[r]
+				r++
+				w++
+			case 'b':
+				b[w] = '\b'
+				r++
+				w++
+			case 'f':
+				b[w] = '\f'
+				r++
+				w++
+			case 'n':
+				b[w] = '\n'
+				r++
+				w++
+			case 'r':
+				b[w] = '\r'
+				r++
+				w++
+			case 't':
+				b[w] = '\t'
+				r++
+				w++
+			case 'u':
+				r--
+				rr := getu4(s[r:])
+				if rr < 0 {
+					return
+				}
+				r += 6
+				if utf16.IsSurrogate(rr) {
+					rr1 := getu4(s[r:])
+					if dec := utf16.DecodeRune(rr, rr1); dec != unicode.ReplacementChar {
+						// 合法的代理对
+						r += 6
+						w += utf8.EncodeRune(b[w:], dec)
+						break
+					}
+					// 不合法的代理对, 替换为U+FFFD
+					rr = unicode.ReplacementChar
+				}
+				w += utf8.EncodeRune(b[w:], rr)
+			}
+
+		// 引号与控制字符不能出现在字符串里
+		case c == '"', c < ' ':
+			return
+
+		// ASCII
+		case c < utf8.RuneSelf:
+			b[w] = c
+			r++
+			w++
+
+		// 多字节的UTF-8
+		default:
+			rr, size := utf8.DecodeRune(s[r:])
+			r += size
+			w += utf8.EncodeRune(b[w:], rr)
+		}
+	}
+	return b[0:w], true
+}
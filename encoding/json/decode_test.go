@@ -0,0 +1,204 @@
+package json
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type unmarshalPoint struct {
+	X, Y int
+}
+
+type unmarshalT struct {
+	Name    string          `json:"name"`
+	Age     int             `json:"age,omitempty"`
+	Tags    []string        `json:"tags"`
+	Attrs   map[string]int  `json:"attrs"`
+	Ptr     *unmarshalPoint `json:"ptr"`
+	Any     interface{}     `json:"any"`
+	Count   int64           `json:"count,string"`
+	Skip    string          `json:"-"`
+	Arr     [2]int          `json:"arr"`
+	Nested  unmarshalPoint  `json:"nested"`
+	ByID    map[int]string  `json:"by_id"`
+	Bytes   []byte          `json:"bytes"`
+	private int
+}
+
+// upperString 实现了Unmarshaler, 把字符串转为大写
+type upperString string
+
+func (u *upperString) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*u = upperString(strings.ToUpper(s))
+	return nil
+}
+
+func TestUnmarshal(t *testing.T) {
+	in := `{
+		"name": "gopher", "AGE": 7, "tags": ["a", "b"],
+		"attrs": {"x": 1, "y": 2}, "ptr": {"X": 1, "y": 2},
+		"any": [1, "two", true, null, {"k": 1.5}],
+		"count": "42", "-": "ignored", "Skip": "ignored too",
+		"arr": [1, 2, 3], "nested": {"X": 3}, "by_id": {"1": "one", "-2": "minus two"},
+		"bytes": "aGk=", "private": 1, "unknown": {"deep": [1]}
+	}`
+	var got unmarshalT
+	if err := Unmarshal([]byte(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := unmarshalT{
+		Name:   "gopher",
+		Age:    7,
+		Tags:   []string{"a", "b"},
+		Attrs:  map[string]int{"x": 1, "y": 2},
+		Ptr:    &unmarshalPoint{1, 2},
+		Any:    []interface{}{1.0, "two", true, nil, map[string]interface{}{"k": 1.5}},
+		Count:  42,
+		Arr:    [2]int{1, 2},
+		Nested: unmarshalPoint{X: 3},
+		ByID:   map[int]string{1: "one", -2: "minus two"},
+		Bytes:  []byte("hi"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	// null把指针, slice, map置为nil, 其它类型不变
+	if err := Unmarshal([]byte(`{"name":null,"tags":null,"attrs":null,"ptr":null}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "gopher" || got.Tags != nil || got.Attrs != nil || got.Ptr != nil {
+		t.Errorf("Unmarshal nulls = %+v", got)
+	}
+
+	var u struct {
+		A upperString
+		B *upperString
+	}
+	if err := Unmarshal([]byte(`{"A":"abc","B":"def"}`), &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.A != "ABC" || u.B == nil || *u.B != "DEF" {
+		t.Errorf("Unmarshaler: got %q %v", u.A, u.B)
+	}
+
+	var s string
+	if err := Unmarshal([]byte(`"\u00e9\ud83d\ude00\n"`), &s); err != nil || s != "\u00e9\U0001F600\n" {
+		t.Errorf("Unmarshal escapes = %q, %v", s, err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var v struct {
+		A int
+		B string
+		C struct{ D uint8 }
+	}
+	err := Unmarshal([]byte(`{"A":"x","B":"ok","C":{"D":300}}`), &v)
+	ute, ok := err.(*UnmarshalTypeError)
+	if !ok {
+		t.Fatalf("Unmarshal error = %v (%T); want *UnmarshalTypeError", err, err)
+	}
+	if ute.Value != "string" || ute.Type.Kind() != reflect.Int || ute.Field != "A" {
+		t.Errorf("UnmarshalTypeError = %+v", ute)
+	}
+	// 类型不匹配的值被跳过, 其它的值照常解码
+	if v.B != "ok" {
+		t.Errorf("B = %q after type error; want ok", v.B)
+	}
+
+	if err := Unmarshal([]byte(`{"A":1,}`), &v); err == nil {
+		t.Error("Unmarshal of invalid JSON succeeded")
+	} else if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("Unmarshal error = %T; want *SyntaxError", err)
+	}
+	if err := Unmarshal([]byte(`1 2`), new(int)); err == nil {
+		t.Error("Unmarshal with trailing data succeeded")
+	}
+
+	for _, arg := range []interface{}{nil, v, (*int)(nil)} {
+		err := Unmarshal([]byte(`1`), arg)
+		if _, ok := err.(*InvalidUnmarshalError); !ok {
+			t.Errorf("Unmarshal(%T) error = %v; want *InvalidUnmarshalError", arg, err)
+		}
+	}
+}
+
+func TestNumberExact(t *testing.T) {
+	const big = `{"id":18446744073709551615,"n":-12,"price":0.1000000000000000055511151231257827}`
+	dec := NewDecoder(strings.NewReader(big))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	id := m["id"].(Number)
+	if u, err := id.Uint64(); err != nil || u != 1<<64-1 {
+		t.Errorf("Uint64 = %d, %v", u, err)
+	}
+	if _, err := id.Int64(); err == nil {
+		t.Error("Int64 of 2^64-1 succeeded")
+	}
+	r, ok := m["price"].(Number).Rat()
+	if !ok || r.FloatString(34) != "0.1000000000000000055511151231257827" {
+		t.Errorf("Rat = %v, %v", r, ok)
+	}
+
+	// 编码时原样输出
+	b, err := Marshal(m)
+	if err != nil || string(b) != big {
+		t.Errorf("Marshal = %s, %v; want %s", b, err, big)
+	}
+
+	var s struct {
+		N Number `json:"n"`
+		Q Number `json:",string"`
+	}
+	if err := Unmarshal([]byte(`{"n":1e400,"Q":"12.50"}`), &s); err != nil || s.N != "1e400" || s.Q != "12.50" {
+		t.Errorf("Unmarshal into Number = %+v, %v", s, err)
+	}
+	if _, err := Marshal(Number("1x")); err == nil {
+		t.Error("Marshal of invalid Number succeeded")
+	}
+}
+
+func TestRawMessage(t *testing.T) {
+	var env struct {
+		Type string
+		Data RawMessage
+		Opt  *RawMessage
+		Null RawMessage
+	}
+	in := `{"Type":"point","Data":{ "x" : 1,"y":[2] },"Null":null}`
+	if err := Unmarshal([]byte(in), &env); err != nil {
+		t.Fatal(err)
+	}
+	if string(env.Data) != `{ "x" : 1,"y":[2] }` || env.Opt != nil || string(env.Null) != "null" {
+		t.Errorf("Unmarshal = %+v", env)
+	}
+	b, err := Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Type":"point","Data":{"x":1,"y":[2]},"Opt":null,"Null":null}`; string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+	if _, err := Marshal(RawMessage(`{bad`)); err == nil {
+		t.Error("Marshal of invalid RawMessage succeeded")
+	}
+
+	// Decoder的缓冲区会被复用, RawMessage必须是一份拷贝
+	dec := NewDecoder(bytes.NewReader([]byte(`[1] [2]`)))
+	var a, c RawMessage
+	dec.Decode(&a)
+	dec.Decode(&c)
+	if string(a) != "[1]" || string(c) != "[2]" {
+		t.Errorf("got %s %s", a, c)
+	}
+}
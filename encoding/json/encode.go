@@ -2,22 +2,35 @@ package json
 
 import (
 	"bytes"
+	"encoding"
+	"encoding/base64"
+	"math"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"sync"
+	"unicode/utf8"
 )
 
 // 如果一个对象实现了Marshaler接口(要有MarshalJSON)方法
 // json.Marshal(v) 会调用其自定义的MarshalJSON来办出json encoding
 // 将一个map或者struct转为json格式的字节数组
+//
+// 结构体字段按tag编码: `json:"name,omitempty,string"`, `json:"-"`表示忽略
+// 匿名嵌入的结构体的字段会提升到外层, map的key会排序后输出
+// 字符串里的<, >, &会被转义成\u003c, \u003e, \u0026, 以便安全地嵌入HTML
 func Marshal(v interface{}) ([]byte, error) {
-	e := &encodeState{} // 初始化未必用newStruct方法
-	err := e.marshal(v)
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+
+	err := e.marshal(v, true)
 	if err != nil {
 		return nil, err
 	}
-	return e.Bytes(), nil
+	// e会放回池子里被复用, 结果要复制一份
+	buf := append([]byte(nil), e.Bytes()...)
+	return buf, nil
 }
 
 func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
@@ -33,7 +46,8 @@ func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// 不是很明白
+// HTMLEscape 把src里字符串中的<, >, &, U+2028, U+2029转义后写进dst
+// 这样JSON可以直接放进HTML的<script>标签里
 func HTMLEscape(dst *bytes.Buffer, src []byte) {
 	start := 0
 	for i, c := range src {
@@ -41,8 +55,24 @@ func HTMLEscape(dst *bytes.Buffer, src []byte) {
 			if start < i {
 				dst.Write(src[start:i])
 			}
+			dst.WriteString(`\u00`)
+			dst.WriteByte(hex[c>>4])
+			dst.WriteByte(hex[c&0xF])
+			start = i + 1
+		}
+		// U+2028是E2 80 A8, U+2029是E2 80 A9
+		if c == 0xE2 && i+2 < len(src) && src[i+1] == 0x80 && src[i+2]&^1 == 0xA8 {
+			if start < i {
+				dst.Write(src[start:i])
+			}
+			dst.WriteString(`\u202`)
+			dst.WriteByte(hex[src[i+2]&0xF])
+			start = i + 3
 		}
 	}
+	if start < len(src) {
+		dst.Write(src[start:])
+	}
 }
 
 // Marshaler 是用于对象自定义成JSON
@@ -90,9 +120,35 @@ var hex = "0123456789abcdef"
 type encodeState struct {
 	bytes.Buffer
 	scratch [64]byte
+
+	escapeHTML bool
+
+	// 指针嵌套很深时开始检查循环引用, 防止栈溢出
+	ptrLevel uint
+	ptrSeen  map[interface{}]struct{}
+}
+
+// encodeStatePool 缓存用过的encodeState, 减少反复编码时缓冲区的分配
+var encodeStatePool sync.Pool
+
+func newEncodeState() *encodeState {
+	if v := encodeStatePool.Get(); v != nil {
+		e := v.(*encodeState)
+		e.Reset()
+		// 上一次编码出错时可能没有恢复这两个字段
+		e.ptrLevel = 0
+		for k := range e.ptrSeen {
+			delete(e.ptrSeen, k)
+		}
+		return e
+	}
+	return new(encodeState)
 }
 
-func (e *encodeState) marshal(v interface{}) (err error) {
+// 指针嵌套超过这个深度才开始记录访问过的指针, 一般的数据不用付出这个开销
+const startDetectingCyclesAfter = 1000
+
+func (e *encodeState) marshal(v interface{}, escapeHTML bool) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if _, ok := r.(runtime.Error); ok {
@@ -104,10 +160,16 @@ func (e *encodeState) marshal(v interface{}) (err error) {
 			err = r.(error)
 		}
 	}()
+	e.escapeHTML = escapeHTML
 	e.reflectValue(reflect.ValueOf(v))
 	return nil
 }
 
+// error 中止编码, 由marshal里的recover转成返回值
+func (e *encodeState) error(err error) {
+	panic(err)
+}
+
 func (e *encodeState) reflectValue(v reflect.Value) {
 	valueEncoder(v)(e, v, false)
 }
@@ -133,4 +195,934 @@ func typeEncoder(t reflect.Type) encoderFunc {
 	if f != nil {
 		return f
 	}
+
+	// 递归类型(比如type T struct{ Next *T })在生成编码函数时会再次查到自己
+	// 先放一个间接的函数进缓存, 它等真正的函数生成好以后再调用
+	encoderCache.Lock()
+	if encoderCache.m == nil {
+		encoderCache.m = make(map[reflect.Type]encoderFunc)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	encoderCache.m[t] = func(e *encodeState, v reflect.Value, quoted bool) {
+		wg.Wait()
+		f(e, v, quoted)
+	}
+	encoderCache.Unlock()
+
+	// 生成真正的函数, 替换掉间接的那个
+	f = newTypeEncoder(t, true)
+	wg.Done()
+	encoderCache.Lock()
+	encoderCache.m[t] = f
+	encoderCache.Unlock()
+	return f
+}
+
+var (
+	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// newTypeEncoder 为t生成编码函数, 不查缓存
+// allowAddr为true时, 如果*t实现了Marshaler, 可寻址的值也会调用它
+func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
+	if t.Implements(marshalerType) {
+		return marshalerEncoder
+	}
+	if t.Kind() != reflect.Ptr && allowAddr {
+		if reflect.PtrTo(t).Implements(marshalerType) {
+			return newCondAddrEncoder(addrMarshalerEncoder, newTypeEncoder(t, false))
+		}
+	}
+
+	if t.Implements(textMarshalerType) {
+		return textMarshalerEncoder
+	}
+	if t.Kind() != reflect.Ptr && allowAddr {
+		if reflect.PtrTo(t).Implements(textMarshalerType) {
+			return newCondAddrEncoder(addrTextMarshalerEncoder, newTypeEncoder(t, false))
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintEncoder
+	case reflect.Float32:
+		return float32Encoder
+	case reflect.Float64:
+		return float64Encoder
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return interfaceEncoder
+	case reflect.Struct:
+		return newStructEncoder(t)
+	case reflect.Map:
+		return newMapEncoder(t)
+	case reflect.Slice:
+		return newSliceEncoder(t)
+	case reflect.Array:
+		return newArrayEncoder(t)
+	case reflect.Ptr:
+		return newPtrEncoder(t)
+	default:
+		return unsupportedTypeEncoder
+	}
+}
+
+func invalidValueEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	e.WriteString("null")
+}
+
+func marshalerEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m := v.Interface().(Marshaler)
+	b, err := m.MarshalJSON()
+	if err == nil {
+		err = e.writeMarshaled(b)
+	}
+	if err != nil {
+		e.error(&MarshaleError{v.Type(), err})
+	}
+}
+
+func addrMarshalerEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	va := v.Addr()
+	if va.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m := va.Interface().(Marshaler)
+	b, err := m.MarshalJSON()
+	if err == nil {
+		err = e.writeMarshaled(b)
+	}
+	if err != nil {
+		e.error(&MarshaleError{v.Type(), err})
+	}
+}
+
+// writeMarshaled 检查MarshalJSON返回的是不是合法的JSON, 去掉空白后写进去
+// 需要转义HTML时, 和HTMLEscape一样转义
+// 去掉空白是必要的, 否则带换行的输出会破坏Encoder与LinesWriter的一行一个值
+func (e *encodeState) writeMarshaled(b []byte) error {
+	return compact(&e.Buffer, b, e.escapeHTML, 0)
+}
+
+func textMarshalerEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m := v.Interface().(encoding.TextMarshaler)
+	b, err := m.MarshalText()
+	if err != nil {
+		e.error(&MarshaleError{v.Type(), err})
+	}
+	e.stringBytes(b, e.escapeHTML)
+}
+
+func addrTextMarshalerEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	va := v.Addr()
+	if va.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m := va.Interface().(encoding.TextMarshaler)
+	b, err := m.MarshalText()
+	if err != nil {
+		e.error(&MarshaleError{v.Type(), err})
+	}
+	e.stringBytes(b, e.escapeHTML)
+}
+
+func boolEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	if quoted {
+		e.WriteByte('"')
+	}
+	if v.Bool() {
+		e.WriteString("true")
+	} else {
+		e.WriteString("false")
+	}
+	if quoted {
+		e.WriteByte('"')
+	}
+}
+
+func intEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	b := strconv.AppendInt(e.scratch[:0], v.Int(), 10)
+	if quoted {
+		e.WriteByte('"')
+	}
+	e.Write(b)
+	if quoted {
+		e.WriteByte('"')
+	}
+}
+
+func uintEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	b := strconv.AppendUint(e.scratch[:0], v.Uint(), 10)
+	if quoted {
+		e.WriteByte('"')
+	}
+	e.Write(b)
+	if quoted {
+		e.WriteByte('"')
+	}
+}
+
+type floatEncoder int // 位数, 32或者64
+
+func (bits floatEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
+	f := v.Float()
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		e.error(&UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, int(bits))})
+	}
+
+	// 和ES6一样: 绝对值在[1e-6, 1e21)之间的用小数表示, 其它的用指数表示
+	b := e.scratch[:0]
+	abs := math.Abs(f)
+	fmt := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			fmt = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, fmt, -1, int(bits))
+	if fmt == 'e' {
+		// e-09 改成 e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+
+	if quoted {
+		e.WriteByte('"')
+	}
+	e.Write(b)
+	if quoted {
+		e.WriteByte('"')
+	}
+}
+
+var (
+	float32Encoder = (floatEncoder(32)).encode
+	float64Encoder = (floatEncoder(64)).encode
+)
+
+func stringEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	if v.Type() == numberType {
+		numStr := v.String()
+		// 空的Number当作0, 和Go 1.5之前的行为一致
+		if numStr == "" {
+			numStr = "0"
+		}
+		if !isValidNumber(numStr) {
+			e.error(&UnsupportedValueError{v, "invalid number literal " + strconv.Quote(numStr)})
+		}
+		if quoted {
+			e.WriteByte('"')
+		}
+		e.WriteString(numStr)
+		if quoted {
+			e.WriteByte('"')
+		}
+		return
+	}
+	if quoted {
+		// ,string的字符串要编码两次: 先编码成JSON字符串, 再把它当作字符串编码
+		sb := &encodeState{}
+		sb.string(v.String(), e.escapeHTML)
+		e.string(sb.String(), e.escapeHTML)
+	} else {
+		e.string(v.String(), e.escapeHTML)
+	}
+}
+
+func interfaceEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	e.reflectValue(v.Elem())
+}
+
+func unsupportedTypeEncoder(e *encodeState, v reflect.Value, quoted bool) {
+	e.error(&UnsupportedTypeError{v.Type()})
+}
+
+// structEncoder 按cachedTypeFields算出的字段顺序编码结构体
+type structEncoder struct {
+	fields    []field
+	fieldEncs []encoderFunc
+}
+
+func (se *structEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
+	e.WriteByte('{')
+	first := true
+	for i, f := range se.fields {
+		fv := fieldByIndex(v, f.index)
+		if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if first {
+			first = false
+		} else {
+			e.WriteByte(',')
+		}
+		e.string(f.name, e.escapeHTML)
+		e.WriteByte(':')
+		se.fieldEncs[i](e, fv, f.quoted)
+	}
+	e.WriteByte('}')
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	fields := cachedTypeFields(t)
+	se := &structEncoder{
+		fields:    fields,
+		fieldEncs: make([]encoderFunc, len(fields)),
+	}
+	for i, f := range fields {
+		se.fieldEncs[i] = typeEncoder(typeByIndex(t, f.index))
+	}
+	return se.encode
+}
+
+// fieldByIndex 和reflect.Value.FieldByIndex一样, 但是路上遇到nil指针时返回无效值, 而不是panic
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+type mapEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (me *mapEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		// map是引用类型, 也可能形成环
+		ptr := v.Pointer()
+		if _, ok := e.ptrSeen[ptr]; ok {
+			e.error(&UnsupportedValueError{v, "encountered a cycle via " + v.Type().String()})
+		}
+		e.seePtr(ptr)
+		defer delete(e.ptrSeen, ptr)
+	}
+
+	// 先把所有的key转成字符串, 排序以后再输出, 保证结果是确定的
+	keys := v.MapKeys()
+	sv := make([]reflectWithString, len(keys))
+	for i, k := range keys {
+		sv[i].v = k
+		if err := sv[i].resolve(); err != nil {
+			e.error(&MarshaleError{k.Type(), err})
+		}
+	}
+	sort.Sort(byString(sv))
+
+	e.WriteByte('{')
+	for i, kv := range sv {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		e.string(kv.s, e.escapeHTML)
+		e.WriteByte(':')
+		me.elemEnc(e, v.MapIndex(kv.v), false)
+	}
+	e.WriteByte('}')
+	e.ptrLevel--
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+	// key只能是字符串, 整数, 或者实现了encoding.TextMarshaler的类型
+	switch t.Key().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !t.Key().Implements(textMarshalerType) {
+			return unsupportedTypeEncoder
+		}
+	}
+	me := &mapEncoder{typeEncoder(t.Elem())}
+	return me.encode
+}
+
+// reflectWithString 是map的一个key和它编码后的字符串
+type reflectWithString struct {
+	v reflect.Value
+	s string
+}
+
+func (w *reflectWithString) resolve() error {
+	if w.v.Kind() == reflect.String {
+		w.s = w.v.String()
+		return nil
+	}
+	if tm, ok := w.v.Interface().(encoding.TextMarshaler); ok {
+		if w.v.Kind() == reflect.Ptr && w.v.IsNil() {
+			return nil
+		}
+		buf, err := tm.MarshalText()
+		w.s = string(buf)
+		return err
+	}
+	switch w.v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.s = strconv.FormatInt(w.v.Int(), 10)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		w.s = strconv.FormatUint(w.v.Uint(), 10)
+		return nil
+	}
+	panic("unexpected map key type")
+}
+
+// byString 按编码后的key排序
+type byString []reflectWithString
+
+func (sv byString) Len() int           { return len(sv) }
+func (sv byString) Swap(i, j int)      { sv[i], sv[j] = sv[j], sv[i] }
+func (sv byString) Less(i, j int) bool { return sv[i].s < sv[j].s }
+
+// encodeByteSlice 把[]byte编码成base64字符串
+func encodeByteSlice(e *encodeState, v reflect.Value, quoted bool) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	s := v.Bytes()
+	e.WriteByte('"')
+	if len(s) < 1024 {
+		// 小的直接在栈上的scratch里编码
+		var dst []byte
+		if n := base64.StdEncoding.EncodedLen(len(s)); n <= len(e.scratch) {
+			dst = e.scratch[:n]
+		} else {
+			dst = make([]byte, n)
+		}
+		base64.StdEncoding.Encode(dst, s)
+		e.Write(dst)
+	} else {
+		// 大的用流式编码, 不用一次分配整个结果
+		enc := base64.NewEncoder(base64.StdEncoding, e)
+		enc.Write(s)
+		enc.Close()
+	}
+	e.WriteByte('"')
+}
+
+// sliceEncoder 只是在arrayEncoder外面加了nil的判断
+type sliceEncoder struct {
+	arrayEnc encoderFunc
+}
+
+func (se *sliceEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		// 用数据指针和长度一起标识一个切片, 避免把同一底层数组的不同切片当成环
+		ptr := struct {
+			ptr uintptr
+			len int
+		}{v.Pointer(), v.Len()}
+		if _, ok := e.ptrSeen[ptr]; ok {
+			e.error(&UnsupportedValueError{v, "encountered a cycle via " + v.Type().String()})
+		}
+		e.seePtr(ptr)
+		defer delete(e.ptrSeen, ptr)
+	}
+	se.arrayEnc(e, v, false)
+	e.ptrLevel--
+}
+
+func newSliceEncoder(t reflect.Type) encoderFunc {
+	// []byte编码成base64, 除非元素类型自己实现了Marshaler
+	if t.Elem().Kind() == reflect.Uint8 {
+		p := reflect.PtrTo(t.Elem())
+		if !p.Implements(marshalerType) && !p.Implements(textMarshalerType) {
+			return encodeByteSlice
+		}
+	}
+	enc := &sliceEncoder{newArrayEncoder(t)}
+	return enc.encode
+}
+
+type arrayEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (ae *arrayEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
+	e.WriteByte('[')
+	n := v.Len()
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		ae.elemEnc(e, v.Index(i), false)
+	}
+	e.WriteByte(']')
+}
+
+func newArrayEncoder(t reflect.Type) encoderFunc {
+	enc := &arrayEncoder{typeEncoder(t.Elem())}
+	return enc.encode
+}
+
+type ptrEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (pe *ptrEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		ptr := v.Interface()
+		if _, ok := e.ptrSeen[ptr]; ok {
+			e.error(&UnsupportedValueError{v, "encountered a cycle via " + v.Type().String()})
+		}
+		e.seePtr(ptr)
+		defer delete(e.ptrSeen, ptr)
+	}
+	pe.elemEnc(e, v.Elem(), quoted)
+	e.ptrLevel--
+}
+
+func newPtrEncoder(t reflect.Type) encoderFunc {
+	enc := &ptrEncoder{typeEncoder(t.Elem())}
+	return enc.encode
+}
+
+func (e *encodeState) seePtr(ptr interface{}) {
+	if e.ptrSeen == nil {
+		e.ptrSeen = make(map[interface{}]struct{})
+	}
+	e.ptrSeen[ptr] = struct{}{}
+}
+
+// condAddrEncoder 值可以取地址时用canAddrEnc, 否则用elseEnc
+type condAddrEncoder struct {
+	canAddrEnc, elseEnc encoderFunc
+}
+
+func (ce *condAddrEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
+	if v.CanAddr() {
+		ce.canAddrEnc(e, v, quoted)
+	} else {
+		ce.elseEnc(e, v, quoted)
+	}
+}
+
+func newCondAddrEncoder(canAddrEnc, elseEnc encoderFunc) encoderFunc {
+	enc := &condAddrEncoder{canAddrEnc: canAddrEnc, elseEnc: elseEnc}
+	return enc.encode
+}
+
+// htmlSafe 判断一个ASCII字符在escapeHTML时能不能原样输出
+func htmlSafe(b byte) bool {
+	return b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&'
+}
+
+// safe 判断一个ASCII字符在不转义HTML时能不能原样输出
+func safe(b byte) bool {
+	return b >= 0x20 && b != '"' && b != '\\'
+}
+
+// string 把s编码成JSON字符串写进去
+// 非法的UTF-8换成U+FFFD, U+2028与U+2029总是转义, 因为JavaScript里它们是换行
+func (e *encodeState) string(s string, escapeHTML bool) int {
+	len0 := e.Len()
+	e.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if htmlSafe(b) || !escapeHTML && safe(b) {
+				i++
+				continue
+			}
+			if start < i {
+				e.WriteString(s[start:i])
+			}
+			switch b {
+			case '\\', '"':
+				e.WriteByte('\\')
+				e.WriteByte(b)
+			case '\n':
+				e.WriteString(`\n`)
+			case '\r':
+				e.WriteString(`\r`)
+			case '\t':
+				e.WriteString(`\t`)
+			default:
+				// 其它的控制字符, 以及需要转义的<, >, &, 都用\u00XX
+				e.WriteString(`\u00`)
+				e.WriteByte(hex[b>>4])
+				e.WriteByte(hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			if start < i {
+				e.WriteString(s[start:i])
+			}
+			e.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			if start < i {
+				e.WriteString(s[start:i])
+			}
+			e.WriteString(`\u202`)
+			e.WriteByte(hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	if start < len(s) {
+		e.WriteString(s[start:])
+	}
+	e.WriteByte('"')
+	return e.Len() - len0
+}
+
+// stringBytes 和string一样, 只是参数是[]byte, 省掉一次转换
+func (e *encodeState) stringBytes(s []byte, escapeHTML bool) int {
+	len0 := e.Len()
+	e.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if htmlSafe(b) || !escapeHTML && safe(b) {
+				i++
+				continue
+			}
+			if start < i {
+				e.Write(s[start:i])
+			}
+			switch b {
+			case '\\', '"':
+				e.WriteByte('\\')
+				e.WriteByte(b)
+			case '\n':
+				e.WriteString(`\n`)
+			case '\r':
+				e.WriteString(`\r`)
+			case '\t':
+				e.WriteString(`\t`)
+			default:
+				e.WriteString(`\u00`)
+				e.WriteByte(hex[b>>4])
+				e.WriteByte(hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRune(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			if start < i {
+				e.Write(s[start:i])
+			}
+			e.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			if start < i {
+				e.Write(s[start:i])
+			}
+			e.WriteString(`\u202`)
+			e.WriteByte(hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	if start < len(s) {
+		e.Write(s[start:])
+	}
+	e.WriteByte('"')
+	return e.Len() - len0
+}
+
+// field 是一个会被编码/解码的结构体字段
+type field struct {
+	name  string
+	tag   bool  // 名字是不是来自tag
+	index []int // 从外层结构体到这个字段的路径, 用于reflect.Value.FieldByIndex
+	typ   reflect.Type
+
+	omitEmpty bool
+	quoted    bool // 有,string选项, 值要放在字符串里
+}
+
+// byName 按名字排序, 名字相同的按深度, 有tag的, 定义的顺序排
+type byName []field
+
+func (x byName) Len() int      { return len(x) }
+func (x byName) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byName) Less(i, j int) bool {
+	if x[i].name != x[j].name {
+		return x[i].name < x[j].name
+	}
+	if len(x[i].index) != len(x[j].index) {
+		return len(x[i].index) < len(x[j].index)
+	}
+	if x[i].tag != x[j].tag {
+		return x[i].tag
+	}
+	return byIndex(x).Less(i, j)
+}
+
+// byIndex 按字段定义的顺序排序
+type byIndex []field
+
+func (x byIndex) Len() int      { return len(x) }
+func (x byIndex) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byIndex) Less(i, j int) bool {
+	for k, xik := range x[i].index {
+		if k >= len(x[j].index) {
+			return false
+		}
+		if xik != x[j].index[k] {
+			return xik < x[j].index[k]
+		}
+	}
+	return len(x[i].index) < len(x[j].index)
+}
+
+// typeFields 返回t里需要编码的字段, 匿名嵌入的结构体的字段会被提升上来
+// 规则和Go的字段选择一样: 浅的字段覆盖深的, 同一深度有tag的覆盖没有tag的,
+// 剩下的同名同深度的字段互相抵消, 都不编码
+// 用广度优先遍历, 一层一层地处理匿名结构体
+func typeFields(t reflect.Type) []field {
+	current := []field{}
+	next := []field{{typ: t}}
+
+	// 当前层与下一层里每种类型出现的次数
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+
+	// 已经处理过的类型, 防止指针嵌入导致的死循环
+	visited := map[reflect.Type]bool{}
+
+	var fields []field
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				isUnexported := sf.PkgPath != ""
+				if sf.Anonymous {
+					t := sf.Type
+					if t.Kind() == reflect.Ptr {
+						t = t.Elem()
+					}
+					// 未导出的非结构体类型的嵌入字段忽略
+					// 未导出的结构体类型的嵌入字段, 它的导出字段还是要提升的
+					if isUnexported && t.Kind() != reflect.Struct {
+						continue
+					}
+				} else if isUnexported {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+				if !isValidTag(name) {
+					name = ""
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				// ,string只对基本类型有效
+				quoted := false
+				if opts.Contains("string") {
+					switch ft.Kind() {
+					case reflect.Bool,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64,
+						reflect.String:
+						quoted = true
+					}
+				}
+
+				// 普通字段, 或者有名字的匿名字段
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+					fields = append(fields, field{
+						name:      name,
+						tag:       tagged,
+						index:     index,
+						typ:       ft,
+						omitEmpty: opts.Contains("omitempty"),
+						quoted:    quoted,
+					})
+					if count[f.typ] > 1 {
+						// 同一层有多个相同类型的匿名结构体, 它们的字段会互相抵消
+						// 这里多放一个, 让下面的dominantField把它们都去掉
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				// 没有名字的匿名结构体, 下一层再处理
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Sort(byName(fields))
+
+	// 去掉被覆盖的字段, fields已经按名字排好序了, 同名的挨在一起
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		name := fi.name
+		for advance = 1; i+advance < len(fields); advance++ {
+			fj := fields[i+advance]
+			if fj.name != name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		dominant, ok := dominantField(fields[i : i+advance])
+		if ok {
+			out = append(out, dominant)
+		}
+	}
+
+	fields = out
+	sort.Sort(byIndex(fields))
+
+	return fields
+}
+
+// dominantField 从同名的字段里选出生效的那个
+// fields已经排好序, 第一个是最浅的, 如果第二个和它一样深并且tag的情况一样, 就是冲突
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tag == fields[1].tag {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+var fieldCache struct {
+	sync.RWMutex
+	m map[reflect.Type][]field
+}
+
+// cachedTypeFields 和typeFields一样, 但是结果会缓存起来
+func cachedTypeFields(t reflect.Type) []field {
+	fieldCache.RLock()
+	f := fieldCache.m[t]
+	fieldCache.RUnlock()
+	if f != nil {
+		return f
+	}
+
+	// 并发时可能会计算多次, 结果是一样的, 无所谓
+	f = typeFields(t)
+	if f == nil {
+		f = []field{}
+	}
+
+	fieldCache.Lock()
+	if fieldCache.m == nil {
+		fieldCache.m = map[reflect.Type][]field{}
+	}
+	fieldCache.m[t] = f
+	fieldCache.Unlock()
+	return f
 }
@@ -0,0 +1,168 @@
+package json
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type embedInner struct {
+	A int `json:"a"`
+	B string
+}
+
+type node struct {
+	Name string `json:"name"`
+	Next *node  `json:"next,omitempty"`
+}
+
+type textKey int
+
+func (k textKey) MarshalText() ([]byte, error) { return []byte(strings.Repeat("k", int(k))), nil }
+
+type selfMarshaler struct{}
+
+func (selfMarshaler) MarshalJSON() ([]byte, error) { return []byte(`{ "x" : 1 }`), nil }
+
+type ptrMarshaler struct{ V int }
+
+func (p *ptrMarshaler) MarshalJSON() ([]byte, error) { return []byte(`"ptr"`), nil }
+
+type tagged struct {
+	embedInner
+	*node
+	Skip   int            `json:"-"`
+	Dash   int            `json:"-,"`
+	N      int64          `json:",string"`
+	S      string         `json:",string"`
+	Empty  []int          `json:",omitempty"`
+	Bytes  []byte         `json:"bytes"`
+	Map    map[string]int `json:"map"`
+	TMap   map[textKey]int
+	IMap   map[int]bool
+	Self   selfMarshaler
+	Ptr    ptrMarshaler
+	Num    Number
+	hidden int
+}
+
+func TestMarshalTags(t *testing.T) {
+	v := &tagged{
+		embedInner: embedInner{1, "b"},
+		node:       &node{Name: "n", Next: &node{Name: "m"}},
+		Skip:       9,
+		N:          5,
+		S:          `q"`,
+		Bytes:      []byte("hi"),
+		Map:        map[string]int{"z": 1, "a": 2},
+		TMap:       map[textKey]int{2: 1, 1: 3},
+		IMap:       map[int]bool{10: true, 2: false},
+		Num:        "12.50",
+	}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":1,"B":"b","name":"n","next":{"name":"m"},"-":0,"N":"5","S":"\"q\\\"\"",` +
+		`"bytes":"aGk=","map":{"a":2,"z":1},"TMap":{"k":3,"kk":1},"IMap":{"10":true,"2":false},` +
+		`"Self":{"x":1},"Ptr":"ptr","Num":12.50}`
+	if string(b) != want {
+		t.Errorf("Marshal:\ngot  %s\nwant %s", b, want)
+	}
+}
+
+func TestMarshalFloat(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{0.0, "0"},
+		{123456.0, "123456"},
+		{1e-7, "1e-7"},
+		{1e21, "1e+21"},
+		{float32(0.1), "0.1"},
+		{-2.5, "-2.5"},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(tt.in)
+		if err != nil || string(b) != tt.want {
+			t.Errorf("Marshal(%v) = %s, %v; want %s", tt.in, b, err, tt.want)
+		}
+	}
+}
+
+func TestMarshalEscape(t *testing.T) {
+	b, err := Marshal("<&>\u2028\xff\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{"<", ">", "&", "\u2028", "\xff", "\n"} {
+		if bytes.Contains(b, []byte(c)) {
+			t.Errorf("Marshal left %q unescaped in %s", c, b)
+		}
+	}
+	var s string
+	if err := Unmarshal(b, &s); err != nil || s != "<&>\u2028\ufffd\n" {
+		t.Errorf("round trip = %q, %v", s, err)
+	}
+
+	var buf bytes.Buffer
+	HTMLEscape(&buf, []byte("{\"a\":\"<b>&\u2028\"}"))
+	if bytes.ContainsAny(buf.Bytes(), "<>&") || bytes.Contains(buf.Bytes(), []byte("\u2028")) {
+		t.Errorf("HTMLEscape = %s", buf.Bytes())
+	}
+}
+
+// funcMarshaler的MarshalJSON返回它自己
+type funcMarshaler string
+
+func (m funcMarshaler) MarshalJSON() ([]byte, error) { return []byte(m), nil }
+
+// MarshalJSON的输出先检查语法, 再去掉空白写入, 需要时和HTMLEscape一样转义
+func TestMarshalerOutput(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"{ \"x\" : 1 }", `{"x":1}`},
+		{"[\n\t1,\n\t\" a b \"\n]\n", `[1," a b "]`},
+		{`"<&>"`, `"\u003c\u0026\u003e"`},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(funcMarshaler(tt.in))
+		if err != nil {
+			t.Errorf("Marshal(%q): %v", tt.in, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("Marshal(%q) = %s; want %s", tt.in, b, tt.want)
+		}
+	}
+	for _, in := range []string{"", "{", "1 2", "{'a':1}"} {
+		if _, err := Marshal(funcMarshaler(in)); err == nil {
+			t.Errorf("Marshal(%q) succeeded; want error for invalid MarshalJSON output", in)
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	cyclic := &node{Name: "c"}
+	cyclic.Next = cyclic
+	for _, v := range []interface{}{math.NaN(), math.Inf(1), make(chan int), map[[2]int]int{}, cyclic} {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("Marshal(%T) succeeded, want error", v)
+		}
+	}
+}
+
+func TestEncoderCache(t *testing.T) {
+	Marshal(tagged{})
+	typ := reflect.TypeOf(tagged{})
+	encoderCache.RLock()
+	f := encoderCache.m[typ]
+	encoderCache.RUnlock()
+	if f == nil {
+		t.Fatal("encoder for tagged not cached")
+	}
+}
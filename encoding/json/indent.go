@@ -0,0 +1,173 @@
+package json
+
+import "bytes"
+
+// 不解码, 直接用scanner的状态机检查与重排原始的JSON
+// 每个字节只经过一次step, 除了输出的缓冲区以外不分配内存
+
+// Valid 判断data是不是一个合法的JSON值
+func Valid(data []byte) bool {
+	return Formatter{}.Valid(data)
+}
+
+// Compact 去掉src里无意义的空白, 结果追加到dst
+// 出错时dst保持不变
+func Compact(dst *bytes.Buffer, src []byte) error {
+	return Formatter{}.Compact(dst, src)
+}
+
+// Indent 把src重新缩进后追加到dst
+// object与array里的每个元素另起一行, 行首是prefix加上若干个indent, 第一行不加prefix
+// src开头的空白会被去掉, 末尾的空白原样保留, 这样Encoder输出的换行不会丢
+// 出错时dst保持不变
+func Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
+	return Formatter{}.Indent(dst, src, prefix, indent)
+}
+
+// Formatter 和Valid, Compact, Indent一样, 只是可以设置最大嵌套深度
+// 零值使用DefaultMaxDepth
+type Formatter struct {
+	// MaxDepth 是object与array允许的最大嵌套深度, 0表示DefaultMaxDepth
+	MaxDepth int
+}
+
+// Valid 判断data是不是一个合法的JSON值
+func (f Formatter) Valid(data []byte) bool {
+	return f.Check(data) == nil
+}
+
+// Check 检查data是不是一个合法的JSON值, 不合法时返回*SyntaxError, 其中有出错的位置
+func (f Formatter) Check(data []byte) error {
+	scan := newScanner(f.MaxDepth)
+	defer freeScanner(scan)
+	for _, c := range data {
+		scan.bytes++
+		if scan.step(scan, c) == scanError {
+			return scan.err
+		}
+	}
+	if scan.eof() == scanError {
+		return scan.err
+	}
+	return nil
+}
+
+// Compact 去掉src里无意义的空白, 结果追加到dst
+func (f Formatter) Compact(dst *bytes.Buffer, src []byte) error {
+	return compact(dst, src, false, f.MaxDepth)
+}
+
+// compact 是Compact的实现, escape为true时同时做HTMLEscape
+func compact(dst *bytes.Buffer, src []byte, escape bool, maxDepth int) error {
+	origLen := dst.Len()
+	scan := newScanner(maxDepth)
+	defer freeScanner(scan)
+	start := 0
+	for i, c := range src {
+		if escape && (c == '<' || c == '>' || c == '&') {
+			if start < i {
+				dst.Write(src[start:i])
+			}
+			dst.WriteString(`\u00`)
+			dst.WriteByte(hex[c>>4])
+			dst.WriteByte(hex[c&0xF])
+			start = i + 1
+		}
+		// U+2028是E2 80 A8, U+2029是E2 80 A9
+		if escape && c == 0xE2 && i+2 < len(src) && src[i+1] == 0x80 && src[i+2]&^1 == 0xA8 {
+			if start < i {
+				dst.Write(src[start:i])
+			}
+			dst.WriteString(`\u202`)
+			dst.WriteByte(hex[src[i+2]&0xF])
+			start = i + 3
+		}
+		scan.bytes++
+		v := scan.step(scan, c)
+		if v >= scanSkipSpace {
+			if v == scanError {
+				break
+			}
+			// 空白, 以及顶层的值之后的字节, 都不要
+			if start < i {
+				dst.Write(src[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if scan.eof() == scanError {
+		dst.Truncate(origLen)
+		return scan.err
+	}
+	if start < len(src) {
+		dst.Write(src[start:])
+	}
+	return nil
+}
+
+func newline(dst *bytes.Buffer, prefix, indent string, depth int) {
+	dst.WriteByte('\n')
+	dst.WriteString(prefix)
+	for i := 0; i < depth; i++ {
+		dst.WriteString(indent)
+	}
+}
+
+// Indent 把src重新缩进后追加到dst
+func (f Formatter) Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
+	origLen := dst.Len()
+	scan := newScanner(f.MaxDepth)
+	defer freeScanner(scan)
+	needIndent := false
+	depth := 0
+	for _, c := range src {
+		scan.bytes++
+		v := scan.step(scan, c)
+		if v == scanSkipSpace {
+			continue
+		}
+		if v == scanError {
+			break
+		}
+		// [或者{之后, 不是马上结束的话, 换行缩进
+		if needIndent && v != scanEndObject && v != scanEndArray {
+			needIndent = false
+			depth++
+			newline(dst, prefix, indent, depth)
+		}
+
+		// 字符串里的字节, 字面量的后续字节, 原样输出
+		if v == scanContinue {
+			dst.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '{', '[':
+			needIndent = true
+			dst.WriteByte(c)
+		case ',':
+			dst.WriteByte(c)
+			newline(dst, prefix, indent, depth)
+		case ':':
+			dst.WriteByte(c)
+			dst.WriteByte(' ')
+		case '}', ']':
+			if needIndent {
+				// 空的object或者array, 不换行
+				needIndent = false
+			} else {
+				depth--
+				newline(dst, prefix, indent, depth)
+			}
+			dst.WriteByte(c)
+		default:
+			dst.WriteByte(c)
+		}
+	}
+	if scan.eof() == scanError {
+		dst.Truncate(origLen)
+		return scan.err
+	}
+	return nil
+}
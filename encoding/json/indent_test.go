@@ -0,0 +1,262 @@
+package json
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var validTests = []struct {
+	data string
+	ok   bool
+}{
+	{`foo`, false},
+	{`}{`, false},
+	{`{]`, false},
+	{`{}`, true},
+	{`{"foo":"bar"}`, true},
+	{`{"foo":"bar","bar":{"baz":["qux"]}}`, true},
+	{` [1, 2.5e-3, -0, true, null] `, true},
+	{`[1,]`, false},
+	{`01`, false},
+	{`"\x"`, false},
+	{`"\u12g4"`, false},
+	{`{"a" 1}`, false},
+	{`[1] [2]`, false},
+}
+
+func TestValid(t *testing.T) {
+	for _, tt := range validTests {
+		if ok := Valid([]byte(tt.data)); ok != tt.ok {
+			t.Errorf("Valid(%#q) = %v, want %v", tt.data, ok, tt.ok)
+		}
+	}
+}
+
+type example struct {
+	compact string
+	indent  string
+}
+
+var examples = []example{
+	{`1`, `1`},
+	{`{}`, `{}`},
+	{`[]`, `[]`},
+	{`{"":2}`, "{\n\t\"\": 2\n}"},
+	{`[3]`, "[\n\t3\n]"},
+	{`[1,2,3]`, "[\n\t1,\n\t2,\n\t3\n]"},
+	{`{"x":1}`, "{\n\t\"x\": 1\n}"},
+	{`[true,false,null,"x",{"a":[]},[{}]]`, "[\n\ttrue,\n\tfalse,\n\tnull,\n\t\"x\",\n\t{\n\t\t\"a\": []\n\t},\n\t[\n\t\t{}\n\t]\n]"},
+	{`{"s":"a, b: [c]"}`, "{\n\t\"s\": \"a, b: [c]\"\n}"},
+}
+
+func TestCompact(t *testing.T) {
+	var buf bytes.Buffer
+	for _, tt := range examples {
+		buf.Reset()
+		if err := Compact(&buf, []byte(tt.compact)); err != nil || buf.String() != tt.compact {
+			t.Errorf("Compact(%#q) = %#q, %v; want original", tt.compact, buf.String(), err)
+		}
+		buf.Reset()
+		if err := Compact(&buf, []byte(tt.indent)); err != nil || buf.String() != tt.compact {
+			t.Errorf("Compact(%#q) = %#q, %v; want %#q", tt.indent, buf.String(), err, tt.compact)
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	var buf bytes.Buffer
+	for _, tt := range examples {
+		buf.Reset()
+		if err := Indent(&buf, []byte(tt.indent), "", "\t"); err != nil || buf.String() != tt.indent {
+			t.Errorf("Indent(%#q) = %#q, %v; want original", tt.indent, buf.String(), err)
+		}
+		buf.Reset()
+		if err := Indent(&buf, []byte(tt.compact), "", "\t"); err != nil || buf.String() != tt.indent {
+			t.Errorf("Indent(%#q) = %#q, %v; want %#q", tt.compact, buf.String(), err, tt.indent)
+		}
+	}
+	buf.Reset()
+	Indent(&buf, []byte(`{"a":[1]}`), "> ", "  ")
+	if want := "{\n>   \"a\": [\n>     1\n>   ]\n> }"; buf.String() != want {
+		t.Errorf("Indent with prefix = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	b, err := MarshalIndent(map[string][]int{"a": {1}}, "", " ")
+	if err != nil || string(b) != "{\n \"a\": [\n  1\n ]\n}" {
+		t.Errorf("MarshalIndent = %q, %v", b, err)
+	}
+}
+
+func TestSyntaxErrorOffset(t *testing.T) {
+	tests := []struct {
+		data   string
+		offset int64
+	}{
+		{`[1,]`, 4},
+		{`{"a":1 "b":2}`, 8},
+		{`[1, 2`, 5},
+		{"[\n\n  tru]", 9},
+	}
+	var buf bytes.Buffer
+	for _, tt := range tests {
+		buf.WriteString("keep")
+		err := Compact(&buf, []byte(tt.data))
+		se, ok := err.(*SyntaxError)
+		if !ok || se.Offset != tt.offset {
+			t.Errorf("Compact(%#q) error = %v, want offset %d", tt.data, err, tt.offset)
+		} else if se.Offset != 0 && buf.String() != "keep" {
+			t.Errorf("Compact(%#q) modified dst on error: %q", tt.data, buf.String())
+		}
+		buf.Reset()
+		if err := (Formatter{}).Check([]byte(tt.data)); err == nil || err.(*SyntaxError).Offset != tt.offset {
+			t.Errorf("Check(%#q) = %v, want offset %d", tt.data, err, tt.offset)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	deep := func(n int) []byte {
+		return []byte(strings.Repeat("[", n) + strings.Repeat("]", n))
+	}
+	f := Formatter{MaxDepth: 10}
+	if !f.Valid(deep(10)) {
+		t.Error("depth 10 rejected")
+	}
+	err := f.Check(deep(11))
+	if se, ok := err.(*SyntaxError); !ok || se.Offset != 11 || !strings.Contains(se.Error(), "exceeded max depth") {
+		t.Errorf("Check(depth 11) = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := f.Indent(&buf, deep(11), "", " "); err == nil {
+		t.Error("Indent accepted depth 11")
+	}
+
+	// 默认的限制保护Unmarshal与Decoder
+	var v interface{}
+	if err := Unmarshal(deep(DefaultMaxDepth+1), &v); err == nil {
+		t.Error("Unmarshal accepted input deeper than DefaultMaxDepth")
+	}
+	dec := NewDecoder(bytes.NewReader([]byte(`[[[1]]] [[1]]`)))
+	dec.SetMaxDepth(2)
+	if err := dec.Decode(&v); err == nil {
+		t.Error("Decoder accepted depth 3 with SetMaxDepth(2)")
+	}
+}
+
+func TestTokenMaxDepth(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`[{"a":[1]}]`))
+	dec.SetMaxDepth(2)
+	for i, want := range []Token{Delim('['), Delim('{'), "a"} {
+		if tok, err := dec.Token(); err != nil || tok != want {
+			t.Fatalf("Token %d = %v, %v; want %v", i, tok, err, want)
+		}
+	}
+	_, err := dec.Token()
+	if se, ok := err.(*SyntaxError); !ok || se.Offset != 6 || !strings.Contains(se.Error(), "exceeded max depth") {
+		t.Errorf("Token at depth 3 = %v", err)
+	}
+
+	// Token进入的深度加上Decode的值的深度一起算
+	dec = NewDecoder(strings.NewReader(`[[1], [[2]]]`))
+	dec.SetMaxDepth(2)
+	if _, err := dec.Token(); err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Errorf("Decode at total depth 2: %v", err)
+	}
+	if err := dec.Decode(&v); err == nil || !strings.Contains(err.Error(), "exceeded max depth") {
+		t.Errorf("Decode at total depth 3 = %v; want max depth error", err)
+	}
+}
+
+func TestCompactEscape(t *testing.T) {
+	var buf bytes.Buffer
+	if err := compact(&buf, []byte("{\"<a>\" : \"&\u2028\"}"), true, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"\u003ca\u003e":"\u0026\u2028"}`; buf.String() != want {
+		t.Errorf("compact = %s, want %s", buf.String(), want)
+	}
+}
+
+// benchDoc 生成一个有很多token的文档
+func benchDoc() []byte {
+	var b bytes.Buffer
+	b.WriteString("[\n")
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		b.WriteString(`  {"id": ` + strconv.Itoa(i) + `, "name": "item ` + strconv.Itoa(i) + `", "tags": ["a", "b"], "ok": true, "v": 1.5e3}`)
+	}
+	b.WriteString("\n]")
+	return b.Bytes()
+}
+
+func TestScannerAllocs(t *testing.T) {
+	data := benchDoc()
+	var buf bytes.Buffer
+	buf.Grow(2 * len(data))
+	allocs := testing.AllocsPerRun(50, func() {
+		buf.Reset()
+		if !Valid(data) {
+			t.Fatal("invalid")
+		}
+		Compact(&buf, data)
+		buf.Reset()
+		Indent(&buf, data, "", "  ")
+	})
+	// 分配次数和token的个数无关, scanner来自池子
+	// -race时sync.Pool会随机丢掉一些对象, 所以这里留了余量
+	if allocs > 10 {
+		t.Errorf("%v allocations per run for %d bytes, want O(1)", allocs, len(data))
+	}
+}
+
+func BenchmarkValid(b *testing.B) {
+	data := benchDoc()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !Valid(data) {
+			b.Fatal("invalid")
+		}
+	}
+}
+
+func BenchmarkCompact(b *testing.B) {
+	data := benchDoc()
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := Compact(&buf, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIndent(b *testing.B) {
+	data := benchDoc()
+	var buf bytes.Buffer
+	buf.Grow(2 * len(data))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := Indent(&buf, data, "", "\t"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// JSON Lines(也叫NDJSON): 每行一个完整的JSON值, 行与行之间用\n分隔
+// 适合日志, 事件流这种一直往后追加的数据
+
+// DefaultMaxLineSize 是LinesReader默认允许的最长一行, 不包括换行符
+const DefaultMaxLineSize = 1 << 20
+
+// LineError 是读写JSON Lines时某一行出的错, Line从1开始
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return "json: line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+}
+
+// LinesWriter 把每个值编码成一行写到w里
+type LinesWriter struct {
+	enc  *Encoder
+	line int
+}
+
+// NewLinesWriter 返回一个写到w的LinesWriter
+func NewLinesWriter(w io.Writer) *LinesWriter {
+	return &LinesWriter{enc: NewEncoder(w)}
+}
+
+// SetEscapeHTML 和Encoder.SetEscapeHTML一样
+func (lw *LinesWriter) SetEscapeHTML(on bool) {
+	lw.enc.SetEscapeHTML(on)
+}
+
+// Encode 把v编码成一行写出去
+// 编码的结果里不会有换行符, 字符串里的换行会被转义成\n
+func (lw *LinesWriter) Encode(v interface{}) error {
+	lw.line++
+	if err := lw.enc.Encode(v); err != nil {
+		return &LineError{lw.line, err}
+	}
+	return nil
+}
+
+// Lines 返回已经写了多少行
+func (lw *LinesWriter) Lines() int {
+	return lw.line
+}
+
+// LinesReader 一行一行地读JSON值
+// 底下是一个bufio.Scanner, 空行会被跳过, 行尾的\r会被去掉
+type LinesReader struct {
+	s       *bufio.Scanner
+	own     bool // s是NewLinesReader创建的, 第一次Decode时设置它的缓冲区与分割函数
+	maxLine int
+	started bool
+	line    int
+	d       decodeState
+	err     error
+}
+
+// NewLinesReader 返回一个从r读取的LinesReader, 最长一行为DefaultMaxLineSize
+func NewLinesReader(r io.Reader) *LinesReader {
+	lr := NewLinesScanner(bufio.NewScanner(r))
+	lr.own = true
+	return lr
+}
+
+// NewLinesScanner 用已有的bufio.Scanner读取, s可以已经Scan过
+// LinesReader不会修改s的缓冲区与分割函数, 分割函数应该按行分割(bufio.NewScanner的默认值)
+// 超过SetMaxLineSize的行仍然会返回bufio.ErrTooLong, 但是超过s自己缓冲区上限的行由s报错
+// LineError里的行号从LinesReader读到的第一行算起
+func NewLinesScanner(s *bufio.Scanner) *LinesReader {
+	return &LinesReader{s: s, maxLine: DefaultMaxLineSize}
+}
+
+// SetMaxLineSize 设置最长一行的字节数, 必须在第一次Decode之前调用
+// 超过的行返回bufio.ErrTooLong
+func (lr *LinesReader) SetMaxLineSize(n int) {
+	if lr.started {
+		panic("json: SetMaxLineSize called after Decode")
+	}
+	lr.maxLine = n
+}
+
+// UseNumber 和Decoder.UseNumber一样
+func (lr *LinesReader) UseNumber() { lr.d.useNumber = true }
+
+// DisallowUnknownFields 和Decoder.DisallowUnknownFields一样
+func (lr *LinesReader) DisallowUnknownFields() { lr.d.disallowUnknownFields = true }
+
+// Decode 读下一行并解码到v里, 没有更多的行时返回io.EOF
+//
+// 某一行不是合法的JSON, 或者类型不匹配时, 返回*LineError, 之后还可以继续读下一行
+// 读底层数据出错, 或者一行太长时, 返回*LineError, 之后都返回同一个错误
+func (lr *LinesReader) Decode(v interface{}) error {
+	if lr.err != nil {
+		return lr.err
+	}
+	if !lr.started && lr.own {
+		// 缓冲区从小的开始, 按需增长, 多留两个字节给\r\n
+		initial := 4096
+		if initial > lr.maxLine+2 {
+			initial = lr.maxLine + 2
+		}
+		lr.s.Buffer(make([]byte, 0, initial), lr.maxLine+2)
+		lr.s.Split(bufio.ScanLines)
+	}
+	lr.started = true
+
+	for lr.s.Scan() {
+		lr.line++
+		b := lr.s.Bytes()
+		if len(b) > lr.maxLine {
+			lr.err = &LineError{lr.line, bufio.ErrTooLong}
+			return lr.err
+		}
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		if err := checkValid(b, &lr.d.scan); err != nil {
+			return &LineError{lr.line, err}
+		}
+		lr.d.init(b)
+		if err := lr.d.unmarshal(v); err != nil {
+			return &LineError{lr.line, err}
+		}
+		return nil
+	}
+
+	if err := lr.s.Err(); err != nil {
+		// 出错的是还没读完的下一行
+		lr.err = &LineError{lr.line + 1, err}
+	} else {
+		lr.err = io.EOF
+	}
+	return lr.err
+}
+
+// Line 返回最近一次Decode读到的行号
+func (lr *LinesReader) Line() int {
+	return lr.line
+}
@@ -0,0 +1,147 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+type event struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+func TestLinesRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	lw := NewLinesWriter(&buf)
+	in := []event{{1, "a\nb"}, {2, ""}, {3, "<c>"}}
+	for _, ev := range in {
+		if err := lw.Encode(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != len(in) || lw.Lines() != len(in) {
+		t.Fatalf("wrote %d newlines, Lines() = %d; want %d", n, lw.Lines(), len(in))
+	}
+
+	lr := NewLinesReader(&buf)
+	for i := 0; ; i++ {
+		var ev event
+		err := lr.Decode(&ev)
+		if err == io.EOF {
+			if i != len(in) {
+				t.Errorf("read %d events, want %d", i, len(in))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev != in[i] {
+			t.Errorf("event %d = %+v, want %+v", i, ev, in[i])
+		}
+	}
+}
+
+// MarshalJSON返回多行的输出时, Encoder与LinesWriter仍然要保证一行一个值
+func TestMultilineMarshaler(t *testing.T) {
+	v := []interface{}{funcMarshaler("{\n  \"a\": [\n    1\n  ]\n}"), 2}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[{\"a\":[1]},2]\n"; buf.String() != want {
+		t.Errorf("Encoder wrote %q; want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	lw := NewLinesWriter(&buf)
+	for i := 0; i < 2; i++ {
+		if err := lw.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lr := NewLinesReader(&buf)
+	for i := 0; i < 2; i++ {
+		var got []interface{}
+		if err := lr.Decode(&got); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if len(got) != 2 || lr.Line() != i+1 {
+			t.Errorf("line %d = %v (Line() = %d)", i+1, got, lr.Line())
+		}
+	}
+}
+
+func TestLinesReaderErrors(t *testing.T) {
+	input := "{\"id\":1}\r\n\n{\"id\":\n{\"id\":\"x\"}\n{\"id\":4}\n"
+	lr := NewLinesReader(strings.NewReader(input))
+	var ev event
+	if err := lr.Decode(&ev); err != nil || ev.ID != 1 {
+		t.Fatalf("line 1: %v %+v", err, ev)
+	}
+	for _, line := range []int{3, 4} {
+		err := lr.Decode(&ev)
+		le, ok := err.(*LineError)
+		if !ok || le.Line != line {
+			t.Errorf("got %v, want error on line %d", err, line)
+		}
+	}
+	// 出错的行之后还可以继续读
+	if err := lr.Decode(&ev); err != nil || ev.ID != 4 || lr.Line() != 5 {
+		t.Errorf("line 5: %v %+v line %d", err, ev, lr.Line())
+	}
+	if err := lr.Decode(&ev); err != io.EOF {
+		t.Errorf("at end: %v", err)
+	}
+}
+
+func TestLinesReaderMaxLineSize(t *testing.T) {
+	input := "[1]\n[" + strings.Repeat("1,", 20) + "1]\n[2]\n"
+	lr := NewLinesScanner(bufio.NewScanner(strings.NewReader(input)))
+	lr.SetMaxLineSize(16)
+	var v []int
+	if err := lr.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	err := lr.Decode(&v)
+	if le, ok := err.(*LineError); !ok || le.Line != 2 || le.Err != bufio.ErrTooLong {
+		t.Fatalf("got %v, want ErrTooLong on line 2", err)
+	}
+	if err2 := lr.Decode(&v); err2 != err {
+		t.Errorf("error is not sticky: %v", err2)
+	}
+}
+
+// NewLinesScanner不能动调用者的Scanner, 它可能已经Scan过, 或者指定了自己的缓冲区
+func TestLinesScannerKeepsConfig(t *testing.T) {
+	s := bufio.NewScanner(strings.NewReader("id,name\n[1]\n[2]\n"))
+	if !s.Scan() || s.Text() != "id,name" {
+		t.Fatal("reading the header failed")
+	}
+	lr := NewLinesScanner(s)
+	for _, want := range []int{1, 2} {
+		var v []int
+		if err := lr.Decode(&v); err != nil || len(v) != 1 || v[0] != want {
+			t.Fatalf("Decode = %v, %v; want [%d]", v, err, want)
+		}
+	}
+	var v []int
+	if err := lr.Decode(&v); err != io.EOF {
+		t.Errorf("at end: %v", err)
+	}
+
+	s = bufio.NewScanner(strings.NewReader("[1]\n[" + strings.Repeat("1,", 20) + "1]\n"))
+	s.Buffer(make([]byte, 0, 8), 16)
+	lr = NewLinesScanner(s)
+	if err := lr.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	// 超过调用者设置的缓冲区上限, 而不是DefaultMaxLineSize
+	err := lr.Decode(&v)
+	if le, ok := err.(*LineError); !ok || le.Line != 2 || le.Err != bufio.ErrTooLong {
+		t.Fatalf("got %v, want ErrTooLong on line 2", err)
+	}
+}
@@ -0,0 +1,371 @@
+package json
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// JSON Patch(RFC 6902)与JSON Merge Patch(RFC 7396)
+// 都作用在Unmarshal到interface{}的文档上, 也提供了直接处理原始字节的版本
+// 原始字节的版本解码时使用Number, 没有被修改的数字会原样输出
+
+// PatchOperation 是JSON Patch里的一个操作
+type PatchOperation struct {
+	Op    string      // add, remove, replace, move, copy, test
+	Path  string      // 操作的位置
+	From  string      // move与copy的来源
+	Value interface{} // add, replace与test的值
+
+	hasValue bool // 区分"value": null与没有value
+}
+
+// Patch 是按顺序执行的一组操作
+type Patch []PatchOperation
+
+// PatchError 是JSON Patch里某个操作失败的错误
+// Index是操作在Patch里的下标, Err通常是*PointerError, 指出具体哪一段路径有问题
+type PatchError struct {
+	Index int
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	s := "json: patch operation " + strconv.Itoa(e.Index)
+	if e.Op != "" {
+		s += " (" + e.Op + " " + strconv.Quote(e.Path) + ")"
+	}
+	return s + ": " + e.Err.Error()
+}
+
+var (
+	errTestFailed   = errors.New("test failed: values are not equal")
+	errMoveIntoSelf = errors.New("cannot move a value into one of its children")
+	errRemoveRoot   = errors.New("cannot remove the whole document")
+)
+
+// DecodePatch 解析JSON Patch文档, 它必须是一个由操作对象组成的数组
+func DecodePatch(data []byte) (Patch, error) {
+	v, err := decodeTree(data)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("json: patch must be an array, got " + kindName(v))
+	}
+	p := make(Patch, len(list))
+	for i, elem := range list {
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			return nil, &PatchError{Index: i, Err: errors.New("operation must be an object, got " + kindName(elem))}
+		}
+		op := &p[i]
+		if op.Op, ok = m["op"].(string); !ok {
+			return nil, &PatchError{Index: i, Err: errors.New(`missing or non-string "op"`)}
+		}
+		if op.Path, ok = m["path"].(string); !ok {
+			return nil, &PatchError{Index: i, Op: op.Op, Err: errors.New(`missing or non-string "path"`)}
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value, op.hasValue = m["value"]; !op.hasValue {
+				return nil, &PatchError{i, op.Op, op.Path, errors.New(`missing "value"`)}
+			}
+		case "move", "copy":
+			if op.From, ok = m["from"].(string); !ok {
+				return nil, &PatchError{i, op.Op, op.Path, errors.New(`missing or non-string "from"`)}
+			}
+		case "remove":
+		default:
+			return nil, &PatchError{i, op.Op, op.Path, errors.New("unknown operation")}
+		}
+	}
+	return p, nil
+}
+
+// Apply 把p应用到doc上, 返回新的文档
+// doc不会被修改; 任何一个操作失败时返回错误, 整个Patch都不生效
+func (p Patch) Apply(doc interface{}) (interface{}, error) {
+	doc = deepCopy(doc)
+	for i := range p {
+		var err error
+		doc, err = p[i].apply(doc)
+		if err != nil {
+			return nil, &PatchError{i, p[i].Op, p[i].Path, err}
+		}
+	}
+	return doc, nil
+}
+
+func (op *PatchOperation) apply(doc interface{}) (interface{}, error) {
+	path, err := ParsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add":
+		return patchAdd(doc, path, deepCopy(op.Value))
+	case "remove":
+		return patchRemove(doc, path)
+	case "replace":
+		if _, err := path.Get(doc); err != nil {
+			return nil, err
+		}
+		return replaceAt(doc, path, deepCopy(op.Value))
+	case "move", "copy":
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := from.Get(doc)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return patchAdd(doc, path, deepCopy(v))
+		}
+		if len(path) > len(from) && from.String() == path[:len(from)].String() {
+			return nil, errMoveIntoSelf
+		}
+		if doc, err = patchRemove(doc, from); err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, v)
+	case "test":
+		v, err := path.Get(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(v, op.Value) {
+			return nil, errTestFailed
+		}
+		return doc, nil
+	}
+	return nil, errors.New("unknown operation")
+}
+
+// patchAdd 把value加到p处: 对象里添加或者替换成员, 数组里插入元素
+func patchAdd(doc interface{}, p Pointer, value interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	parentPtr, last := p.Parent()
+	parent, err := parentPtr.Get(doc)
+	if err != nil {
+		return nil, err
+	}
+	switch x := parent.(type) {
+	case map[string]interface{}:
+		x[last] = value
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(x), true)
+		if err != nil {
+			return nil, &PointerError{p.String(), err.Error()}
+		}
+		x = append(x, nil)
+		copy(x[i+1:], x[i:])
+		x[i] = value
+		// 切片可能重新分配了, 要放回父节点里
+		return replaceAt(doc, parentPtr, x)
+	}
+	return nil, &PointerError{p.String(), "cannot add to " + kindName(parent)}
+}
+
+// patchRemove 删除p处的值, 它必须存在
+func patchRemove(doc interface{}, p Pointer) (interface{}, error) {
+	if len(p) == 0 {
+		return nil, errRemoveRoot
+	}
+	if _, err := p.Get(doc); err != nil {
+		return nil, err
+	}
+	parentPtr, last := p.Parent()
+	parent, _ := parentPtr.Get(doc)
+	switch x := parent.(type) {
+	case map[string]interface{}:
+		delete(x, last)
+		return doc, nil
+	case []interface{}:
+		i, _ := arrayIndex(last, len(x), false)
+		x = append(x[:i], x[i+1:]...)
+		return replaceAt(doc, parentPtr, x)
+	}
+	return nil, &PointerError{p.String(), "cannot remove from " + kindName(parent)}
+}
+
+// replaceAt 把p处的值换成value, p的父节点必须存在
+func replaceAt(doc interface{}, p Pointer, value interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	parentPtr, last := p.Parent()
+	parent, err := parentPtr.Get(doc)
+	if err != nil {
+		return nil, err
+	}
+	switch x := parent.(type) {
+	case map[string]interface{}:
+		x[last] = value
+	case []interface{}:
+		i, err := arrayIndex(last, len(x), false)
+		if err != nil {
+			return nil, &PointerError{p.String(), err.Error()}
+		}
+		x[i] = value
+	default:
+		return nil, &PointerError{p.String(), "cannot index into " + kindName(parent)}
+	}
+	return doc, nil
+}
+
+// MergePatch 按RFC 7396把patch合并到doc上, 返回新的文档, doc与patch都不会被修改
+// patch里值为null的成员会从doc里删除, 对象递归合并, 其它的值直接替换
+func MergePatch(doc, patch interface{}) interface{} {
+	return mergePatch(deepCopy(doc), deepCopy(patch))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	pm, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	tm, ok := target.(map[string]interface{})
+	if !ok {
+		tm = make(map[string]interface{})
+	}
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+		} else {
+			tm[k] = mergePatch(tm[k], v)
+		}
+	}
+	return tm
+}
+
+// ApplyPatch 把JSON Patch文档patch应用到JSON文档doc上, 返回新的文档
+func ApplyPatch(doc, patch []byte) ([]byte, error) {
+	p, err := DecodePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeTree(doc)
+	if err != nil {
+		return nil, err
+	}
+	if v, err = p.Apply(v); err != nil {
+		return nil, err
+	}
+	return encodeTree(v)
+}
+
+// ApplyMergePatch 把JSON Merge Patch文档patch合并到JSON文档doc上, 返回新的文档
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	d, err := decodeTree(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodeTree(patch)
+	if err != nil {
+		return nil, err
+	}
+	return encodeTree(mergePatch(d, p))
+}
+
+// decodeTree 把data解码成interface{}, 数字保留为Number
+func decodeTree(data []byte) (interface{}, error) {
+	var d decodeState
+	if err := checkValid(data, &d.scan); err != nil {
+		return nil, err
+	}
+	d.useNumber = true
+	var v interface{}
+	if err := d.init(data).unmarshal(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// encodeTree 用encodeState把文档重新编码
+func encodeTree(v interface{}) ([]byte, error) {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+	if err := e.marshal(v, true); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.Bytes()...), nil
+}
+
+// deepCopy 复制一个解码后的文档, 对象与数组都是新的
+func deepCopy(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, elem := range x {
+			m[k] = deepCopy(elem)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(x))
+		for i, elem := range x {
+			s[i] = deepCopy(elem)
+		}
+		return s
+	}
+	return v
+}
+
+// jsonEqual 按RFC 6902里test的规则比较两个值
+// 数字按数值精确比较, 所以Number("1.0")与float64(1)相等,
+// 而9007199254740992与9007199254740993不相等(转成float64后它们是同一个值)
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, xv := range x {
+			yv, ok := y[k]
+			if !ok || !jsonEqual(xv, yv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case float64, Number:
+		rx, ok1 := toRat(a)
+		ry, ok2 := toRat(b)
+		return ok1 && ok2 && rx.Cmp(ry) == 0
+	}
+	return a == b
+}
+
+// toRat 把数字转为big.Rat, float64转换时没有误差
+func toRat(v interface{}) (*big.Rat, bool) {
+	switch x := v.(type) {
+	case float64:
+		r := new(big.Rat)
+		if r.SetFloat64(x) == nil {
+			return nil, false
+		}
+		return r, true
+	case Number:
+		return x.Rat()
+	}
+	return nil, false
+}
@@ -0,0 +1,138 @@
+package json
+
+import (
+	"testing"
+)
+
+var patchTests = []struct {
+	doc, patch, want string
+}{
+	// RFC 6902附录A里的例子
+	{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+	{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+	{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+	{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+	{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+	{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+		`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+		`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+	{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+	{`{"baz":"qux","foo":["a",2,"c"]}`,
+		`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+		`{"baz":"qux","foo":["a",2,"c"]}`},
+	{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`},
+	{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+	{`{"a":{"b":1.50}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/a/b","value":null}]`, `{"a":{"b":null},"c":{"b":1.50}}`},
+	{`{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+}
+
+func TestApplyPatch(t *testing.T) {
+	for i, tt := range patchTests {
+		got, err := ApplyPatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("#%d: ApplyPatch = %s, %v; want %s", i, got, err, tt.want)
+		}
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		doc, patch string
+		index      int
+		at         string // 出错的PointerError位置, 为空时不检查
+	}{
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, 0, ""},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, 0, "/baz"},
+		{`{"a":[1]}`, `[{"op":"remove","path":"/a/0"},{"op":"remove","path":"/a/0"}]`, 1, "/a/0"},
+		{`{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, 0, ""},
+		{`{"a":[]}`, `[{"op":"add","path":"/a/1","value":0}]`, 0, "/a/1"},
+		{`{}`, `[{"op":"frob","path":""}]`, 0, ""},
+		{`{}`, `[{"op":"add","path":"/x"}]`, 0, ""},
+		// 超过2^53的整数转成float64后相等, 但它们不是同一个数
+		{`{"n":9007199254740993}`, `[{"op":"test","path":"/n","value":9007199254740992}]`, 0, ""},
+	}
+	for i, tt := range tests {
+		_, err := ApplyPatch([]byte(tt.doc), []byte(tt.patch))
+		pe, ok := err.(*PatchError)
+		if !ok || pe.Index != tt.index {
+			t.Errorf("#%d: got %v, want PatchError for operation %d", i, err, tt.index)
+			continue
+		}
+		if tt.at != "" {
+			if ptrErr, ok := pe.Err.(*PointerError); !ok || ptrErr.Pointer != tt.at {
+				t.Errorf("#%d: got %v, want pointer error at %q", i, pe.Err, tt.at)
+			}
+		}
+	}
+}
+
+func TestJSONEqualNumbers(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want bool
+	}{
+		{Number("1.0"), 1.0, true},
+		{Number("1e2"), Number("100"), true},
+		{Number("-0"), 0.0, true},
+		{Number("0.1"), 0.1, false}, // float64的0.1不是精确的0.1
+		{Number("9007199254740993"), Number("9007199254740992"), false},
+		{Number("9007199254740993"), 9007199254740992.0, false},
+		{Number("9007199254740992"), 9007199254740992.0, true},
+		{Number("12345678901234567890123"), Number("12345678901234567890123.0"), true},
+		{Number("1"), "1", false},
+		{[]interface{}{Number("2")}, []interface{}{2.0}, true},
+	}
+	for _, tt := range tests {
+		if got := jsonEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("jsonEqual(%#v, %#v) = %v; want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := jsonEqual(tt.b, tt.a); got != tt.want {
+			t.Errorf("jsonEqual(%#v, %#v) = %v; want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestPatchAtomic(t *testing.T) {
+	var doc interface{}
+	Unmarshal([]byte(`{"a":[1,2]}`), &doc)
+	p, err := DecodePatch([]byte(`[{"op":"add","path":"/a/0","value":0},{"op":"remove","path":"/nope"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Apply(doc); err == nil {
+		t.Fatal("Apply succeeded")
+	}
+	if b, _ := Marshal(doc); string(b) != `{"a":[1,2]}` {
+		t.Errorf("doc modified by failed patch: %s", b)
+	}
+}
+
+// RFC 7396附录A里的例子
+var mergePatchTests = []struct {
+	doc, patch, want string
+}{
+	{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+	{`{"a":"b"}`, `{"a":null}`, `{}`},
+	{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+	{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+	{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+	{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+	{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+	{`{"a":"b"}`, `["c"]`, `["c"]`},
+	{`{"a":"foo"}`, `null`, `null`},
+	{`{"a":"foo"}`, `"bar"`, `"bar"`},
+	{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+	{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+	{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	for i, tt := range mergePatchTests {
+		got, err := ApplyMergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("#%d: ApplyMergePatch = %s, %v; want %s", i, got, err, tt.want)
+		}
+	}
+}
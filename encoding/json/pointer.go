@@ -0,0 +1,268 @@
+package json
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Pointer 是RFC 6901定义的JSON Pointer, 例如"/a/0/b"
+// 每个元素是一个已经去掉转义的引用(reference token), 空的Pointer指向整个文档
+type Pointer []string
+
+// PointerError 是解析或者计算JSON Pointer时出的错
+// Pointer是出错的那个位置, 而不一定是整个Pointer
+type PointerError struct {
+	Pointer string
+	Msg     string
+}
+
+func (e *PointerError) Error() string {
+	return "json: pointer " + strconv.Quote(e.Pointer) + ": " + e.Msg
+}
+
+// ParsePointer 解析s, s必须为空或者以/开头
+// ~1表示/, ~0表示~, 其它的~都是错误
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if s[0] != '/' {
+		return nil, &PointerError{s, "must be empty or start with '/'"}
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, tok := range tokens {
+		if strings.IndexByte(tok, '~') < 0 {
+			continue
+		}
+		for j := 0; j < len(tok); j++ {
+			if tok[j] == '~' && (j+1 == len(tok) || tok[j+1] != '0' && tok[j+1] != '1') {
+				return nil, &PointerError{s, "invalid escape in " + strconv.Quote(tok)}
+			}
+		}
+		// 先换~1再换~0, 否则"~01"会被错误地变成"/"
+		tok = strings.Replace(tok, "~1", "/", -1)
+		tokens[i] = strings.Replace(tok, "~0", "~", -1)
+	}
+	return Pointer(tokens), nil
+}
+
+// String 返回p的字符串形式, 与ParsePointer相反
+func (p Pointer) String() string {
+	var b []byte
+	for _, tok := range p {
+		b = append(b, '/')
+		for i := 0; i < len(tok); i++ {
+			switch tok[i] {
+			case '~':
+				b = append(b, '~', '0')
+			case '/':
+				b = append(b, '~', '1')
+			default:
+				b = append(b, tok[i])
+			}
+		}
+	}
+	return string(b)
+}
+
+// Parent 返回p的父节点与最后一个引用, p不能为空
+func (p Pointer) Parent() (Pointer, string) {
+	return p[:len(p)-1], p[len(p)-1]
+}
+
+// Get 在Unmarshal到interface{}的文档里找p指向的值
+// 文档里的对象必须是map[string]interface{}, 数组必须是[]interface{}
+func (p Pointer) Get(doc interface{}) (interface{}, error) {
+	v := doc
+	for i, tok := range p {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			elem, ok := x[tok]
+			if !ok {
+				return nil, &PointerError{p[:i+1].String(), "member not found"}
+			}
+			v = elem
+		case []interface{}:
+			n, err := arrayIndex(tok, len(x), false)
+			if err != nil {
+				return nil, &PointerError{p[:i+1].String(), err.Error()}
+			}
+			v = x[n]
+		default:
+			return nil, &PointerError{p[:i+1].String(), "cannot index into " + kindName(v)}
+		}
+	}
+	return v, nil
+}
+
+// GetRaw 在原始的JSON数据里找p指向的值, 返回它的原始字节(data的子切片)
+// 不会解码整个文档, 不需要的值直接跳过
+func (p Pointer) GetRaw(data []byte) ([]byte, error) {
+	var d decodeState
+	if err := checkValid(data, &d.scan); err != nil {
+		return nil, err
+	}
+	d.init(data)
+	d.skipSpace()
+
+	for i, tok := range p {
+		found := false
+		switch d.data[d.off] {
+		case '{':
+			d.off++
+			for {
+				d.skipSpace()
+				if d.data[d.off] == '}' {
+					break
+				}
+				start := d.off
+				d.skipString()
+				key, ok := unquote(d.data[start:d.off])
+				if !ok {
+					return nil, errPhase
+				}
+				d.skipSpace()
+				d.off++ // :
+				d.skipSpace()
+				// 对象里有重复的key时, 和Unmarshal一样取最后一个
+				if key == tok {
+					found = true
+					start := d.off
+					d.skip()
+					end := d.off
+					if !d.hasLaterKey(tok) {
+						d.off = start
+						break
+					}
+					d.off = end
+				} else {
+					d.skip()
+				}
+				d.skipSpace()
+				if d.data[d.off] == ',' {
+					d.off++
+				}
+			}
+			if !found {
+				return nil, &PointerError{p[:i+1].String(), "member not found"}
+			}
+		case '[':
+			d.off++
+			n := -1
+			for k := 0; ; k++ {
+				d.skipSpace()
+				if d.data[d.off] == ']' {
+					n = k
+					break
+				}
+				if strconv.Itoa(k) == tok {
+					found = true
+					break
+				}
+				d.skip()
+				d.skipSpace()
+				if d.data[d.off] == ',' {
+					d.off++
+				}
+			}
+			if !found {
+				// 再按数组长度检查一遍, 给出更准确的错误
+				_, err := arrayIndex(tok, n, false)
+				return nil, &PointerError{p[:i+1].String(), err.Error()}
+			}
+		default:
+			return nil, &PointerError{p[:i+1].String(), "cannot index into " + rawKindName(d.data[d.off])}
+		}
+	}
+	return d.rawValue(), nil
+}
+
+// hasLaterKey 看当前对象里d.off之后还有没有名为key的成员, 不移动d.off
+func (d *decodeState) hasLaterKey(key string) bool {
+	off := d.off
+	defer func() { d.off = off }()
+	for {
+		d.skipSpace()
+		if d.data[d.off] == ',' {
+			d.off++
+			d.skipSpace()
+		}
+		if d.data[d.off] == '}' {
+			return false
+		}
+		start := d.off
+		d.skipString()
+		if k, _ := unquote(d.data[start:d.off]); k == key {
+			return true
+		}
+		d.skipSpace()
+		d.off++ // :
+		d.skip()
+	}
+}
+
+// arrayIndex 把引用解析成长度为n的数组的下标
+// allowEnd为true时, "-"与n表示数组末尾之后的位置(用于add)
+func arrayIndex(tok string, n int, allowEnd bool) (int, error) {
+	if tok == "-" {
+		if allowEnd {
+			return n, nil
+		}
+		return 0, &indexError{"index \"-\" refers past the end of the array"}
+	}
+	// 不允许前导0与符号
+	if tok == "" || len(tok) > 1 && tok[0] == '0' {
+		return 0, &indexError{"invalid array index " + strconv.Quote(tok)}
+	}
+	for i := 0; i < len(tok); i++ {
+		if tok[i] < '0' || tok[i] > '9' {
+			return 0, &indexError{"invalid array index " + strconv.Quote(tok)}
+		}
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i > n || i == n && !allowEnd {
+		return 0, &indexError{"array index " + tok + " out of range (length " + strconv.Itoa(n) + ")"}
+	}
+	return i, nil
+}
+
+type indexError struct {
+	msg string
+}
+
+func (e *indexError) Error() string { return e.msg }
+
+// kindName 返回解码后的值对应的JSON类型名, 用在错误信息里
+func kindName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64, Number:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return "value"
+}
+
+func rawKindName(c byte) string {
+	switch c {
+	case 'n':
+		return "null"
+	case 't', 'f':
+		return "boolean"
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	}
+	return "number"
+}
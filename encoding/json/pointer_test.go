@@ -0,0 +1,107 @@
+package json
+
+import (
+	"reflect"
+	"testing"
+)
+
+// RFC 6901第5节的例子
+const rfc6901Doc = `{
+	"foo": ["bar", "baz"],
+	"": 0,
+	"a/b": 1,
+	"c%d": 2,
+	"e^f": 3,
+	"g|h": 4,
+	"i\\j": 5,
+	"k\"l": 6,
+	" ": 7,
+	"m~n": 8
+}`
+
+var pointerTests = []struct {
+	ptr  string
+	want string // 原始JSON
+}{
+	{"", ""},
+	{"/foo", `["bar", "baz"]`},
+	{"/foo/0", `"bar"`},
+	{"/", `0`},
+	{"/a~1b", `1`},
+	{"/c%d", `2`},
+	{"/e^f", `3`},
+	{"/g|h", `4`},
+	{"/i\\j", `5`},
+	{"/k\"l", `6`},
+	{"/ ", `7`},
+	{"/m~0n", `8`},
+}
+
+func TestPointer(t *testing.T) {
+	var doc interface{}
+	if err := Unmarshal([]byte(rfc6901Doc), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range pointerTests {
+		p, err := ParsePointer(tt.ptr)
+		if err != nil {
+			t.Errorf("ParsePointer(%q): %v", tt.ptr, err)
+			continue
+		}
+		if s := p.String(); s != tt.ptr {
+			t.Errorf("ParsePointer(%q).String() = %q", tt.ptr, s)
+		}
+		raw := tt.want
+		if raw == "" {
+			raw = rfc6901Doc
+		}
+		var want interface{}
+		Unmarshal([]byte(raw), &want)
+
+		got, err := p.Get(doc)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%q) = %v, %v; want %v", tt.ptr, got, err, want)
+		}
+		b, err := p.GetRaw([]byte(rfc6901Doc))
+		if err != nil || string(b) != raw {
+			t.Errorf("GetRaw(%q) = %s, %v; want %s", tt.ptr, b, err, raw)
+		}
+	}
+}
+
+func TestPointerErrors(t *testing.T) {
+	tests := []struct {
+		ptr, at string
+	}{
+		{"/missing/x", "/missing"},
+		{"/foo/2", "/foo/2"},
+		{"/foo/01", "/foo/01"},
+		{"/foo/-", "/foo/-"},
+		{"/foo/0/x", "/foo/0/x"},
+	}
+	var doc interface{}
+	Unmarshal([]byte(rfc6901Doc), &doc)
+	for _, tt := range tests {
+		p, _ := ParsePointer(tt.ptr)
+		_, err := p.Get(doc)
+		if pe, ok := err.(*PointerError); !ok || pe.Pointer != tt.at {
+			t.Errorf("Get(%q) error = %v, want error at %q", tt.ptr, err, tt.at)
+		}
+		_, err = p.GetRaw([]byte(rfc6901Doc))
+		if pe, ok := err.(*PointerError); !ok || pe.Pointer != tt.at {
+			t.Errorf("GetRaw(%q) error = %v, want error at %q", tt.ptr, err, tt.at)
+		}
+	}
+	for _, s := range []string{"a", "/~2", "/x~"} {
+		if _, err := ParsePointer(s); err == nil {
+			t.Errorf("ParsePointer(%q) succeeded", s)
+		}
+	}
+}
+
+func TestGetRawDuplicateKey(t *testing.T) {
+	b, err := Pointer{"a"}.GetRaw([]byte(`{"a":1,"b":{"a":3},"a":2}`))
+	if err != nil || string(b) != "2" {
+		t.Errorf("GetRaw = %s, %v; want 2", b, err)
+	}
+}
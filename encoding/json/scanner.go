@@ -0,0 +1,550 @@
+package json
+
+// JSON的状态机, 一次读一个字节
+// 每读一个字节, step返回一个scanXxx的值, 告诉调用者这个字节是什么
+// 调用者(Unmarshal, Decoder)据此知道一个值在哪里开始, 在哪里结束
+// 这样的设计不用回溯, 也不用为每个token分配内存
+
+import (
+	"strconv"
+	"sync"
+)
+
+// DefaultMaxDepth 是默认允许的最大嵌套深度
+// 解码是递归的, 不加限制的话恶意的输入可以把栈撑爆
+const DefaultMaxDepth = 10000
+
+// checkValid 检查data是不是一个合法的JSON值, scan是临时用的scanner
+func checkValid(data []byte, scan *scanner) error {
+	scan.reset()
+	scan.bytes = 0
+	for _, c := range data {
+		scan.bytes++
+		if scan.step(scan, c) == scanError {
+			return scan.err
+		}
+	}
+	if scan.eof() == scanError {
+		return scan.err
+	}
+	return nil
+}
+
+// SyntaxError 描述JSON的语法错误, Offset是读到第几个字节时出的错
+type SyntaxError struct {
+	msg    string
+	Offset int64
+}
+
+func (e *SyntaxError) Error() string { return e.msg }
+
+type scanner struct {
+	// step 是处理下一个字节的函数, 状态机的状态其实就是这个函数
+	step func(*scanner, byte) int
+
+	// 顶层的值是否已经结束
+	endTop bool
+
+	// 嵌套的object与array, 栈顶是当前所在的位置
+	parseState []int
+
+	err error
+
+	// 已经读了多少字节, 用于SyntaxError.Offset
+	bytes int64
+
+	// 最大嵌套深度, 0表示DefaultMaxDepth
+	maxDepth int
+
+	// 值外面已经有的嵌套深度, Decoder在Token读到数组或对象里面之后Decode时设置
+	outerDepth int
+}
+
+// scannerPool 缓存scanner, Valid, Compact与Indent不用每次分配
+var scannerPool sync.Pool
+
+func newScanner(maxDepth int) *scanner {
+	scan, _ := scannerPool.Get().(*scanner)
+	if scan == nil {
+		scan = new(scanner)
+	}
+	scan.maxDepth = maxDepth
+	scan.outerDepth = 0
+	scan.bytes = 0
+	scan.reset()
+	return scan
+}
+
+func freeScanner(scan *scanner) {
+	// 太深的栈不要放回去, 免得一直占着内存
+	if cap(scan.parseState) > 1024 {
+		scan.parseState = nil
+	}
+	scannerPool.Put(scan)
+}
+
+// step返回的值
+// scanContinue与scanSkipSpace可以忽略, 其它的是调用者关心的事件
+const (
+	scanContinue     = iota // 普通的字节, 不用关心
+	scanBeginLiteral        // 字符串, 数字, true/false/null的开始
+	scanBeginObject         // {
+	scanObjectKey           // object里key后面的:
+	scanObjectValue         // object里value后面的,
+	scanEndObject           // }, 和scanEnd一样可能会延迟
+	scanBeginArray          // [
+	scanArrayValue          // array里元素后面的,
+	scanEndArray            // ]
+	scanSkipSpace           // 值之间的空白
+
+	// 停止
+	scanEnd   // 顶层的值已经结束, 这个字节不属于它
+	scanError // 出错了, 见scanner.err
+)
+
+// parseState里的值
+const (
+	parseObjectKey   = iota // 在读object的key
+	parseObjectValue        // 在读object的value
+	parseArrayValue         // 在读array的元素
+)
+
+func (s *scanner) reset() {
+	s.step = stateBeginValue
+	s.parseState = s.parseState[0:0]
+	s.err = nil
+	s.endTop = false
+}
+
+// eof 告诉scanner输入结束了, 返回scanEnd或者scanError
+func (s *scanner) eof() int {
+	if s.err != nil {
+		return scanError
+	}
+	if s.endTop {
+		return scanEnd
+	}
+	// 数字这样的字面量只有看到下一个字节才知道结束了, 给它一个空格
+	s.step(s, ' ')
+	if s.endTop {
+		return scanEnd
+	}
+	if s.err == nil {
+		s.err = &SyntaxError{"unexpected end of JSON input", s.bytes}
+	}
+	return scanError
+}
+
+// pushParseState 进入一个object或者array, 超过最大深度时出错
+// c是引起这次嵌套的字节, 成功时返回successState
+func (s *scanner) pushParseState(c byte, newParseState int, successState int) int {
+	s.parseState = append(s.parseState, newParseState)
+	if s.outerDepth+len(s.parseState) > s.depthLimit() {
+		return s.error(c, "exceeded max depth")
+	}
+	return successState
+}
+
+// depthLimit 返回实际的最大嵌套深度
+func (s *scanner) depthLimit() int {
+	if s.maxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return s.maxDepth
+}
+
+// popParseState 结束一个object或者array
+func (s *scanner) popParseState() {
+	n := len(s.parseState) - 1
+	s.parseState = s.parseState[0:n]
+	if n == 0 {
+		s.step = stateEndTop
+		s.endTop = true
+	} else {
+		s.step = stateEndValue
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// stateBeginValueOrEmpty 是[之后的状态, 可能是空数组
+func stateBeginValueOrEmpty(s *scanner, c byte) int {
+	if isSpace(c) {
+		return scanSkipSpace
+	}
+	if c == ']' {
+		return stateEndValue(s, c)
+	}
+	return stateBeginValue(s, c)
+}
+
+// stateBeginValue 是一个值开始之前的状态
+func stateBeginValue(s *scanner, c byte) int {
+	if isSpace(c) {
+		return scanSkipSpace
+	}
+	switch c {
+	case '{':
+		s.step = stateBeginStringOrEmpty
+		return s.pushParseState(c, parseObjectKey, scanBeginObject)
+	case '[':
+		s.step = stateBeginValueOrEmpty
+		return s.pushParseState(c, parseArrayValue, scanBeginArray)
+	case '"':
+		s.step = stateInString
+		return scanBeginLiteral
+	case '-':
+		s.step = stateNeg
+		return scanBeginLiteral
+	case '0': // 0后面不能再跟数字
+		s.step = state0
+		return scanBeginLiteral
+	case 't': // true
+		s.step = stateT
+		return scanBeginLiteral
+	case 'f': // false
+		s.step = stateF
+		return scanBeginLiteral
+	case 'n': // null
+		s.step = stateN
+		return scanBeginLiteral
+	}
+	if '1' <= c && c <= '9' {
+		s.step = state1
+		return scanBeginLiteral
+	}
+	return s.error(c, "looking for beginning of value")
+}
+
+// stateBeginStringOrEmpty 是{之后的状态, 可能是空对象
+func stateBeginStringOrEmpty(s *scanner, c byte) int {
+	if isSpace(c) {
+		return scanSkipSpace
+	}
+	if c == '}' {
+		n := len(s.parseState)
+		s.parseState[n-1] = parseObjectValue
+		return stateEndValue(s, c)
+	}
+	return stateBeginString(s, c)
+}
+
+// stateBeginString 是object的key开始之前的状态
+func stateBeginString(s *scanner, c byte) int {
+	if isSpace(c) {
+		return scanSkipSpace
+	}
+	if c == '"' {
+		s.step = stateInString
+		return scanBeginLiteral
+	}
+	return s.error(c, "looking for beginning of object key string")
+}
+
+// stateEndValue 是一个值刚结束的状态, 根据所在的位置决定后面可以跟什么
+func stateEndValue(s *scanner, c byte) int {
+	n := len(s.parseState)
+	if n == 0 {
+		// 顶层的值结束了
+		s.step = stateEndTop
+		s.endTop = true
+		return stateEndTop(s, c)
+	}
+	if isSpace(c) {
+		s.step = stateEndValue
+		return scanSkipSpace
+	}
+	ps := s.parseState[n-1]
+	switch ps {
+	case parseObjectKey:
+		if c == ':' {
+			s.parseState[n-1] = parseObjectValue
+			s.step = stateBeginValue
+			return scanObjectKey
+		}
+		return s.error(c, "after object key")
+	case parseObjectValue:
+		if c == ',' {
+			s.parseState[n-1] = parseObjectKey
+			s.step = stateBeginString
+			return scanObjectValue
+		}
+		if c == '}' {
+			s.popParseState()
+			return scanEndObject
+		}
+		return s.error(c, "after object key:value pair")
+	case parseArrayValue:
+		if c == ',' {
+			s.step = stateBeginValue
+			return scanArrayValue
+		}
+		if c == ']' {
+			s.popParseState()
+			return scanEndArray
+		}
+		return s.error(c, "after array element")
+	}
+	return s.error(c, "")
+}
+
+// stateEndTop 是顶层的值结束之后的状态, 只允许空白
+func stateEndTop(s *scanner, c byte) int {
+	if !isSpace(c) {
+		// 记录错误, 但是还要返回scanEnd, 调用者可能只读一个值
+		s.error(c, "after top-level value")
+	}
+	return scanEnd
+}
+
+// stateInString 在字符串里面
+func stateInString(s *scanner, c byte) int {
+	if c == '"' {
+		s.step = stateEndValue
+		return scanContinue
+	}
+	if c == '\\' {
+		s.step = stateInStringEsc
+		return scanContinue
+	}
+	if c < 0x20 {
+		return s.error(c, "in string literal")
+	}
+	return scanContinue
+}
+
+// stateInStringEsc 在字符串的\之后
+func stateInStringEsc(s *scanner, c byte) int {
+	switch c {
+	case 'b', 'f', 'n', 'r', 't', '\\', '/', '"':
+		s.step = stateInString
+		return scanContinue
+	case 'u':
+		s.step = stateInStringEscU
+		return scanContinue
+	}
+	return s.error(c, "in string escape code")
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// \u后面的四个十六进制数字
+func stateInStringEscU(s *scanner, c byte) int {
+	if isHex(c) {
+		s.step = stateInStringEscU1
+		return scanContinue
+	}
+	return s.error(c, "in \\u hexadecimal character escape")
+}
+
+func stateInStringEscU1(s *scanner, c byte) int {
+	if isHex(c) {
+		s.step = stateInStringEscU12
+		return scanContinue
+	}
+	return s.error(c, "in \\u hexadecimal character escape")
+}
+
+func stateInStringEscU12(s *scanner, c byte) int {
+	if isHex(c) {
+		s.step = stateInStringEscU123
+		return scanContinue
+	}
+	return s.error(c, "in \\u hexadecimal character escape")
+}
+
+func stateInStringEscU123(s *scanner, c byte) int {
+	if isHex(c) {
+		s.step = stateInString
+		return scanContinue
+	}
+	return s.error(c, "in \\u hexadecimal character escape")
+}
+
+// stateNeg 在负号之后
+func stateNeg(s *scanner, c byte) int {
+	if c == '0' {
+		s.step = state0
+		return scanContinue
+	}
+	if '1' <= c && c <= '9' {
+		s.step = state1
+		return scanContinue
+	}
+	return s.error(c, "in numeric literal")
+}
+
+// state1 在非0开头的整数部分里
+func state1(s *scanner, c byte) int {
+	if '0' <= c && c <= '9' {
+		s.step = state1
+		return scanContinue
+	}
+	return state0(s, c)
+}
+
+// state0 在整数部分之后, 可能有小数或者指数
+func state0(s *scanner, c byte) int {
+	if c == '.' {
+		s.step = stateDot
+		return scanContinue
+	}
+	if c == 'e' || c == 'E' {
+		s.step = stateE
+		return scanContinue
+	}
+	return stateEndValue(s, c)
+}
+
+// stateDot 在小数点之后, 至少要有一位数字
+func stateDot(s *scanner, c byte) int {
+	if '0' <= c && c <= '9' {
+		s.step = stateDot0
+		return scanContinue
+	}
+	return s.error(c, "after decimal point in numeric literal")
+}
+
+func stateDot0(s *scanner, c byte) int {
+	if '0' <= c && c <= '9' {
+		return scanContinue
+	}
+	if c == 'e' || c == 'E' {
+		s.step = stateE
+		return scanContinue
+	}
+	return stateEndValue(s, c)
+}
+
+// stateE 在e之后, 可以有正负号
+func stateE(s *scanner, c byte) int {
+	if c == '+' || c == '-' {
+		s.step = stateESign
+		return scanContinue
+	}
+	return stateESign(s, c)
+}
+
+func stateESign(s *scanner, c byte) int {
+	if '0' <= c && c <= '9' {
+		s.step = stateE0
+		return scanContinue
+	}
+	return s.error(c, "in exponent of numeric literal")
+}
+
+func stateE0(s *scanner, c byte) int {
+	if '0' <= c && c <= '9' {
+		return scanContinue
+	}
+	return stateEndValue(s, c)
+}
+
+// true, false, null 一个字节一个状态
+func stateT(s *scanner, c byte) int {
+	if c == 'r' {
+		s.step = stateTr
+		return scanContinue
+	}
+	return s.error(c, "in literal true (expecting 'r')")
+}
+
+func stateTr(s *scanner, c byte) int {
+	if c == 'u' {
+		s.step = stateTru
+		return scanContinue
+	}
+	return s.error(c, "in literal true (expecting 'u')")
+}
+
+func stateTru(s *scanner, c byte) int {
+	if c == 'e' {
+		s.step = stateEndValue
+		return scanContinue
+	}
+	return s.error(c, "in literal true (expecting 'e')")
+}
+
+func stateF(s *scanner, c byte) int {
+	if c == 'a' {
+		s.step = stateFa
+		return scanContinue
+	}
+	return s.error(c, "in literal false (expecting 'a')")
+}
+
+func stateFa(s *scanner, c byte) int {
+	if c == 'l' {
+		s.step = stateFal
+		return scanContinue
+	}
+	return s.error(c, "in literal false (expecting 'l')")
+}
+
+func stateFal(s *scanner, c byte) int {
+	if c == 's' {
+		s.step = stateFals
+		return scanContinue
+	}
+	return s.error(c, "in literal false (expecting 's')")
+}
+
+func stateFals(s *scanner, c byte) int {
+	if c == 'e' {
+		s.step = stateEndValue
+		return scanContinue
+	}
+	return s.error(c, "in literal false (expecting 'e')")
+}
+
+func stateN(s *scanner, c byte) int {
+	if c == 'u' {
+		s.step = stateNu
+		return scanContinue
+	}
+	return s.error(c, "in literal null (expecting 'u')")
+}
+
+func stateNu(s *scanner, c byte) int {
+	if c == 'l' {
+		s.step = stateNul
+		return scanContinue
+	}
+	return s.error(c, "in literal null (expecting 'l')")
+}
+
+func stateNul(s *scanner, c byte) int {
+	if c == 'l' {
+		s.step = stateEndValue
+		return scanContinue
+	}
+	return s.error(c, "in literal null (expecting 'l')")
+}
+
+// stateError 出错之后一直停在这个状态
+func stateError(s *scanner, c byte) int {
+	return scanError
+}
+
+// error 记录错误并进入stateError
+func (s *scanner) error(c byte, context string) int {
+	s.step = stateError
+	s.err = &SyntaxError{"invalid character " + quoteChar(c) + " " + context, s.bytes}
+	return scanError
+}
+
+// quoteChar 把c格式化为带单引号的字符
+func quoteChar(c byte) string {
+	if c == '\'' {
+		return `'\''`
+	}
+	if c == '"' {
+		return `'"'`
+	}
+	s := strconv.Quote(string(c))
+	return "'" + s[1:len(s)-1] + "'"
+}
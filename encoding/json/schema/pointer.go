@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// 标准库的encoding/json没有JSON Pointer, 这里只实现$ref与报告位置需要的部分
+
+// pointer 是RFC 6901的JSON Pointer去掉转义后的各个引用, 空的pointer指向整个文档
+type pointer []string
+
+var (
+	pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+	// Replacer从左往右匹配, "~01"会先匹配到"~0", 得到"~1"而不是"/"
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// parsePointer 解析s, s必须为空或者以/开头, ~后面只能是0或1
+func parsePointer(s string) (pointer, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '/' {
+		return nil, errors.New("invalid JSON pointer " + strconv.Quote(s) + ": must be empty or start with '/'")
+	}
+	p := pointer(strings.Split(s[1:], "/"))
+	for i, tok := range p {
+		for j := 0; j < len(tok); j++ {
+			if tok[j] == '~' && (j+1 == len(tok) || tok[j+1] != '0' && tok[j+1] != '1') {
+				return nil, errors.New("invalid JSON pointer " + strconv.Quote(s) + ": bad escape in " + strconv.Quote(tok))
+			}
+		}
+		p[i] = pointerUnescaper.Replace(tok)
+	}
+	return p, nil
+}
+
+func (p pointer) String() string {
+	var b strings.Builder
+	for _, tok := range p {
+		b.WriteByte('/')
+		pointerEscaper.WriteString(&b, tok)
+	}
+	return b.String()
+}
+
+// child 返回在p后面加上toks的新pointer, 不会修改p
+func (p pointer) child(toks ...string) pointer {
+	c := make(pointer, 0, len(p)+len(toks))
+	return append(append(c, p...), toks...)
+}
+
+// get 在解码后的文档里找p指向的值
+func (p pointer) get(doc interface{}) (interface{}, error) {
+	v := doc
+	for i, tok := range p {
+		found := false
+		switch x := v.(type) {
+		case map[string]interface{}:
+			v, found = x[tok]
+		case []interface{}:
+			// 不允许前导0与符号
+			if n, err := strconv.Atoi(tok); err == nil && n >= 0 && n < len(x) && strconv.Itoa(n) == tok {
+				v, found = x[n], true
+			}
+		}
+		if !found {
+			return nil, errors.New(strconv.Quote(p[:i+1].String()) + " not found")
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,472 @@
+// Package schema 实现JSON Schema(draft 2020-12)的一个子集, 用来校验解码后的JSON值
+//
+// 支持的关键字:
+//
+//	type, enum, const
+//	properties, required, additionalProperties, minProperties, maxProperties
+//	items, minItems, maxItems
+//	minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//	minLength, maxLength, pattern
+//	allOf, anyOf, oneOf, not
+//	$ref(只支持同一个文档内的"#"与"#/..."), $defs
+//
+// 不认识的关键字被忽略, 和规范要求的一样
+// pattern用的是regexp包的RE2语法, 而不是ECMA 262, 常见的写法是兼容的
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema 是编译好的JSON Schema, 可以被多个goroutine同时使用
+type Schema struct {
+	root *node
+}
+
+// node 是schema文档里的一个(子)schema
+type node struct {
+	loc string // 在schema文档里的位置, 用在错误信息里
+
+	always *bool // true或者false这种布尔schema
+
+	types []string
+
+	enum     []interface{} // enum与constVal都经过了canonical
+	hasEnum  bool
+	constVal interface{}
+	hasConst bool
+
+	properties    map[string]*node
+	required      []string
+	additional    *node
+	minProperties int
+	maxProperties int // -1表示没有限制
+
+	items    *node
+	minItems int
+	maxItems int
+
+	minimum, maximum                   *big.Rat
+	exclusiveMinimum, exclusiveMaximum *big.Rat
+
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+
+	allOf, anyOf, oneOf []*node
+	not                 *node
+
+	ref     string
+	refNode *node
+}
+
+// CompileError 是schema文档本身有问题时返回的错误
+type CompileError struct {
+	Location string // 出错的关键字在schema文档里的JSON Pointer
+	Msg      string
+}
+
+func (e *CompileError) Error() string {
+	return "schema: " + strconv.Quote(e.Location) + ": " + e.Msg
+}
+
+// Compile 编译一个JSON格式的schema文档
+func Compile(data []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return CompileValue(doc)
+}
+
+// MustCompile 和Compile一样, 出错时panic, 用于初始化全局变量
+func MustCompile(data []byte) *Schema {
+	s, err := Compile(data)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// CompileValue 编译一个已经解码成interface{}的schema文档
+func CompileValue(doc interface{}) (*Schema, error) {
+	c := &compiler{doc: doc, nodes: make(map[string]*node)}
+	root, err := c.compile(doc, nil)
+	if err != nil {
+		return nil, err
+	}
+	// $ref指向的schema可能还没有编译过, 编译它们时又会产生新的$ref
+	for len(c.refs) > 0 {
+		n := c.refs[0]
+		c.refs = c.refs[1:]
+		if n.refNode, err = c.resolve(n); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.checkCycles(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+type compiler struct {
+	doc   interface{}
+	nodes map[string]*node // 按位置记录已经编译过的schema, $ref与递归都靠它
+	refs  []*node          // 还没有解析的$ref
+}
+
+func (c *compiler) compile(v interface{}, path pointer) (*node, error) {
+	loc := path.String()
+	if n, ok := c.nodes[loc]; ok {
+		return n, nil
+	}
+	n := &node{loc: loc, maxProperties: -1, maxItems: -1, maxLength: -1}
+	c.nodes[loc] = n
+
+	switch x := v.(type) {
+	case bool:
+		n.always = &x
+		return n, nil
+	case map[string]interface{}:
+		return n, c.compileObject(n, x, path)
+	}
+	return nil, &CompileError{loc, "schema must be an object or a boolean"}
+}
+
+func (c *compiler) compileObject(n *node, m map[string]interface{}, path pointer) error {
+	sub := path.child
+	errAt := func(keyword, msg string) error {
+		return &CompileError{sub(keyword).String(), msg}
+	}
+
+	if v, ok := m["type"]; ok {
+		switch t := v.(type) {
+		case string:
+			n.types = []string{t}
+		case []interface{}:
+			for _, elem := range t {
+				s, ok := elem.(string)
+				if !ok {
+					return errAt("type", "must be a string or an array of strings")
+				}
+				n.types = append(n.types, s)
+			}
+		default:
+			return errAt("type", "must be a string or an array of strings")
+		}
+		for _, t := range n.types {
+			switch t {
+			case "null", "boolean", "object", "array", "number", "string", "integer":
+			default:
+				return errAt("type", "unknown type "+strconv.Quote(t))
+			}
+		}
+	}
+
+	if v, ok := m["enum"]; ok {
+		list, ok := v.([]interface{})
+		if !ok {
+			return errAt("enum", "must be an array")
+		}
+		n.enum = make([]interface{}, len(list))
+		for i, e := range list {
+			n.enum[i] = canonical(e)
+		}
+		n.hasEnum = true
+	}
+	if v, ok := m["const"]; ok {
+		n.constVal, n.hasConst = canonical(v), true
+	}
+
+	if v, ok := m["properties"]; ok {
+		props, ok := v.(map[string]interface{})
+		if !ok {
+			return errAt("properties", "must be an object")
+		}
+		n.properties = make(map[string]*node, len(props))
+		for name, ps := range props {
+			pn, err := c.compile(ps, sub("properties", name))
+			if err != nil {
+				return err
+			}
+			n.properties[name] = pn
+		}
+	}
+	if v, ok := m["required"]; ok {
+		list, ok := v.([]interface{})
+		if !ok {
+			return errAt("required", "must be an array of strings")
+		}
+		for _, elem := range list {
+			s, ok := elem.(string)
+			if !ok {
+				return errAt("required", "must be an array of strings")
+			}
+			n.required = append(n.required, s)
+		}
+	}
+
+	var err error
+	compileSub := func(keyword string) (*node, error) {
+		v, ok := m[keyword]
+		if !ok {
+			return nil, nil
+		}
+		return c.compile(v, sub(keyword))
+	}
+	if n.additional, err = compileSub("additionalProperties"); err != nil {
+		return err
+	}
+	if n.items, err = compileSub("items"); err != nil {
+		return err
+	}
+	if n.not, err = compileSub("not"); err != nil {
+		return err
+	}
+
+	if defs, ok := m["$defs"].(map[string]interface{}); ok {
+		// $defs里的schema只有被$ref引用时才有用, 这里先编译一遍, 尽早发现错误
+		for name, d := range defs {
+			if _, err := c.compile(d, sub("$defs", name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	compileList := func(keyword string) ([]*node, error) {
+		v, ok := m[keyword]
+		if !ok {
+			return nil, nil
+		}
+		list, ok := v.([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, errAt(keyword, "must be a non-empty array")
+		}
+		nodes := make([]*node, len(list))
+		for i, elem := range list {
+			if nodes[i], err = c.compile(elem, sub(keyword, strconv.Itoa(i))); err != nil {
+				return nil, err
+			}
+		}
+		return nodes, nil
+	}
+	if n.allOf, err = compileList("allOf"); err != nil {
+		return err
+	}
+	if n.anyOf, err = compileList("anyOf"); err != nil {
+		return err
+	}
+	if n.oneOf, err = compileList("oneOf"); err != nil {
+		return err
+	}
+
+	numbers := []struct {
+		keyword string
+		dst     **big.Rat
+	}{
+		{"minimum", &n.minimum},
+		{"maximum", &n.maximum},
+		{"exclusiveMinimum", &n.exclusiveMinimum},
+		{"exclusiveMaximum", &n.exclusiveMaximum},
+	}
+	for _, kw := range numbers {
+		if v, ok := m[kw.keyword]; ok {
+			r, ok := number(v)
+			if !ok {
+				return errAt(kw.keyword, "must be a number")
+			}
+			*kw.dst = r
+		}
+	}
+
+	counts := []struct {
+		keyword string
+		dst     *int
+	}{
+		{"minLength", &n.minLength},
+		{"maxLength", &n.maxLength},
+		{"minItems", &n.minItems},
+		{"maxItems", &n.maxItems},
+		{"minProperties", &n.minProperties},
+		{"maxProperties", &n.maxProperties},
+	}
+	for _, kw := range counts {
+		if v, ok := m[kw.keyword]; ok {
+			r, ok := number(v)
+			if !ok || !r.IsInt() || r.Sign() < 0 || r.Num().BitLen() > 31 {
+				return errAt(kw.keyword, "must be a non-negative integer")
+			}
+			*kw.dst = int(r.Num().Int64())
+		}
+	}
+
+	if v, ok := m["pattern"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return errAt("pattern", "must be a string")
+		}
+		if n.pattern, err = regexp.Compile(s); err != nil {
+			return errAt("pattern", err.Error())
+		}
+	}
+
+	if v, ok := m["$ref"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return errAt("$ref", "must be a string")
+		}
+		if !strings.HasPrefix(s, "#") {
+			return errAt("$ref", "only references within the same document are supported")
+		}
+		n.ref = s
+		c.refs = append(c.refs, n)
+	}
+	return nil
+}
+
+// resolve 找到n.ref指向的schema, 需要时编译它
+func (c *compiler) resolve(n *node) (*node, error) {
+	ptr, err := parsePointer(n.ref[1:])
+	if err != nil {
+		return nil, &CompileError{n.loc + "/$ref", err.Error()}
+	}
+	if target, ok := c.nodes[ptr.String()]; ok {
+		return target, nil
+	}
+	v, err := ptr.get(c.doc)
+	if err != nil {
+		return nil, &CompileError{n.loc + "/$ref", err.Error()}
+	}
+	return c.compile(v, ptr)
+}
+
+// checkCycles 找出在同一个值上无限递归的$ref, 例如两个只有$ref的schema互相引用
+// properties与items会进入下一层的值, 不会无限递归, 只需要看$ref, allOf, anyOf, oneOf与not
+func (c *compiler) checkCycles() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*node]int)
+	var stack []*node
+	var via []string // via[i]是到达stack[i]经过的关键字位置
+	var visit func(n *node, edge string) error
+	visit = func(n *node, edge string) error {
+		switch state[n] {
+		case done:
+			return nil
+		case visiting:
+			// 只有$ref能连回去, 环上一定有$ref, 报告最先经过的那个
+			i := len(stack) - 1
+			for stack[i] != n {
+				i--
+			}
+			for _, loc := range append(via[i+1:], edge) {
+				if strings.HasSuffix(loc, "/$ref") {
+					return &CompileError{loc, "$ref cycle"}
+				}
+			}
+			return &CompileError{edge, "$ref cycle"}
+		}
+		state[n] = visiting
+		stack, via = append(stack, n), append(via, edge)
+		if n.refNode != nil {
+			if err := visit(n.refNode, n.loc+"/$ref"); err != nil {
+				return err
+			}
+		}
+		subs := append(append(append([]*node(nil), n.allOf...), n.anyOf...), n.oneOf...)
+		if n.not != nil {
+			subs = append(subs, n.not)
+		}
+		for _, sub := range subs {
+			if err := visit(sub, sub.loc); err != nil {
+				return err
+			}
+		}
+		stack, via = stack[:len(stack)-1], via[:len(via)-1]
+		state[n] = done
+		return nil
+	}
+
+	// 按位置排序, 报告的位置不依赖map的顺序
+	locs := make([]string, 0, len(c.nodes))
+	for loc := range c.nodes {
+		locs = append(locs, loc)
+	}
+	sort.Strings(locs)
+	for _, loc := range locs {
+		if err := visit(c.nodes[loc], loc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Violation 是一条校验失败的记录
+type Violation struct {
+	InstanceLocation string // 不合法的值在被校验文档里的JSON Pointer
+	SchemaLocation   string // 失败的关键字在schema文档里的JSON Pointer
+	Message          string
+}
+
+func (v Violation) String() string {
+	loc := v.InstanceLocation
+	if loc == "" {
+		loc = "(root)"
+	}
+	return loc + ": " + v.Message
+}
+
+// ValidationError 包含一次校验发现的所有问题
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Violations) == 1 {
+		return "schema: " + e.Violations[0].String()
+	}
+	s := "schema: " + strconv.Itoa(len(e.Violations)) + " violations:"
+	for _, v := range e.Violations {
+		s += "\n\t" + v.String()
+	}
+	return s
+}
+
+// Validate 校验一个解码后的JSON值(Unmarshal到interface{}的结果)
+// 合法时返回nil, 否则返回*ValidationError, 里面是所有的问题, 按位置排序
+func (s *Schema) Validate(v interface{}) error {
+	var vs []Violation
+	s.root.validate(v, nil, &vs)
+	if len(vs) == 0 {
+		return nil
+	}
+	sort.Stable(byLocation(vs))
+	return &ValidationError{vs}
+}
+
+// ValidateBytes 解码data再校验, 数字按Number解码, 不会损失精度
+func (s *Schema) ValidateBytes(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	return s.Validate(v)
+}
+
+type byLocation []Violation
+
+func (x byLocation) Len() int           { return len(x) }
+func (x byLocation) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+func (x byLocation) Less(i, j int) bool { return x[i].InstanceLocation < x[j].InstanceLocation }
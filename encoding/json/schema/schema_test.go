@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata里的每个文件是一组用例, 格式和JSON-Schema-Test-Suite类似,
+// 另外用locations列出每个问题所在的位置(排过序)
+type fixtureGroup struct {
+	Description string
+	Schema      interface{}
+	Tests       []struct {
+		Description string
+		Data        interface{}
+		Valid       bool
+		Locations   []string
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var groups []fixtureGroup
+		if err := json.Unmarshal(data, &groups); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		for _, g := range groups {
+			s, err := CompileValue(g.Schema)
+			if err != nil {
+				t.Errorf("%s: %s: Compile: %v", file, g.Description, err)
+				continue
+			}
+			for _, tt := range g.Tests {
+				name := file + ": " + g.Description + ": " + tt.Description
+				err := s.Validate(tt.Data)
+				if tt.Valid {
+					if err != nil {
+						t.Errorf("%s: unexpected error: %v", name, err)
+					}
+					continue
+				}
+				ve, ok := err.(*ValidationError)
+				if !ok {
+					t.Errorf("%s: got %v, want *ValidationError", name, err)
+					continue
+				}
+				var locs []string
+				for _, v := range ve.Violations {
+					locs = append(locs, v.InstanceLocation)
+				}
+				if tt.Locations != nil && !reflect.DeepEqual(locs, tt.Locations) {
+					t.Errorf("%s: violations at %q, want %q\n%v", name, locs, tt.Locations, err)
+				}
+			}
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		schema, loc string
+	}{
+		{`1`, ""},
+		{`{"type": "float"}`, "/type"},
+		{`{"properties": {"a": {"minLength": -1}}}`, "/properties/a/minLength"},
+		{`{"items": {"pattern": "("}}`, "/items/pattern"},
+		{`{"anyOf": []}`, "/anyOf"},
+		{`{"$ref": "#/$defs/missing"}`, "/$ref"},
+		{`{"$ref": "other.json"}`, "/$ref"},
+		// 在同一个值上无限递归的$ref
+		{`{"$ref": "#"}`, "/$ref"},
+		{`{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, "/$defs/a/$ref"},
+		{`{"allOf": [{"$ref": "#"}]}`, "/allOf/0/$ref"},
+		{`{"not": {"anyOf": [{"type": "string"}, {"$ref": "#/not"}]}}`, "/not/anyOf/1/$ref"},
+	}
+	for _, tt := range tests {
+		_, err := Compile([]byte(tt.schema))
+		ce, ok := err.(*CompileError)
+		if !ok || ce.Location != tt.loc {
+			t.Errorf("Compile(%s) = %v, want CompileError at %q", tt.schema, err, tt.loc)
+		}
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	s := MustCompile([]byte(`{"properties": {"a": {"type": "string"}}, "required": ["b"]}`))
+	var v interface{}
+	json.Unmarshal([]byte(`{"a": 1}`), &v)
+	err := s.Validate(v)
+	if err == nil {
+		t.Fatal("Validate succeeded")
+	}
+	msg := err.Error()
+	for _, want := range []string{`(root): missing required property "b"`, "/a: expected string, got integer"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not contain %q", msg, want)
+		}
+	}
+	ve := err.(*ValidationError)
+	if loc := ve.Violations[1].SchemaLocation; loc != "/properties/a/type" {
+		t.Errorf("SchemaLocation = %q", loc)
+	}
+}
+
+// 数字按数值精确比较, 不经过float64
+func TestNumbers(t *testing.T) {
+	s := MustCompile([]byte(`{"enum": [1, 9007199254740993, {"a": [0.5]}], "maximum": 9007199254740993}`))
+	for _, data := range []string{`1.0`, `1e0`, `9007199254740993`, `{"a": [5e-1]}`} {
+		if err := s.ValidateBytes([]byte(data)); err != nil {
+			t.Errorf("ValidateBytes(%s): %v", data, err)
+		}
+	}
+	for _, data := range []string{`"1"`, `9007199254740992`, `9007199254740994`, `{"a": [0.50001]}`} {
+		if err := s.ValidateBytes([]byte(data)); err == nil {
+			t.Errorf("ValidateBytes(%s) succeeded", data)
+		}
+	}
+
+	s = MustCompile([]byte(`{"type": "integer"}`))
+	if err := s.ValidateBytes([]byte(`12345678901234567890.5`)); err == nil {
+		t.Error("12345678901234567890.5 validated as an integer")
+	}
+	if err := s.Validate(float64(3)); err != nil {
+		t.Errorf("Validate(float64(3)): %v", err)
+	}
+}
+
+func TestRefPointerEscapes(t *testing.T) {
+	s := MustCompile([]byte(`{
+		"$defs": {"a/b": {"type": "string"}, "c~d": {"type": "integer"}},
+		"x-list": [{"minimum": 10}],
+		"properties": {
+			"x": {"$ref": "#/$defs/a~1b"},
+			"y": {"$ref": "#/$defs/c~0d"},
+			"z": {"$ref": "#/x-list/0"}
+		}
+	}`))
+	var v interface{}
+	json.Unmarshal([]byte(`{"x": 1, "y": "s", "z": 3}`), &v)
+	ve, ok := s.Validate(v).(*ValidationError)
+	if !ok || len(ve.Violations) != 3 {
+		t.Fatalf("Validate = %v; want 3 violations", s.Validate(v))
+	}
+	for _, tt := range []string{`{"$ref": "#/x-list/01"}`, `{"$ref": "#/x-list/-1"}`, `{"$ref": "#/bad~2"}`, `{"$ref": "#x-list"}`} {
+		if _, err := Compile([]byte(`{"x-list": [true], "properties": {"p": ` + tt + `}}`)); err == nil {
+			t.Errorf("Compile with %s succeeded", tt)
+		}
+	}
+}
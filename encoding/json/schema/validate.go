@@ -0,0 +1,258 @@
+package schema
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validate 用n校验v, 把发现的问题追加到vs里
+// path是v在被校验文档里的位置
+func (n *node) validate(v interface{}, path pointer, vs *[]Violation) {
+	report := func(keyword, msg string) {
+		*vs = append(*vs, Violation{
+			InstanceLocation: path.String(),
+			SchemaLocation:   n.loc + "/" + keyword,
+			Message:          msg,
+		})
+	}
+
+	if n.always != nil {
+		if !*n.always {
+			*vs = append(*vs, Violation{path.String(), n.loc, "not allowed by false schema"})
+		}
+		return
+	}
+
+	if n.refNode != nil {
+		n.refNode.validate(v, path, vs)
+	}
+
+	if len(n.types) > 0 && !n.matchType(v) {
+		report("type", "expected "+strings.Join(n.types, " or ")+", got "+typeName(v))
+		// 类型都不对, 其它关键字的错误没有意义了
+		return
+	}
+
+	if n.hasEnum || n.hasConst {
+		cv := canonical(v)
+		if n.hasEnum {
+			found := false
+			for _, e := range n.enum {
+				if reflect.DeepEqual(cv, e) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				report("enum", "value is not one of the allowed values")
+			}
+		}
+		if n.hasConst && !reflect.DeepEqual(cv, n.constVal) {
+			report("const", "value does not equal the constant")
+		}
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		n.validateObject(x, path, vs, report)
+	case []interface{}:
+		if len(x) < n.minItems {
+			report("minItems", "array has "+strconv.Itoa(len(x))+" items, want at least "+strconv.Itoa(n.minItems))
+		}
+		if n.maxItems >= 0 && len(x) > n.maxItems {
+			report("maxItems", "array has "+strconv.Itoa(len(x))+" items, want at most "+strconv.Itoa(n.maxItems))
+		}
+		if n.items != nil {
+			for i, elem := range x {
+				n.items.validate(elem, path.child(strconv.Itoa(i)), vs)
+			}
+		}
+	case string:
+		// 长度按Unicode码点计算
+		l := utf8.RuneCountInString(x)
+		if l < n.minLength {
+			report("minLength", "string is shorter than "+strconv.Itoa(n.minLength))
+		}
+		if n.maxLength >= 0 && l > n.maxLength {
+			report("maxLength", "string is longer than "+strconv.Itoa(n.maxLength))
+		}
+		if n.pattern != nil && !n.pattern.MatchString(x) {
+			report("pattern", "string does not match pattern "+strconv.Quote(n.pattern.String()))
+		}
+	case float64, json.Number:
+		r, ok := number(x)
+		if !ok {
+			break
+		}
+		if n.minimum != nil && r.Cmp(n.minimum) < 0 {
+			report("minimum", formatNumber(r)+" is less than "+formatNumber(n.minimum))
+		}
+		if n.maximum != nil && r.Cmp(n.maximum) > 0 {
+			report("maximum", formatNumber(r)+" is greater than "+formatNumber(n.maximum))
+		}
+		if n.exclusiveMinimum != nil && r.Cmp(n.exclusiveMinimum) <= 0 {
+			report("exclusiveMinimum", formatNumber(r)+" is not greater than "+formatNumber(n.exclusiveMinimum))
+		}
+		if n.exclusiveMaximum != nil && r.Cmp(n.exclusiveMaximum) >= 0 {
+			report("exclusiveMaximum", formatNumber(r)+" is not less than "+formatNumber(n.exclusiveMaximum))
+		}
+	}
+
+	for _, sub := range n.allOf {
+		sub.validate(v, path, vs)
+	}
+	if len(n.anyOf) > 0 {
+		ok := false
+		for _, sub := range n.anyOf {
+			if sub.valid(v, path) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			report("anyOf", "value does not match any schema in anyOf")
+		}
+	}
+	if len(n.oneOf) > 0 {
+		var matched []string
+		for i, sub := range n.oneOf {
+			if sub.valid(v, path) {
+				matched = append(matched, strconv.Itoa(i))
+			}
+		}
+		switch len(matched) {
+		case 1:
+		case 0:
+			report("oneOf", "value does not match any schema in oneOf")
+		default:
+			report("oneOf", "value matches more than one schema in oneOf (indexes "+strings.Join(matched, ", ")+")")
+		}
+	}
+	if n.not != nil && n.not.valid(v, path) {
+		report("not", "value must not match the schema in not")
+	}
+}
+
+func (n *node) validateObject(m map[string]interface{}, path pointer, vs *[]Violation, report func(keyword, msg string)) {
+	for _, name := range n.required {
+		if _, ok := m[name]; !ok {
+			report("required", "missing required property "+strconv.Quote(name))
+		}
+	}
+	if len(m) < n.minProperties {
+		report("minProperties", "object has "+strconv.Itoa(len(m))+" properties, want at least "+strconv.Itoa(n.minProperties))
+	}
+	if n.maxProperties >= 0 && len(m) > n.maxProperties {
+		report("maxProperties", "object has "+strconv.Itoa(len(m))+" properties, want at most "+strconv.Itoa(n.maxProperties))
+	}
+
+	// 按名字的顺序校验, 让结果是确定的
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if pn, ok := n.properties[name]; ok {
+			pn.validate(m[name], path.child(name), vs)
+		} else if n.additional != nil {
+			n.additional.validate(m[name], path.child(name), vs)
+		}
+	}
+}
+
+// valid 只判断v是否合法, 不关心具体的问题, 用于anyOf, oneOf与not
+func (n *node) valid(v interface{}, path pointer) bool {
+	var vs []Violation
+	n.validate(v, path, &vs)
+	return len(vs) == 0
+}
+
+func (n *node) matchType(v interface{}) bool {
+	name := typeName(v)
+	for _, t := range n.types {
+		if t == name || t == "number" && name == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName 返回v的JSON Schema类型, 整数值的数字算作integer
+func typeName(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float64, json.Number:
+		if r, ok := number(x); ok && r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// number 把解码后的数字精确地转为big.Rat, 不是数字(或者是Inf)时返回false
+// json.Number直接按文本转换, 超过float64精度的整数也不会被舍入
+func number(v interface{}) (*big.Rat, bool) {
+	switch x := v.(type) {
+	case float64:
+		r := new(big.Rat)
+		if r.SetFloat64(x) == nil {
+			return nil, false
+		}
+		return r, true
+	case json.Number:
+		return new(big.Rat).SetString(string(x))
+	}
+	return nil, false
+}
+
+// formatNumber 用在错误信息里, 整数原样输出, 其它的按float64的最短形式输出
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	f, _ := r.Float64()
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// canonicalNumber 是canonical后的数字, 与字符串区分开
+type canonicalNumber string
+
+// canonical 把v里的数字换成精确的分数形式, 之后就可以用reflect.DeepEqual比较,
+// 数字按数值比较, 所以1, 1.0与1e0相等
+func canonical(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			m[k] = canonical(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, e := range x {
+			a[i] = canonical(e)
+		}
+		return a
+	case float64, json.Number:
+		if r, ok := number(x); ok {
+			return canonicalNumber(r.RatString())
+		}
+	}
+	return v
+}
@@ -0,0 +1,493 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Decoder 从一个输入流里一个一个地读JSON值并解码
+type Decoder struct {
+	r       io.Reader
+	buf     []byte
+	d       decodeState
+	scanp   int   // buf[scanp:]是还没有用过的数据
+	scanned int64 // buf之前已经处理过的字节数
+	scan    scanner
+	err     error
+
+	tokenState int
+	tokenStack []int
+}
+
+// NewDecoder 返回一个从r读取的Decoder
+// Decoder有自己的缓冲区, 可能会从r多读出一些数据
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// UseNumber 让解码到interface{}的数字成为Number而不是float64
+func (dec *Decoder) UseNumber() { dec.d.useNumber = true }
+
+// DisallowUnknownFields 让解码到结构体时, 遇到没有对应字段的key返回错误
+func (dec *Decoder) DisallowUnknownFields() { dec.d.disallowUnknownFields = true }
+
+// SetMaxDepth 设置允许的最大嵌套深度, n<=0表示DefaultMaxDepth
+// 超过的输入返回*SyntaxError, Token读到的数组与对象同样受限制
+func (dec *Decoder) SetMaxDepth(n int) { dec.scan.maxDepth = n }
+
+// Decode 读取下一个JSON值并解码到v, 规则和Unmarshal一样
+func (dec *Decoder) Decode(v interface{}) error {
+	if dec.err != nil {
+		return dec.err
+	}
+
+	if err := dec.tokenPrepareForDecode(); err != nil {
+		return err
+	}
+
+	if !dec.tokenValueAllowed() {
+		return &SyntaxError{msg: "not at beginning of value", Offset: dec.InputOffset()}
+	}
+
+	// 先把一个完整的值读到缓冲区里
+	// 用Token进入的数组与对象也算在嵌套深度里
+	dec.scan.outerDepth = len(dec.tokenStack)
+	n, err := dec.readValue()
+	if err != nil {
+		return err
+	}
+	start := dec.InputOffset()
+	dec.d.init(dec.buf[dec.scanp : dec.scanp+n])
+	dec.scanp += n
+
+	// 这里不会有语法错误, readValue已经检查过了
+	err = dec.d.unmarshal(v)
+	if e, ok := err.(*DuplicateKeyError); ok {
+		// checkStrict只知道在这个值里的位置
+		e.Offset += start
+	}
+
+	dec.tokenValueEnd()
+
+	return err
+}
+
+// Buffered 返回Decoder缓冲区里还没有用到的数据
+func (dec *Decoder) Buffered() io.Reader {
+	return bytes.NewReader(dec.buf[dec.scanp:])
+}
+
+// readValue 把一个完整的JSON值读进dec.buf[dec.scanp:], 返回它的长度
+func (dec *Decoder) readValue() (int, error) {
+	dec.scan.reset()
+
+	scanp := dec.scanp
+	var err error
+Input:
+	// scanp为缓冲区里下一个要扫描的位置
+	for scanp >= 0 {
+		for ; scanp < len(dec.buf); scanp++ {
+			c := dec.buf[scanp]
+			dec.scan.bytes++
+			switch dec.scan.step(&dec.scan, c) {
+			case scanEnd:
+				// scanEnd延迟了一个字节, 下一次Decode还会再数这个字节
+				dec.scan.bytes--
+				break Input
+			case scanEndObject, scanEndArray:
+				// }与]可以直接确定顶层的值结束了, 不用等下一个字节
+				if stateEndValue(&dec.scan, ' ') == scanEnd {
+					scanp++
+					break Input
+				}
+			case scanError:
+				dec.err = dec.scan.err
+				return 0, dec.scan.err
+			}
+		}
+
+		// 上一次读的错误等缓冲区里的数据处理完了再返回
+		if err != nil {
+			if err == io.EOF {
+				if dec.scan.step(&dec.scan, ' ') == scanEnd {
+					break Input
+				}
+				if nonSpace(dec.buf) {
+					err = io.ErrUnexpectedEOF
+				}
+			}
+			dec.err = err
+			return 0, err
+		}
+
+		n := scanp - dec.scanp
+		err = dec.refill()
+		scanp = dec.scanp + n
+	}
+	return scanp - dec.scanp, nil
+}
+
+func (dec *Decoder) refill() error {
+	// 把用过的数据挪走
+	if dec.scanp > 0 {
+		dec.scanned += int64(dec.scanp)
+		n := copy(dec.buf, dec.buf[dec.scanp:])
+		dec.buf = dec.buf[:n]
+		dec.scanp = 0
+	}
+
+	// 剩余空间不够时扩容
+	const minRead = 512
+	if cap(dec.buf)-len(dec.buf) < minRead {
+		newBuf := make([]byte, len(dec.buf), 2*cap(dec.buf)+minRead)
+		copy(newBuf, dec.buf)
+		dec.buf = newBuf
+	}
+
+	n, err := dec.r.Read(dec.buf[len(dec.buf):cap(dec.buf)])
+	dec.buf = dec.buf[0 : len(dec.buf)+n]
+
+	return err
+}
+
+func nonSpace(b []byte) bool {
+	for _, c := range b {
+		if !isSpace(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// InputOffset 返回当前在输入流里的位置
+func (dec *Decoder) InputOffset() int64 {
+	return dec.scanned + int64(dec.scanp)
+}
+
+// RawMessage 是一段原始的JSON值
+// 解码时原样保存, 可以等知道了具体类型以后再解码; 编码时原样输出(去掉空白)
+type RawMessage []byte
+
+// MarshalJSON 返回m本身, nil的RawMessage编码为null
+func (m RawMessage) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return m, nil
+}
+
+// UnmarshalJSON 把data复制一份保存到*m
+// 一定要复制, data可能是Decoder或者LinesReader的缓冲区
+func (m *RawMessage) UnmarshalJSON(data []byte) error {
+	if m == nil {
+		return errors.New("json.RawMessage: UnmarshalJSON on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+var _ Marshaler = (*RawMessage)(nil)
+var _ Unmarshaler = (*RawMessage)(nil)
+
+// Token 是下面几种类型之一:
+//
+//	Delim, 表示[ ] { }
+//	bool
+//	float64(UseNumber时为Number)
+//	string
+//	nil, 表示null
+type Token interface{}
+
+// Delim 是JSON的分隔符[ ] { }
+type Delim rune
+
+func (d Delim) String() string {
+	return string(d)
+}
+
+// tokenState记录Token在流里的位置, 用于检查语法
+const (
+	tokenTopValue = iota
+	tokenArrayStart
+	tokenArrayValue
+	tokenArrayComma
+	tokenObjectStart
+	tokenObjectKey
+	tokenObjectColon
+	tokenObjectValue
+	tokenObjectComma
+)
+
+// tokenPrepareForDecode 在Token与Decode混用时, 先把前面的,或者:读掉
+func (dec *Decoder) tokenPrepareForDecode() error {
+	switch dec.tokenState {
+	case tokenArrayComma:
+		c, err := dec.peek()
+		if err != nil {
+			return err
+		}
+		if c != ',' {
+			return &SyntaxError{"expected comma after array element", dec.InputOffset()}
+		}
+		dec.scanp++
+		dec.tokenState = tokenArrayValue
+	case tokenObjectColon:
+		c, err := dec.peek()
+		if err != nil {
+			return err
+		}
+		if c != ':' {
+			return &SyntaxError{"expected colon after object key", dec.InputOffset()}
+		}
+		dec.scanp++
+		dec.tokenState = tokenObjectValue
+	}
+	return nil
+}
+
+func (dec *Decoder) tokenValueAllowed() bool {
+	switch dec.tokenState {
+	case tokenTopValue, tokenArrayStart, tokenArrayValue, tokenObjectValue:
+		return true
+	}
+	return false
+}
+
+func (dec *Decoder) tokenValueEnd() {
+	switch dec.tokenState {
+	case tokenArrayStart, tokenArrayValue:
+		dec.tokenState = tokenArrayComma
+	case tokenObjectValue:
+		dec.tokenState = tokenObjectComma
+	}
+}
+
+// Token 返回输入流里的下一个token, 输入结束时返回nil, io.EOF
+//
+// Token保证返回的分隔符是配对的, 遇到不合法的输入会返回错误
+// 逗号与冒号不会作为token返回
+func (dec *Decoder) Token() (Token, error) {
+	for {
+		c, err := dec.peek()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case '[':
+			if !dec.tokenValueAllowed() {
+				return dec.tokenError(c)
+			}
+			if len(dec.tokenStack) >= dec.scan.depthLimit() {
+				return nil, &SyntaxError{"invalid character " + quoteChar(c) + " exceeded max depth", dec.InputOffset()}
+			}
+			dec.scanp++
+			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
+			dec.tokenState = tokenArrayStart
+			return Delim('['), nil
+
+		case ']':
+			if dec.tokenState != tokenArrayStart && dec.tokenState != tokenArrayComma {
+				return dec.tokenError(c)
+			}
+			dec.scanp++
+			dec.tokenState = dec.tokenStack[len(dec.tokenStack)-1]
+			dec.tokenStack = dec.tokenStack[:len(dec.tokenStack)-1]
+			dec.tokenValueEnd()
+			return Delim(']'), nil
+
+		case '{':
+			if !dec.tokenValueAllowed() {
+				return dec.tokenError(c)
+			}
+			if len(dec.tokenStack) >= dec.scan.depthLimit() {
+				return nil, &SyntaxError{"invalid character " + quoteChar(c) + " exceeded max depth", dec.InputOffset()}
+			}
+			dec.scanp++
+			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
+			dec.tokenState = tokenObjectStart
+			return Delim('{'), nil
+
+		case '}':
+			if dec.tokenState != tokenObjectStart && dec.tokenState != tokenObjectComma {
+				return dec.tokenError(c)
+			}
+			dec.scanp++
+			dec.tokenState = dec.tokenStack[len(dec.tokenStack)-1]
+			dec.tokenStack = dec.tokenStack[:len(dec.tokenStack)-1]
+			dec.tokenValueEnd()
+			return Delim('}'), nil
+
+		case ':':
+			if dec.tokenState != tokenObjectColon {
+				return dec.tokenError(c)
+			}
+			dec.scanp++
+			dec.tokenState = tokenObjectValue
+			continue
+
+		case ',':
+			if dec.tokenState == tokenArrayComma {
+				dec.scanp++
+				dec.tokenState = tokenArrayValue
+				continue
+			}
+			if dec.tokenState == tokenObjectComma {
+				dec.scanp++
+				dec.tokenState = tokenObjectKey
+				continue
+			}
+			return dec.tokenError(c)
+
+		case '"':
+			if dec.tokenState == tokenObjectStart || dec.tokenState == tokenObjectKey {
+				var x string
+				old := dec.tokenState
+				dec.tokenState = tokenTopValue
+				err := dec.Decode(&x)
+				dec.tokenState = old
+				if err != nil {
+					return nil, err
+				}
+				dec.tokenState = tokenObjectColon
+				return x, nil
+			}
+			fallthrough
+
+		default:
+			if !dec.tokenValueAllowed() {
+				return dec.tokenError(c)
+			}
+			var x interface{}
+			if err := dec.Decode(&x); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+}
+
+func (dec *Decoder) tokenError(c byte) (Token, error) {
+	var context string
+	switch dec.tokenState {
+	case tokenTopValue:
+		context = " looking for beginning of value"
+	case tokenArrayStart, tokenArrayValue, tokenObjectValue:
+		context = " looking for beginning of value"
+	case tokenArrayComma:
+		context = " after array element"
+	case tokenObjectStart, tokenObjectKey:
+		context = " looking for beginning of object key string"
+	case tokenObjectColon:
+		context = " after object key"
+	case tokenObjectComma:
+		context = " after object key:value pair"
+	}
+	return nil, &SyntaxError{"invalid character " + quoteChar(c) + context, dec.InputOffset()}
+}
+
+// More 判断当前的数组或者对象里还有没有元素
+func (dec *Decoder) More() bool {
+	c, err := dec.peek()
+	return err == nil && c != ']' && c != '}'
+}
+
+// peek 返回下一个非空白字节, 但是不读掉它
+func (dec *Decoder) peek() (byte, error) {
+	var err error
+	for {
+		for i := dec.scanp; i < len(dec.buf); i++ {
+			c := dec.buf[i]
+			if isSpace(c) {
+				continue
+			}
+			dec.scanp = i
+			return c, nil
+		}
+		// 缓冲区用完了, 上一次读的错误现在才返回
+		if err != nil {
+			return 0, err
+		}
+		err = dec.refill()
+	}
+}
+
+// Encoder 把JSON值一个一个地写到输出流里
+type Encoder struct {
+	w          io.Writer
+	err        error
+	escapeHTML bool
+	canonical  bool
+
+	indentBuf    *bytes.Buffer
+	indentPrefix string
+	indentValue  string
+}
+
+// NewEncoder 返回一个写到w的Encoder
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, escapeHTML: true}
+}
+
+// Encode 把v编码后写到流里, 后面跟一个换行符
+// 写出错以后, 之后的Encode都返回同一个错误
+func (enc *Encoder) Encode(v interface{}) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+
+	err := e.marshal(v, enc.escapeHTML && !enc.canonical)
+	if err != nil {
+		return err
+	}
+
+	if enc.canonical {
+		c := newEncodeState()
+		defer encodeStatePool.Put(c)
+		if err := c.canonicalize(e.Bytes()); err != nil {
+			return err
+		}
+		e = c
+	}
+
+	// 每个值后面加一个换行, 方便按行读取, 也方便人看
+	e.WriteByte('\n')
+
+	b := e.Bytes()
+	if !enc.canonical && (enc.indentPrefix != "" || enc.indentValue != "") {
+		if enc.indentBuf == nil {
+			enc.indentBuf = new(bytes.Buffer)
+		}
+		enc.indentBuf.Reset()
+		err = Indent(enc.indentBuf, b, enc.indentPrefix, enc.indentValue)
+		if err != nil {
+			return err
+		}
+		b = enc.indentBuf.Bytes()
+	}
+	if _, err = enc.w.Write(b); err != nil {
+		enc.err = err
+	}
+	return err
+}
+
+// SetIndent 让之后的每个值都像MarshalIndent那样缩进
+// prefix与indent都为空时不缩进
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.indentPrefix = prefix
+	enc.indentValue = indent
+}
+
+// SetEscapeHTML 设置字符串里的<, >, &要不要转义, 默认转义
+// 输出不会嵌入HTML时可以关掉, 可读性更好
+func (enc *Encoder) SetEscapeHTML(on bool) {
+	enc.escapeHTML = on
+}
+
+// SetCanonical 让之后的每个值都按MarshalCanonical的规则输出
+// 打开以后SetIndent与SetEscapeHTML不再起作用, 每个值后面还是会有一个换行
+func (enc *Encoder) SetCanonical(on bool) {
+	enc.canonical = on
+}
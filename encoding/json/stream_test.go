@@ -0,0 +1,244 @@
+package json
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range []interface{}{1, "<x>", map[string]int{"b": 2, "a": 1}, nil} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "1\n\"\\u003cx\\u003e\"\n{\"a\":1,\"b\":2}\nnull\n"
+	if buf.String() != want {
+		t.Errorf("Encode:\ngot  %q\nwant %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	enc.SetEscapeHTML(false)
+	enc.Encode("<x>")
+	if buf.String() != "\"<x>\"\n" {
+		t.Errorf("SetEscapeHTML(false): got %q", buf.String())
+	}
+}
+
+func TestEncoderPoolReuse(t *testing.T) {
+	// Marshal的结果不能和池子里的缓冲区共用内存
+	a, _ := Marshal(strings.Repeat("a", 100))
+	b, _ := Marshal(strings.Repeat("b", 100))
+	if a[1] != 'a' || b[1] != 'b' {
+		t.Errorf("Marshal results share memory: %s %s", a, b)
+	}
+}
+
+type errWriter struct{ n int }
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	w.n++
+	return 0, bytes.ErrTooLarge
+}
+
+func TestEncoderStickyError(t *testing.T) {
+	w := &errWriter{}
+	enc := NewEncoder(w)
+	if err := enc.Encode(1); err != bytes.ErrTooLarge {
+		t.Fatalf("Encode = %v", err)
+	}
+	if err := enc.Encode(2); err != bytes.ErrTooLarge || w.n != 1 {
+		t.Errorf("second Encode = %v after %d writes", err, w.n)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	in := ` {"a":1} [2,3]
+"four"	5 null`
+	dec := NewDecoder(strings.NewReader(in))
+	var got []interface{}
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	want := []interface{}{map[string]interface{}{"a": 1.0}, []interface{}{2.0, 3.0}, "four", 5.0, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %v; want %v", got, want)
+	}
+	if off := dec.InputOffset(); off != int64(len(in)) {
+		t.Errorf("InputOffset = %d; want %d", off, len(in))
+	}
+}
+
+// oneByteReader 每次只返回一个字节, 值会跨越多次refill
+type oneByteReader struct{ r io.Reader }
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[:1])
+}
+
+func TestDecoderSmallReads(t *testing.T) {
+	dec := NewDecoder(oneByteReader{strings.NewReader(`{"x":[1,{"y":"z"}]} 7`)})
+	var v struct{ X []interface{} }
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.X) != 2 {
+		t.Errorf("Decode = %+v", v)
+	}
+	var n int
+	if err := dec.Decode(&n); err != nil || n != 7 {
+		t.Errorf("second Decode = %d, %v", n, err)
+	}
+}
+
+func TestDecoderBuffered(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"a":1} rest of input`))
+	var v map[string]int
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(dec.Buffered())
+	if string(b) != " rest of input" {
+		t.Errorf("Buffered = %q", b)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"a":1,"b":2}`))
+	dec.DisallowUnknownFields()
+	var v struct{ A int }
+	if err := dec.Decode(&v); err == nil || !strings.Contains(err.Error(), `unknown field "b"`) {
+		t.Errorf("DisallowUnknownFields: Decode error = %v", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(`[1, 2`))
+	var a []int
+	if err := dec.Decode(&a); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated input: Decode error = %v; want io.ErrUnexpectedEOF", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(`[1] ]`))
+	if err := dec.Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	err := dec.Decode(&a)
+	if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("Decode error = %v (%T); want *SyntaxError", err, err)
+	}
+	// 出错之后一直返回同一个错误
+	if err2 := dec.Decode(&a); err2 != err {
+		t.Errorf("Decode after error = %v; want %v", err2, err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"a": [1, "x", true, null], "b": {}, "c": []} 3`))
+	var got []Token
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tok)
+	}
+	want := []Token{
+		Delim('{'),
+		"a", Delim('['), 1.0, "x", true, nil, Delim(']'),
+		"b", Delim('{'), Delim('}'),
+		"c", Delim('['), Delim(']'),
+		Delim('}'),
+		3.0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Token:\ngot  %v\nwant %v", got, want)
+	}
+}
+
+// Token与More配合Decode, 流式地处理一个大数组里的元素
+func TestTokenMoreDecode(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"items": [{"n": 1}, {"n": 2}, {"n": 3}], "total": 3}`))
+	expect := func(want Token) {
+		t.Helper()
+		tok, err := dec.Token()
+		if err != nil || tok != want {
+			t.Fatalf("Token = %v, %v; want %v", tok, err, want)
+		}
+	}
+	expect(Delim('{'))
+	expect("items")
+	expect(Delim('['))
+	var ns []int
+	for dec.More() {
+		var item struct{ N int }
+		if err := dec.Decode(&item); err != nil {
+			t.Fatal(err)
+		}
+		ns = append(ns, item.N)
+	}
+	expect(Delim(']'))
+	if !reflect.DeepEqual(ns, []int{1, 2, 3}) {
+		t.Errorf("items = %v", ns)
+	}
+	if !dec.More() {
+		t.Error("More() = false before \"total\"")
+	}
+	expect("total")
+	var total int
+	if err := dec.Decode(&total); err != nil || total != 3 {
+		t.Errorf("Decode total = %d, %v", total, err)
+	}
+	if dec.More() {
+		t.Error("More() = true at end of object")
+	}
+	expect(Delim('}'))
+	if _, err := dec.Token(); err != io.EOF {
+		t.Errorf("Token at end = %v; want io.EOF", err)
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		msg  string
+		skip int // 出错之前正常返回的token数
+	}{
+		{`]`, "looking for beginning of value", 0},
+		{`[1 2]`, "after array element", 2},
+		{`{1:2}`, "looking for beginning of object key string", 1},
+		{`{"a" "b"}`, "after object key", 2},
+		{`{"a":1 "b":2}`, "after object key:value pair", 3},
+		{`[}`, "looking for beginning of value", 1},
+	}
+	for _, tt := range tests {
+		dec := NewDecoder(strings.NewReader(tt.in))
+		for i := 0; i < tt.skip; i++ {
+			if _, err := dec.Token(); err != nil {
+				t.Fatalf("%s: token %d: %v", tt.in, i, err)
+			}
+		}
+		_, err := dec.Token()
+		se, ok := err.(*SyntaxError)
+		if !ok || !strings.Contains(se.Error(), tt.msg) {
+			t.Errorf("%s: Token error = %v; want SyntaxError containing %q", tt.in, err, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package json
+
+import (
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 严格模式
+//
+// 默认情况下, 对象里重复的key取最后一个, 字符串里非法的UTF-8与落单的代理对(\ud800这样的)
+// 被替换成U+FFFD, 这和大多数实现一致, 但是不同的实现对重复key的处理并不一样,
+// 同一段JSON在两个系统里可能被解析成不同的值; 严格模式把这些情况都当成错误
+
+// DuplicateKeyError 是严格模式下遇到对象里重复的key时返回的错误
+// Offset是第二次出现的key在输入里的位置
+type DuplicateKeyError struct {
+	Key    string
+	Offset int64
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return "json: duplicate key " + strconv.Quote(e.Key) + " at offset " + strconv.FormatInt(e.Offset, 10)
+}
+
+// UnmarshalStrict 和Unmarshal一样, 但是使用严格模式:
+// 对象里有重复的key时返回*DuplicateKeyError, 有非法的UTF-8时返回*InvalidUTF8Error
+func UnmarshalStrict(data []byte, v interface{}) error {
+	var d decodeState
+	if err := checkValid(data, &d.scan); err != nil {
+		return err
+	}
+	d.init(data)
+	d.strict = true
+	return d.unmarshal(v)
+}
+
+// Strict 让Decoder使用严格模式, 见UnmarshalStrict
+func (dec *Decoder) Strict() { dec.d.strict = true }
+
+// Strict 让LinesReader使用严格模式, 见UnmarshalStrict
+func (lr *LinesReader) Strict() { lr.d.strict = true }
+
+// checkStrict 检查data里有没有重复的key与非法的UTF-8
+// data必须已经用checkValid检查过, 所以这里不用处理语法错误
+// 整个值都要检查, 包括解码时会被跳过的部分
+func checkStrict(data []byte) error {
+	c := strictChecker{data: data}
+	return c.value()
+}
+
+type strictChecker struct {
+	data []byte
+	off  int
+}
+
+func (c *strictChecker) skipSpace() {
+	for c.off < len(c.data) && isSpace(c.data[c.off]) {
+		c.off++
+	}
+}
+
+func (c *strictChecker) value() error {
+	c.skipSpace()
+	switch c.data[c.off] {
+	case '{':
+		c.off++
+		var keys map[string]struct{}
+		for {
+			c.skipSpace()
+			if c.data[c.off] == '}' {
+				c.off++
+				return nil
+			}
+			start := c.off
+			key, err := c.string(true)
+			if err != nil {
+				return err
+			}
+			if _, dup := keys[key]; dup {
+				return &DuplicateKeyError{key, int64(start)}
+			}
+			if keys == nil {
+				keys = make(map[string]struct{})
+			}
+			keys[key] = struct{}{}
+			c.skipSpace()
+			c.off++ // :
+			if err := c.value(); err != nil {
+				return err
+			}
+			c.skipSpace()
+			if c.data[c.off] == ',' {
+				c.off++
+			}
+		}
+	case '[':
+		c.off++
+		for {
+			c.skipSpace()
+			if c.data[c.off] == ']' {
+				c.off++
+				return nil
+			}
+			if err := c.value(); err != nil {
+				return err
+			}
+			c.skipSpace()
+			if c.data[c.off] == ',' {
+				c.off++
+			}
+		}
+	case '"':
+		_, err := c.string(false)
+		return err
+	}
+	// 数字, true/false/null
+	for c.off < len(c.data) {
+		switch b := c.data[c.off]; {
+		case b == ',' || b == '}' || b == ']' || isSpace(b):
+			return nil
+		}
+		c.off++
+	}
+	return nil
+}
+
+// string 读一个字符串, 检查UTF-8与\u转义里的代理对
+// wantValue为true时返回去掉转义以后的值
+func (c *strictChecker) string(wantValue bool) (string, error) {
+	start := c.off
+	i := c.off + 1
+	for ; i < len(c.data); i++ {
+		b := c.data[i]
+		if b == '"' {
+			break
+		}
+		if b == '\\' {
+			i++
+			if c.data[i] != 'u' {
+				continue
+			}
+			r := getu4(c.data[i-1:])
+			if !utf16.IsSurrogate(r) {
+				i += 4
+				continue
+			}
+			// 高位代理后面必须跟着低位代理
+			if r < 0xDC00 {
+				if r2 := getu4(c.data[i+5:]); r2 >= 0xDC00 && r2 <= 0xDFFF {
+					i += 10
+					continue
+				}
+			}
+			return "", &InvalidUTF8Error{string(c.data[start+1 : i+5])}
+		}
+	}
+	c.off = i + 1
+	raw := c.data[start:c.off]
+	if !utf8.Valid(raw) {
+		return "", &InvalidUTF8Error{string(raw[1 : len(raw)-1])}
+	}
+	if !wantValue {
+		return "", nil
+	}
+	s, ok := unquote(raw)
+	if !ok {
+		return "", errPhase
+	}
+	return s, nil
+}
@@ -0,0 +1,73 @@
+package json
+
+import (
+	"strings"
+	"testing"
+)
+
+var strictTests = []struct {
+	in     string
+	errStr string // 为空表示没有错误
+}{
+	{`{"a":1,"b":{"a":2}}`, ""},
+	{`[{"a":1},{"a":2}]`, ""},
+	{`{"a":1,"a":2}`, `duplicate key "a" at offset 7`},
+	{`{"x":[{"k":1,"k":1}]}`, `duplicate key "k" at offset 13`},
+	{`{"a":1,"a":2}`, `duplicate key "a"`},
+	{"{\"ok\":\"\xf0\x9f\x98\x80\",\"pair\":\"\\ud83d\\ude00\"}", ""},
+	{`{"s":"\ud83d"}`, "invalid UTF-8"},
+	{`{"s":"\ude00\ud83d"}`, "invalid UTF-8"},
+	{"{\"s\":\"\xff\"}", "invalid UTF-8"},
+	{"{\"\xc3\x28\":1}", "invalid UTF-8"},
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	for _, tt := range strictTests {
+		var v interface{}
+		err := UnmarshalStrict([]byte(tt.in), &v)
+		if tt.errStr == "" {
+			if err != nil {
+				t.Errorf("UnmarshalStrict(%#q) = %v", tt.in, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.errStr) {
+			t.Errorf("UnmarshalStrict(%#q) = %v, want error containing %q", tt.in, err, tt.errStr)
+		}
+		// 非严格模式都可以解码
+		if err := Unmarshal([]byte(tt.in), &v); err != nil {
+			t.Errorf("Unmarshal(%#q) = %v", tt.in, err)
+		}
+	}
+}
+
+func TestStrictSkippedValues(t *testing.T) {
+	// 没有对应字段的值也要检查
+	var s struct{ A int }
+	err := UnmarshalStrict([]byte(`{"A":1,"Unknown":{"x":1,"x":2}}`), &s)
+	if _, ok := err.(*DuplicateKeyError); !ok {
+		t.Errorf("got %v, want *DuplicateKeyError", err)
+	}
+	err = UnmarshalStrict([]byte("{\"A\":1,\"B\":\"\xff\"}"), &s)
+	if _, ok := err.(*InvalidUTF8Error); !ok {
+		t.Errorf("got %v, want *InvalidUTF8Error", err)
+	}
+}
+
+func TestDecoderStrict(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"a":1} {"b":1,"b":2}`))
+	dec.Strict()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	err := dec.Decode(&v)
+	if e, ok := err.(*DuplicateKeyError); !ok || e.Offset != 15 {
+		t.Errorf("got %v, want duplicate key at offset 15", err)
+	}
+
+	lr := NewLinesReader(strings.NewReader("{\"a\":1}\n{\"a\":1,\"a\":1}\n"))
+	lr.Strict()
+	lr.Decode(&v)
+	if err := lr.Decode(&v); err == nil || err.(*LineError).Line != 2 {
+		t.Errorf("LinesReader: got %v, want error on line 2", err)
+	}
+}
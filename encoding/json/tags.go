@@ -0,0 +1,53 @@
+package json
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tagOptions 是struct tag里名字后面逗号分隔的部分, 例如`json:"name,omitempty,string"`里的"omitempty,string"
+type tagOptions string
+
+// parseTag 把tag分成名字和选项两部分
+func parseTag(tag string) (string, tagOptions) {
+	if idx := strings.Index(tag, ","); idx != -1 {
+		return tag[:idx], tagOptions(tag[idx+1:])
+	}
+	return tag, tagOptions("")
+}
+
+// Contains 判断选项里有没有optionName
+func (o tagOptions) Contains(optionName string) bool {
+	if len(o) == 0 {
+		return false
+	}
+	s := string(o)
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == optionName {
+			return true
+		}
+		s = next
+	}
+	return false
+}
+
+// isValidTag 判断tag里的名字能不能作为JSON的key
+// 引号与反斜杠不允许, 其它的标点允许
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
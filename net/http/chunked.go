@@ -0,0 +1,182 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// chunked编码(RFC 7230 4.1): 每一块是十六进制的长度, 可选的扩展, \r\n, 数据, \r\n,
+// 长度为0的一块表示结束, 后面跟着trailer与一个空行
+
+const maxChunkLineLength = 4096
+
+var errChunkLineTooLong = errors.New("http: chunk header line too long")
+
+// chunkedReader 解码chunked编码的body
+// 读到长度为0的最后一块时返回io.EOF, 后面的trailer留给调用者读
+type chunkedReader struct {
+	r        *bufio.Reader
+	n        uint64 // 当前块还没读的字节数
+	err      error
+	buf      [2]byte
+	checkEnd bool // 当前块读完了, 接下来要读块末尾的\r\n
+}
+
+func newChunkedReader(r *bufio.Reader) io.Reader {
+	return &chunkedReader{r: r}
+}
+
+func (cr *chunkedReader) beginChunk() {
+	var line []byte
+	line, cr.err = readChunkLine(cr.r)
+	if cr.err != nil {
+		return
+	}
+	cr.n, cr.err = parseHexUint(line)
+	if cr.err != nil {
+		return
+	}
+	if cr.n == 0 {
+		cr.err = io.EOF
+	}
+}
+
+// chunkHeaderAvailable 判断下一块的长度行是否已经在缓冲里, 读它不会阻塞
+func (cr *chunkedReader) chunkHeaderAvailable() bool {
+	n := cr.r.Buffered()
+	if n > 0 {
+		peek, _ := cr.r.Peek(n)
+		return bytes.IndexByte(peek, '\n') >= 0
+	}
+	return false
+}
+
+func (cr *chunkedReader) Read(b []byte) (n int, err error) {
+	for cr.err == nil {
+		if cr.checkEnd {
+			if n > 0 && cr.r.Buffered() < 2 {
+				// 已经读到数据了, 不为了等\r\n阻塞
+				break
+			}
+			if _, cr.err = io.ReadFull(cr.r, cr.buf[:2]); cr.err == nil {
+				if string(cr.buf[:]) != "\r\n" {
+					cr.err = errors.New("http: malformed chunked encoding")
+					break
+				}
+			} else if cr.err == io.EOF {
+				cr.err = io.ErrUnexpectedEOF
+			}
+			cr.checkEnd = false
+		}
+		if cr.n == 0 {
+			if n > 0 && !cr.chunkHeaderAvailable() {
+				// 同上, 先把读到的数据返回
+				break
+			}
+			cr.beginChunk()
+			continue
+		}
+		if len(b) == 0 {
+			break
+		}
+		rbuf := b
+		if uint64(len(rbuf)) > cr.n {
+			rbuf = rbuf[:cr.n]
+		}
+		var n0 int
+		n0, cr.err = cr.r.Read(rbuf)
+		n += n0
+		b = b[n0:]
+		cr.n -= uint64(n0)
+		if cr.err == io.EOF {
+			// 块还没读完连接就断了
+			cr.err = io.ErrUnexpectedEOF
+		}
+		if cr.n == 0 && cr.err == nil {
+			cr.checkEnd = true
+		}
+	}
+	return n, cr.err
+}
+
+// readChunkLine 读一行块的长度, 去掉行尾的空白与扩展
+func readChunkLine(b *bufio.Reader) ([]byte, error) {
+	p, err := b.ReadSlice('\n')
+	if err != nil {
+		// 长度行不应该被EOF截断
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		} else if err == bufio.ErrBufferFull {
+			err = errChunkLineTooLong
+		}
+		return nil, err
+	}
+	if len(p) >= maxChunkLineLength {
+		return nil, errChunkLineTooLong
+	}
+	p = bytes.TrimRight(p, " \t\r\n")
+	if i := bytes.IndexByte(p, ';'); i >= 0 {
+		p = p[:i]
+	}
+	return p, nil
+}
+
+func parseHexUint(v []byte) (n uint64, err error) {
+	if len(v) == 0 {
+		return 0, errors.New("http: empty hex number for chunk length")
+	}
+	for i, b := range v {
+		switch {
+		case '0' <= b && b <= '9':
+			b = b - '0'
+		case 'a' <= b && b <= 'f':
+			b = b - 'a' + 10
+		case 'A' <= b && b <= 'F':
+			b = b - 'A' + 10
+		default:
+			return 0, errors.New("http: invalid byte in chunk length")
+		}
+		if i == 16 {
+			return 0, errors.New("http: chunk length too large")
+		}
+		n <<= 4
+		n |= uint64(b)
+	}
+	return n, nil
+}
+
+// chunkedWriter 把写入的数据编码成chunked格式
+// Close只写出长度为0的最后一块, trailer与结尾的空行留给调用者写
+type chunkedWriter struct {
+	Wire io.Writer
+}
+
+func newChunkedWriter(w io.Writer) io.WriteCloser {
+	return &chunkedWriter{w}
+}
+
+func (cw *chunkedWriter) Write(data []byte) (n int, err error) {
+	// 长度为0的一块表示结束, 不能写出去
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if _, err = fmt.Fprintf(cw.Wire, "%x\r\n", len(data)); err != nil {
+		return 0, err
+	}
+	if n, err = cw.Wire.Write(data); err != nil {
+		return
+	}
+	if n != len(data) {
+		return n, io.ErrShortWrite
+	}
+	_, err = io.WriteString(cw.Wire, "\r\n")
+	return
+}
+
+func (cw *chunkedWriter) Close() error {
+	_, err := io.WriteString(cw.Wire, "0\r\n")
+	return err
+}
@@ -1,6 +1,13 @@
 package http
 
-import "net/textproto"
+import (
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
 
 var reaceEnabled = false // set by race.go
 
@@ -18,3 +25,99 @@ func (h Header) Set(key, value string) {
 func (h Header) Get(key string) string {
 	return textproto.MIMEHeader(h).Get(key)
 }
+
+func (h Header) Del(key string) {
+	textproto.MIMEHeader(h).Del(key)
+}
+
+// Write 按wire格式写出header, 每行一个"Key: value\r\n"
+func (h Header) Write(w io.Writer) error {
+	return h.WriteSubset(w, nil)
+}
+
+// headerNewlineToSpace 把值里的换行换成空格, 防止客户端借header注入新的行
+var headerNewlineToSpace = strings.NewReplacer("\n", " ", "\r", " ")
+
+// WriteSubset 和Write一样, 但是跳过exclude里为true的key
+// key按字典序写出, 这样同样的header每次写出来都一样
+func (h Header) WriteSubset(w io.Writer, exclude map[string]bool) error {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		if !exclude[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			v = headerNewlineToSpace.Replace(v)
+			v = textproto.TrimString(v)
+			if _, err := fmt.Fprintf(w, "%s: %s\r\n", k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clone 返回h的拷贝, 值的切片也是新的, 修改拷贝不会影响h
+func (h Header) clone() Header {
+	h2 := make(Header, len(h))
+	for k, vv := range h {
+		vv2 := make([]string, len(vv))
+		copy(vv2, vv)
+		h2[k] = vv2
+	}
+	return h2
+}
+
+// CanonicalHeaderKey 返回key的规范形式, 比如"accept-encoding"的规范形式是"Accept-Encoding"
+func CanonicalHeaderKey(s string) string { return textproto.CanonicalMIMEHeaderKey(s) }
+
+// foreachHeaderElement 把逗号分隔的header值拆开, 对每个非空的元素调用fn
+func foreachHeaderElement(v string, fn func(string)) {
+	for _, f := range strings.Split(v, ",") {
+		if f = textproto.TrimString(f); f != "" {
+			fn(f)
+		}
+	}
+}
+
+// hasToken 判断逗号分隔的header值v里是否有token, 不区分大小写
+// 比如hasToken("keep-alive, Upgrade", "upgrade")为true
+func hasToken(v, token string) bool {
+	found := false
+	foreachHeaderElement(v, func(f string) {
+		if strings.EqualFold(f, token) {
+			found = true
+		}
+	})
+	return found
+}
+
+// headerValuesContainsToken 和hasToken一样, 但是检查一个key的所有值
+func headerValuesContainsToken(values []string, token string) bool {
+	for _, v := range values {
+		if hasToken(v, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSetHeader 把src里的值设到*dst里, *dst为nil时直接用src
+func mergeSetHeader(dst *Header, src Header) {
+	if *dst == nil {
+		*dst = src
+		return
+	}
+	for k, vv := range src {
+		(*dst)[k] = vv
+	}
+}
+
+// isNotToken 判断r是否不能出现在RFC 7230定义的token里
+// method与header的key都必须是token
+func isNotToken(r rune) bool {
+	return r <= ' ' || r >= utf8.RuneSelf || r == 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+}
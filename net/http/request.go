@@ -11,9 +11,11 @@ import (
 	"mime/multipart"
 	"net"
 	"net/http/httptrace"
+	"net/textproto"
 	"net/url"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/net/idna"
 
@@ -70,10 +72,10 @@ type badStringError struct {
 	str  string
 }
 
-func (e *badStringError) Error() string { return fmt.Sprintf("%s %q", e.waht, e.str) }
+func (e *badStringError) Error() string { return fmt.Sprintf("%s %q", e.what, e.str) }
 
 // Headers that Request.Write handles itself and should be skipped.
-var reqWriteExecludeHeader = map[string]bool{
+var reqWriteExcludeHeader = map[string]bool{
 	"Host":              true,
 	"User-Agent":        true,
 	"Content-Length":    true,
@@ -204,7 +206,7 @@ type Request struct {
 	// sets RemoteAddr to on "IP:port"  address before invoking a
 	// handler.
 	// This field is ignored by the HTTP clients.
-	RemtoeAddr string
+	RemoteAddr string
 
 	// RequestURI is the unmodified Request-URI of the
 	// Request-Line （RFC 2616, Section 5.1) as sent by the client
@@ -320,7 +322,7 @@ func (r *Request) Cookie(name string) (*Cookie, error) {
 // means all cookies, if any, are written into the same line,
 // separated by semicolon.
 func (r *Request) AddCookie(c *Cookie) {
-	s := fmt.Sprintf("%s=%s", sanitizeCooieName(c.Name), santizeCookieValue(c.Value))
+	s := fmt.Sprintf("%s=%s", sanitizeCookieName(c.Name), sanitizeCookieValue(c.Value))
 	if c := r.Header.Get("Cookie"); c != "" {
 		r.Header.Set("Cookie", c+"; "+s)
 	} else {
@@ -373,7 +375,7 @@ func (r *Request) multipartReader() (*multipart.Reader, error) {
 	if !ok {
 		return nil, ErrMissingBoundary
 	}
-	return multipart.NewReader(r.body, boundary), nil
+	return multipart.NewReader(r.Body, boundary), nil
 }
 
 // isH2Upgrade reprots whether r represents the http2 "client preface"
@@ -552,6 +554,15 @@ func (req *Request) write(w io.Writer, usingProxy bool, extraHeader Header, wait
 	return nil
 }
 
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
 func idnaASCII(v string) (string, error) {
 	if isASCII(v) {
 		return v, nil
@@ -571,7 +582,7 @@ func cleanHost(in string) string {
 	if i := strings.IndexAny(in, " /"); i != -1 {
 		in = in[:i]
 	}
-	host, prot, err := net.SplitHostPort(in)
+	host, port, err := net.SplitHostPort(in)
 	if err != nil {
 		a, err := idnaASCII(in)
 		if err != nil {
@@ -631,7 +642,7 @@ func ParseHTTPVersion(vers string) (major, minor int, ok bool) {
 	return major, minor, true
 }
 
-func validMehtod(method string) bool {
+func validMethod(method string) bool {
 	/*
 		Method = "OPTIONS"
 				| "GET"
@@ -644,3 +655,109 @@ func validMehtod(method string) bool {
 	*/
 	return len(method) > 0 && strings.IndexFunc(method, isNotToken) == -1
 }
+
+// ReadRequest reads and parses an incoming request from b.
+func ReadRequest(b *bufio.Reader) (*Request, error) {
+	return readRequest(b)
+}
+
+func readRequest(b *bufio.Reader) (req *Request, err error) {
+	tp := textproto.NewReader(b)
+	req = new(Request)
+
+	// First line: GET /index.html HTTP/1.0
+	var s string
+	if s, err = tp.ReadLine(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	var ok bool
+	req.Method, req.RequestURI, req.Proto, ok = parseRequestLine(s)
+	if !ok {
+		return nil, &badStringError{"malformed HTTP request", s}
+	}
+	if !validMethod(req.Method) {
+		return nil, &badStringError{"invalid method", req.Method}
+	}
+	rawurl := req.RequestURI
+	if req.ProtoMajor, req.ProtoMinor, ok = ParseHTTPVersion(req.Proto); !ok {
+		return nil, &badStringError{"malformed HTTP version", req.Proto}
+	}
+
+	// CONNECT requests are used two different ways, and neither uses a full URL:
+	// the standard use is to tunnel HTTPS through an HTTP proxy, where the
+	// Request-URI is just "host:port". Prepend a scheme so url.ParseRequestURI
+	// understands it, then drop the scheme again.
+	justAuthority := req.Method == "CONNECT" && !strings.HasPrefix(rawurl, "/")
+	if justAuthority {
+		rawurl = "http://" + rawurl
+	}
+	if req.URL, err = url.ParseRequestURI(rawurl); err != nil {
+		return nil, err
+	}
+	if justAuthority {
+		req.URL.Scheme = ""
+	}
+
+	// Subsequent lines: Key: value.
+	mimeHeader, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	req.Header = Header(mimeHeader)
+
+	// RFC 2616: Must treat
+	//	GET /index.html HTTP/1.1
+	//	Host: www.google.com
+	// and
+	//	GET http://www.google.com/index.html HTTP/1.1
+	//	Host: doesntmatter
+	// the same. In the second case, any Host line is ignored.
+	req.Host = req.URL.Host
+	if req.Host == "" {
+		req.Host = req.Header.Get("Host")
+	}
+	req.Close = shouldClose(req.ProtoMajor, req.ProtoMinor, req.Header)
+
+	if err = readTransfer(req, b); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// parseRequestLine parses "GET /foo HTTP/1.1" into its three parts.
+func parseRequestLine(line string) (method, requestURI, proto string, ok bool) {
+	s1 := strings.Index(line, " ")
+	s2 := strings.Index(line[s1+1:], " ")
+	if s1 < 0 || s2 < 0 {
+		return
+	}
+	s2 += s1 + 1
+	return line[:s1], line[s1+1 : s2], line[s2+1:], true
+}
+
+func (r *Request) expectsContinue() bool {
+	return hasToken(r.Header.Get("Expect"), "100-continue")
+}
+
+func (r *Request) wantsHttp10KeepAlive() bool {
+	if r.ProtoMajor != 1 || r.ProtoMinor != 0 {
+		return false
+	}
+	return hasToken(r.Header.Get("Connection"), "keep-alive")
+}
+
+func (r *Request) wantsClose() bool {
+	return hasToken(r.Header.Get("Connection"), "close")
+}
+
+func (r *Request) closeBody() {
+	if r.Body != nil {
+		r.Body.Close()
+	}
+}
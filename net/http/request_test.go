@@ -1,10 +1,13 @@
-package http_test
+package http
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"net/url"
+	"strings"
 	"testing"
-
-	"github.com/stretchr/testify/assert"
 )
 
 func TestFmtQ(t *testing.T) {
@@ -14,6 +17,125 @@ func TestFmtQ(t *testing.T) {
 
 func TestRemoveZone(t *testing.T) {
 	s := "[fe80::1%en0]:80080"
-	v := removeZone(s)
-	assert.Equal(t, v, "[fe80::1]:80080", "equal")
+	if v := removeZone(s); v != "[fe80::1]:80080" {
+		t.Errorf("removeZone(%q) = %q, want %q", s, v, "[fe80::1]:80080")
+	}
+}
+
+func TestReadRequest(t *testing.T) {
+	raw := "POST http://www.google.com/search?q=go HTTP/1.1\r\n" +
+		"Host: doesntmatter\r\n" +
+		"Content-Length: 5\r\n" +
+		"Cookie: a=1; b=\"two\"; bad name=3\r\n" +
+		"\r\n" +
+		"hello"
+	req, err := ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "POST" || req.Host != "www.google.com" || req.URL.Path != "/search" ||
+		req.URL.RawQuery != "q=go" || req.ContentLength != 5 || req.Close {
+		t.Errorf("got %+v", req)
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil || string(b) != "hello" {
+		t.Errorf("body %q, %v", b, err)
+	}
+	cookies := req.Cookies()
+	if len(cookies) != 2 || cookies[0].Value != "1" || cookies[1].Value != "two" {
+		t.Errorf("cookies %v", cookies)
+	}
+	if c, err := req.Cookie("b"); err != nil || c.Value != "two" {
+		t.Errorf("Cookie(b) = %v, %v", c, err)
+	}
+	if _, err := req.Cookie("c"); err != ErrNoCookie {
+		t.Errorf("Cookie(c) error = %v, want ErrNoCookie", err)
+	}
+}
+
+func TestReadRequestErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"GET\r\n\r\n",
+		"GET / HTTP/1.1\r\nHost: a\r\n", // 没有空行
+		"G(T / HTTP/1.1\r\n\r\n",
+		"GET / HTTPS/1.1\r\n\r\n",
+		"POST / HTTP/1.1\r\nContent-Length: 1\r\nContent-Length: 2\r\n\r\n",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n",
+		"POST / HTTP/1.1\r\nTrailer: X\r\nContent-Length: 1\r\n\r\nx",
+	}
+	for _, raw := range tests {
+		if _, err := ReadRequest(bufio.NewReader(strings.NewReader(raw))); err == nil {
+			t.Errorf("%q: no error", raw)
+		}
+	}
+}
+
+// 用Request.Write写出来的请求, ReadRequest读回来应该是一样的
+func TestRequestWriteRoundTrip(t *testing.T) {
+	tests := []struct {
+		body          string
+		contentLength int64
+		trailer       Header
+		wantTE        string
+	}{
+		{"", 0, nil, ""},
+		{"hello", 5, nil, ""},
+		{"hello", 0, nil, "chunked"}, // 长度未知
+		{"hello", -1, Header{"X-Sum": {"42"}}, "chunked"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse("http://example.com/path?q=1")
+		req := &Request{
+			Method:        "POST",
+			URL:           u,
+			Header:        Header{"X-Test": {"a"}},
+			ContentLength: tt.contentLength,
+			Trailer:       tt.trailer,
+		}
+		req.AddCookie(&Cookie{Name: "id", Value: "a b"})
+		if tt.body != "" {
+			req.Body = io.NopCloser(strings.NewReader(tt.body))
+		}
+		var buf bytes.Buffer
+		if err := req.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		wire := buf.String()
+
+		got, err := ReadRequest(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatalf("%q: %v", wire, err)
+		}
+		b, err := io.ReadAll(got.Body)
+		if err != nil {
+			t.Fatalf("%q: %v", wire, err)
+		}
+		if string(b) != tt.body || got.Host != "example.com" || got.RequestURI != "/path?q=1" ||
+			got.Header.Get("X-Test") != "a" || got.Header.Get("User-Agent") != defaultUserAgent {
+			t.Errorf("%q: got body %q, %+v", wire, b, got)
+		}
+		if te := strings.Join(got.TransferEncoding, ","); te != tt.wantTE {
+			t.Errorf("%q: TransferEncoding %q, want %q", wire, te, tt.wantTE)
+		}
+		if c, err := got.Cookie("id"); err != nil || c.Value != "a b" {
+			t.Errorf("%q: cookie %v, %v", wire, c, err)
+		}
+		if tt.trailer != nil && got.Trailer.Get("X-Sum") != "42" {
+			t.Errorf("%q: trailer %v", wire, got.Trailer)
+		}
+	}
+}
+
+func TestRequestWriteErrors(t *testing.T) {
+	u, _ := url.Parse("http://example.com/")
+	req := &Request{Method: "POST", URL: u, Header: Header{}, ContentLength: 10,
+		Body: io.NopCloser(strings.NewReader("short"))}
+	if err := req.Write(io.Discard); err == nil {
+		t.Error("short body: no error")
+	}
+	req = &Request{Method: "GET", Header: Header{}}
+	if err := req.Write(io.Discard); err != errMissingHost {
+		t.Errorf("no host: %v, want errMissingHost", err)
+	}
 }
@@ -0,0 +1,686 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testResponse 是从连接上读到的一个回复
+type testResponse struct {
+	proto   string
+	code    int
+	header  Header
+	body    string
+	trailer Header
+}
+
+// readTestResponse 从br读一个回复, 按Content-Length或者chunked读body
+// 两者都没有时读到连接关闭
+func readTestResponse(t *testing.T, br *bufio.Reader, method string) *testResponse {
+	t.Helper()
+	tp := textproto.NewReader(br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		t.Fatalf("reading status line: %v", err)
+	}
+	f := strings.SplitN(line, " ", 3)
+	if len(f) < 2 {
+		t.Fatalf("bad status line %q", line)
+	}
+	res := &testResponse{proto: f[0]}
+	if res.code, err = strconv.Atoi(f[1]); err != nil {
+		t.Fatalf("bad status line %q", line)
+	}
+	h, err := tp.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("reading header: %v", err)
+	}
+	res.header = Header(h)
+	if method == "HEAD" || !bodyAllowedForStatus(res.code) {
+		return res
+	}
+
+	var body []byte
+	switch {
+	case res.header.Get("Transfer-Encoding") == "chunked":
+		body, err = io.ReadAll(newChunkedReader(br))
+		if err == nil {
+			var h textproto.MIMEHeader
+			h, err = tp.ReadMIMEHeader()
+			res.trailer = Header(h)
+		}
+	case res.header.Get("Content-Length") != "":
+		n, _ := strconv.Atoi(res.header.Get("Content-Length"))
+		body = make([]byte, n)
+		_, err = io.ReadFull(br, body)
+	default:
+		body, err = io.ReadAll(br)
+	}
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	res.body = string(body)
+	return res
+}
+
+// newTestServer 在本地随机端口上启动srv, 返回它的地址
+func newTestServer(t *testing.T, srv *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go srv.Serve(ln)
+	return ln.Addr().String()
+}
+
+func dialTest(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	return c, bufio.NewReader(c)
+}
+
+// expectClosed 检查服务端已经关闭了连接
+func expectClosed(t *testing.T, br *bufio.Reader) {
+	t.Helper()
+	if b, err := br.ReadByte(); err != io.EOF {
+		t.Fatalf("connection not closed: read %q, %v", b, err)
+	}
+}
+
+func TestServeKeepAlive(t *testing.T) {
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.RemoteAddr == "" {
+			t.Error("RemoteAddr not set")
+		}
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Path", r.URL.Path)
+		io.WriteString(w, r.Method+" "+r.Host+" "+string(b))
+	})})
+	c, br := dialTest(t, addr)
+
+	// 两个请求一起发出去, 回复按顺序回来
+	io.WriteString(c, "GET /a HTTP/1.1\r\nHost: foo\r\n\r\n"+
+		"POST /b HTTP/1.1\r\nHost: bar\r\nContent-Length: 5\r\n\r\nhello")
+	for _, want := range []struct{ path, body string }{
+		{"/a", "GET foo "},
+		{"/b", "POST bar hello"},
+	} {
+		res := readTestResponse(t, br, "GET")
+		if res.code != StatusOK || res.header.Get("X-Path") != want.path || res.body != want.body {
+			t.Fatalf("got %d %q %q, want 200 %q %q", res.code, res.header.Get("X-Path"), res.body, want.path, want.body)
+		}
+		if res.header.Get("Content-Length") != strconv.Itoa(len(want.body)) {
+			t.Errorf("Content-Length = %q", res.header.Get("Content-Length"))
+		}
+		if res.header.Get("Date") == "" {
+			t.Error("missing Date")
+		}
+		if res.header.Get("Connection") != "" {
+			t.Errorf("Connection = %q on a kept-alive connection", res.header.Get("Connection"))
+		}
+	}
+
+	io.WriteString(c, "GET /c HTTP/1.1\r\nHost: foo\r\nConnection: close\r\n\r\n")
+	res := readTestResponse(t, br, "GET")
+	if res.header.Get("Connection") != "close" {
+		t.Errorf("Connection = %q, want close", res.header.Get("Connection"))
+	}
+	expectClosed(t, br)
+}
+
+func TestServeUnreadBody(t *testing.T) {
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "ok")
+	})})
+	c, br := dialTest(t, addr)
+
+	// 处理函数没有读的body被丢掉, 下一个请求还能正常读
+	io.WriteString(c, "POST / HTTP/1.1\r\nHost: foo\r\nContent-Length: 3\r\n\r\nabc"+
+		"POST / HTTP/1.1\r\nHost: foo\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n"+
+		"GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	for i := 0; i < 3; i++ {
+		if res := readTestResponse(t, br, "GET"); res.code != StatusOK || res.body != "ok" {
+			t.Fatalf("response %d: %d %q", i, res.code, res.body)
+		}
+	}
+}
+
+func TestServeMalformedChunkedBody(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		io.WriteString(w, "ok")
+	})})
+	c, br := dialTest(t, addr)
+
+	// 没读完的chunked body是坏的, 后面的字节不能被当成下一个请求
+	io.WriteString(c, "POST /a HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n"+
+		"GET /admin HTTP/1.1\r\nHost: x\r\n\r\n")
+	if res := readTestResponse(t, br, "GET"); res.code != StatusOK {
+		t.Fatalf("response: %d %q", res.code, res.body)
+	}
+	expectClosed(t, br)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 1 || paths[0] != "/a" {
+		t.Errorf("handled paths = %q; want [/a]", paths)
+	}
+}
+
+func TestServeChunkedRequest(t *testing.T) {
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.ContentLength != -1 {
+			t.Errorf("ContentLength = %d, want -1", r.ContentLength)
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		io.WriteString(w, string(b)+" "+r.Trailer.Get("X-Sum"))
+	})})
+	c, br := dialTest(t, addr)
+
+	io.WriteString(c, "POST / HTTP/1.1\r\nHost: foo\r\nTransfer-Encoding: chunked\r\nTrailer: X-Sum\r\n\r\n"+
+		"5;ext=1\r\nhello\r\n6\r\n world\r\n0\r\nX-Sum: 42\r\n\r\n")
+	if res := readTestResponse(t, br, "GET"); res.body != "hello world 42" {
+		t.Fatalf("body = %q", res.body)
+	}
+}
+
+func TestServeChunkedResponse(t *testing.T) {
+	next := make(chan bool)
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "first")
+		w.(Flusher).Flush()
+		<-next
+		io.WriteString(w, "second")
+	})})
+	c, br := dialTest(t, addr)
+
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	// Flush以后header与第一块必须已经发出来了, 处理函数还没有返回
+	tp := textproto.NewReader(br)
+	if line, _ := tp.ReadLine(); line != "HTTP/1.1 200 OK" {
+		t.Fatalf("status line %q", line)
+	}
+	h, err := tp.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if te := h.Get("Transfer-Encoding"); te != "chunked" {
+		t.Fatalf("Transfer-Encoding = %q, want chunked", te)
+	}
+	cr := newChunkedReader(br)
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(cr, buf); err != nil || string(buf) != "first" {
+		t.Fatalf("first chunk %q, %v", buf, err)
+	}
+	next <- true
+	rest, err := io.ReadAll(cr)
+	if err != nil || string(rest) != "second" {
+		t.Fatalf("rest %q, %v", rest, err)
+	}
+	if line, _ := tp.ReadLine(); line != "" {
+		t.Fatalf("trailer %q", line)
+	}
+
+	// 连接还能继续用
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	go func() { next <- true }()
+	if res := readTestResponse(t, br, "GET"); res.body != "firstsecond" {
+		t.Fatalf("body = %q", res.body)
+	}
+}
+
+func TestServeLargeResponse(t *testing.T) {
+	big := strings.Repeat("x", 3*bufferBeforeChunkingSize)
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/cl" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(big)))
+		}
+		io.WriteString(w, big)
+	})})
+	c, br := dialTest(t, addr)
+
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\nGET /cl HTTP/1.1\r\nHost: foo\r\n\r\n")
+	res := readTestResponse(t, br, "GET")
+	if res.header.Get("Transfer-Encoding") != "chunked" || res.body != big {
+		t.Fatalf("unknown length: TE %q, %d bytes", res.header.Get("Transfer-Encoding"), len(res.body))
+	}
+	res = readTestResponse(t, br, "GET")
+	if res.header.Get("Transfer-Encoding") != "" || res.body != big {
+		t.Fatalf("declared length: TE %q, %d bytes", res.header.Get("Transfer-Encoding"), len(res.body))
+	}
+}
+
+func TestServeHEADAndNoBody(t *testing.T) {
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/204" {
+			w.WriteHeader(StatusNoContent)
+			if _, err := io.WriteString(w, "x"); err != ErrBodyNotAllowed {
+				t.Errorf("Write after 204 = %v, want ErrBodyNotAllowed", err)
+			}
+			return
+		}
+		io.WriteString(w, "hello")
+	})})
+	c, br := dialTest(t, addr)
+
+	io.WriteString(c, "HEAD / HTTP/1.1\r\nHost: foo\r\n\r\nGET /204 HTTP/1.1\r\nHost: foo\r\n\r\nGET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	if res := readTestResponse(t, br, "HEAD"); res.header.Get("Content-Length") != "5" {
+		t.Errorf("HEAD Content-Length = %q, want 5", res.header.Get("Content-Length"))
+	}
+	if res := readTestResponse(t, br, "GET"); res.code != StatusNoContent || res.header.Get("Content-Length") != "" {
+		t.Errorf("204: %d, Content-Length %q", res.code, res.header.Get("Content-Length"))
+	}
+	if res := readTestResponse(t, br, "GET"); res.body != "hello" {
+		t.Errorf("body = %q", res.body)
+	}
+}
+
+func TestServeHTTP10(t *testing.T) {
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "hello")
+		if r.URL.Path == "/stream" {
+			w.(Flusher).Flush()
+		}
+	})})
+
+	// 客户端没有要求keep-alive
+	c, br := dialTest(t, addr)
+	io.WriteString(c, "GET / HTTP/1.0\r\n\r\n")
+	if res := readTestResponse(t, br, "GET"); res.proto != "HTTP/1.0" || res.body != "hello" {
+		t.Fatalf("got %s %q", res.proto, res.body)
+	}
+	expectClosed(t, br)
+
+	// 要求了keep-alive, 长度已知
+	c, br = dialTest(t, addr)
+	io.WriteString(c, "GET / HTTP/1.0\r\nConnection: keep-alive\r\n\r\n")
+	res := readTestResponse(t, br, "GET")
+	if res.header.Get("Connection") != "keep-alive" {
+		t.Fatalf("Connection = %q, want keep-alive", res.header.Get("Connection"))
+	}
+
+	// 长度未知, 不能用chunked, 只能关闭连接
+	io.WriteString(c, "GET /stream HTTP/1.0\r\nConnection: keep-alive\r\n\r\n")
+	res = readTestResponse(t, br, "GET")
+	if res.header.Get("Transfer-Encoding") != "" || res.body != "hello" {
+		t.Fatalf("TE %q, body %q", res.header.Get("Transfer-Encoding"), res.body)
+	}
+}
+
+func TestServeExpectContinue(t *testing.T) {
+	addr := newTestServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/read" {
+			b, _ := io.ReadAll(r.Body)
+			w.Write(b)
+			return
+		}
+		w.WriteHeader(StatusForbidden)
+	})})
+
+	c, br := dialTest(t, addr)
+	io.WriteString(c, "PUT /read HTTP/1.1\r\nHost: foo\r\nExpect: 100-continue\r\nContent-Length: 3\r\n\r\n")
+	if res := readTestResponse(t, br, "GET"); res.code != StatusContinue {
+		t.Fatalf("got %d, want 100", res.code)
+	}
+	io.WriteString(c, "abc")
+	if res := readTestResponse(t, br, "GET"); res.code != StatusOK || res.body != "abc" {
+		t.Fatalf("got %d %q", res.code, res.body)
+	}
+
+	// 处理函数不读body时不发100 Continue, 回复以后关闭连接
+	c, br = dialTest(t, addr)
+	io.WriteString(c, "PUT / HTTP/1.1\r\nHost: foo\r\nExpect: 100-continue\r\nContent-Length: 3\r\n\r\n")
+	if res := readTestResponse(t, br, "GET"); res.code != StatusForbidden || res.header.Get("Connection") != "close" {
+		t.Fatalf("got %d, Connection %q", res.code, res.header.Get("Connection"))
+	}
+	expectClosed(t, br)
+}
+
+func TestServeBadRequest(t *testing.T) {
+	addr := newTestServer(t, &Server{
+		MaxHeaderBytes: 1 << 10,
+		Handler:        HandlerFunc(func(w ResponseWriter, r *Request) {}),
+	})
+	tests := []struct {
+		req  string
+		code int
+	}{
+		{"GARBAGE\r\n\r\n", StatusBadRequest},
+		{"GET / HTTP/1.1\r\n\r\n", StatusBadRequest}, // 没有Host
+		{"GET / HTTP/1.1\r\nHost: a\r\nHost: b\r\n\r\n", StatusBadRequest},
+		{"GET / HTTP/2.0\r\nHost: a\r\n\r\n", StatusBadRequest},
+		{"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: -1\r\n\r\n", StatusBadRequest},
+		{"GET / HTTP/1.1\r\nHost: a\r\nX-Big: " + strings.Repeat("x", 8<<10) + "\r\n\r\n", StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, tt := range tests {
+		c, br := dialTest(t, addr)
+		io.WriteString(c, tt.req)
+		if res := readTestResponse(t, br, "GET"); res.code != tt.code {
+			t.Errorf("%.40q: got %d, want %d", tt.req, res.code, tt.code)
+		}
+		expectClosed(t, br)
+	}
+}
+
+func TestServeConnState(t *testing.T) {
+	var mu sync.Mutex
+	var states []ConnState
+	closed := make(chan bool)
+	addr := newTestServer(t, &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			io.WriteString(w, "ok")
+		}),
+		ConnState: func(c net.Conn, s ConnState) {
+			mu.Lock()
+			states = append(states, s)
+			mu.Unlock()
+			if s == StateClosed {
+				close(closed)
+			}
+		},
+	})
+	c, br := dialTest(t, addr)
+	for i := 0; i < 2; i++ {
+		io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+		readTestResponse(t, br, "GET")
+	}
+	c.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no StateClosed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []ConnState{StateNew, StateActive, StateIdle, StateActive, StateIdle, StateClosed}
+	if len(states) != len(want) {
+		t.Fatalf("states = %v, want %v", states, want)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("states = %v, want %v", states, want)
+		}
+	}
+}
+
+func TestServeDefaultServeMux(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("/tree/", func(w ResponseWriter, r *Request) { io.WriteString(w, "tree") })
+	mux.HandleFunc("/tree/leaf", func(w ResponseWriter, r *Request) { io.WriteString(w, "leaf") })
+	addr := newTestServer(t, &Server{Handler: mux})
+	c, br := dialTest(t, addr)
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/tree/x", StatusOK, "tree"},
+		{"/tree/leaf", StatusOK, "leaf"},
+		{"/tree", StatusMovedPermanently, ""},
+		{"/other", StatusNotFound, "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		io.WriteString(c, "GET "+tt.path+" HTTP/1.1\r\nHost: foo\r\n\r\n")
+		res := readTestResponse(t, br, "GET")
+		if res.code != tt.code || res.body != tt.body {
+			t.Errorf("%s: got %d %q, want %d %q", tt.path, res.code, res.body, tt.code, tt.body)
+		}
+	}
+}
+
+func TestChunkedReader(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{"0\r\n", "", false},
+		{"3\r\nabc\r\n0\r\n", "abc", false},
+		{"A;name=v\r\n0123456789\r\n1\r\nx\r\n0\r\n", "0123456789x", false},
+		{"3\r\nabcd\r\n0\r\n", "", true},    // 块比声明的长
+		{"3\r\nab", "", true},               // 连接断在块中间
+		{"zz\r\n", "", true},                // 长度不是十六进制
+		{"11111111111111111\r\n", "", true}, // 长度溢出
+		{"3\r\nabc\r\n", "", true},          // 没有最后一块
+		{"\r\n", "", true},                  // 空的长度
+	}
+	for _, tt := range tests {
+		got, err := io.ReadAll(newChunkedReader(bufio.NewReader(strings.NewReader(tt.in))))
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: no error", tt.in)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestHeaderWrite(t *testing.T) {
+	h := Header{
+		"Content-Type": {"text/html"},
+		"X-Multi":      {"a", "b"},
+		"X-Inject":     {"v\r\nEvil: 1"},
+		"Host":         {"foo"},
+	}
+	var buf bytes.Buffer
+	if err := h.WriteSubset(&buf, map[string]bool{"Host": true}); err != nil {
+		t.Fatal(err)
+	}
+	want := "Content-Type: text/html\r\nX-Inject: v  Evil: 1\r\nX-Multi: a\r\nX-Multi: b\r\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+// serveTest 和newTestServer一样, 另外返回Serve的返回值
+func serveTest(t *testing.T, srv *Server) (addr string, errc <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := make(chan error, 1)
+	go func() { c <- srv.Serve(ln) }()
+	return ln.Addr().String(), c
+}
+
+func waitServe(t *testing.T, errc <-chan error) {
+	t.Helper()
+	select {
+	case err := <-errc:
+		if err != ErrServerClosed {
+			t.Fatalf("Serve = %v, want ErrServerClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	started := make(chan bool)
+	release := make(chan bool)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/slow" {
+			started <- true
+			<-release
+		}
+		io.WriteString(w, "done")
+	})}
+	hooked := make(chan bool)
+	srv.RegisterOnShutdown(func() { close(hooked) })
+	addr, errc := serveTest(t, srv)
+
+	// idle: 处理完一个请求, 在keep-alive里等着
+	idle, idleBr := dialTest(t, addr)
+	io.WriteString(idle, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	readTestResponse(t, idleBr, "GET")
+
+	// active: 处理函数还没有返回
+	active, activeBr := dialTest(t, addr)
+	io.WriteString(active, "GET /slow HTTP/1.1\r\nHost: foo\r\n\r\n")
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- srv.Shutdown(context.Background()) }()
+
+	waitServe(t, errc)
+	<-hooked
+	expectClosed(t, idleBr)
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("listener still accepting after Shutdown")
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v with an active request", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 正在处理的请求正常结束, 因为在关闭中, 回复以后连接被关闭
+	release <- true
+	res := readTestResponse(t, activeBr, "GET")
+	if res.body != "done" || res.header.Get("Connection") != "close" {
+		t.Errorf("got %q, Connection %q", res.body, res.header.Get("Connection"))
+	}
+	expectClosed(t, activeBr)
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+
+	if err := srv.Serve(&net.TCPListener{}); err != ErrServerClosed {
+		t.Errorf("Serve after Shutdown = %v, want ErrServerClosed", err)
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	release := make(chan bool)
+	defer close(release)
+	started := make(chan bool)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		started <- true
+		<-release
+	})}
+	addr, errc := serveTest(t, srv)
+	c, _ := dialTest(t, addr)
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown = %v, want DeadlineExceeded", err)
+	}
+	waitServe(t, errc)
+}
+
+func TestServerClose(t *testing.T) {
+	started := make(chan bool)
+	release := make(chan bool)
+	defer close(release)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		started <- true
+		<-release
+	})}
+	addr, errc := serveTest(t, srv)
+	c, br := dialTest(t, addr)
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	<-started
+
+	// Close不等正在处理的请求
+	if err := srv.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	waitServe(t, errc)
+	expectClosed(t, br)
+	if err := srv.ListenAndServe(); err != ErrServerClosed {
+		t.Errorf("ListenAndServe after Close = %v, want ErrServerClosed", err)
+	}
+}
+
+func TestSetKeepAlivesEnabled(t *testing.T) {
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "ok")
+	})}
+	addr := newTestServer(t, srv)
+
+	c, br := dialTest(t, addr)
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	readTestResponse(t, br, "GET")
+
+	srv.SetKeepAlivesEnabled(false)
+	expectClosed(t, br)
+
+	c, br = dialTest(t, addr)
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	if res := readTestResponse(t, br, "GET"); res.header.Get("Connection") != "close" {
+		t.Errorf("Connection = %q, want close", res.header.Get("Connection"))
+	}
+	expectClosed(t, br)
+}
+
+func TestCloseIdleConnsStateNew(t *testing.T) {
+	srv := &Server{}
+	conns := make(map[ConnState]*conn)
+	ends := make(map[ConnState]net.Conn)
+	for _, st := range []ConnState{StateNew, StateActive, StateIdle} {
+		a, b := net.Pipe()
+		t.Cleanup(func() { a.Close(); b.Close() })
+		c := srv.newConn(a)
+		c.setState(a, StateNew)
+		c.setState(a, st)
+		conns[st], ends[st] = c, b
+	}
+	closed := func(st ConnState) bool {
+		ends[st].SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		_, err := ends[st].Read(make([]byte, 1))
+		return err == io.EOF
+	}
+
+	// 刚建立的连接还在等第一个请求, 不能关; 空闲的连接关掉
+	if srv.closeIdleConns() {
+		t.Error("quiescent with new and active connections")
+	}
+	if !closed(StateIdle) || closed(StateNew) || closed(StateActive) {
+		t.Fatalf("closed idle=%v new=%v active=%v, want only idle", closed(StateIdle), closed(StateNew), closed(StateActive))
+	}
+
+	// 超过5秒还没有发请求的连接当作空闲的
+	conns[StateNew].curState.Store(uint64(time.Now().Unix()-6)<<8 | uint64(StateNew))
+	if srv.closeIdleConns() {
+		t.Error("quiescent with an active connection")
+	}
+	if !closed(StateNew) || closed(StateActive) {
+		t.Fatalf("closed new=%v active=%v, want only new", closed(StateNew), closed(StateActive))
+	}
+
+	conns[StateActive].setState(conns[StateActive].rwc, StateIdle)
+	if !srv.closeIdleConns() {
+		t.Error("not quiescent after the last connection went idle")
+	}
+}
@@ -1 +1,489 @@
 package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrBodyReadAfterClose is returned when reading a Request or Response
+// Body after the body has been closed. This typically happens when the body is
+// read after an HTTP Handler calls WriteHeader or Write on its
+// ResponseWriter.
+var ErrBodyReadAfterClose = errors.New("http: invalid Read on closed Body")
+
+// NoBody is an io.ReadCloser with no bytes. Read always returns EOF
+// and Close always returns nil. It can be used in an outgoing client
+// request to explicitly signal that a request has zero bytes.
+var NoBody = noBody{}
+
+type noBody struct{}
+
+func (noBody) Read([]byte) (int, error)         { return 0, io.EOF }
+func (noBody) Close() error                     { return nil }
+func (noBody) WriteTo(io.Writer) (int64, error) { return 0, nil }
+
+// transferWriter inspects the fields of a user-supplied Request,
+// sanitizes them without changing the user object and provides methods
+// for writing the header, body and trailer in wire format.
+type transferWriter struct {
+	Method           string
+	Body             io.Reader
+	BodyCloser       io.Closer
+	ContentLength    int64 // -1 means unknown, 0 means exactly none
+	Close            bool
+	TransferEncoding []string
+	Trailer          Header
+
+	FlushHeaders bool // flush headers to network before body
+}
+
+func newTransferWriter(r *Request) (*transferWriter, error) {
+	t := &transferWriter{
+		Method:  valueOrDefault(r.Method, "GET"),
+		Close:   r.Close,
+		Trailer: r.Trailer,
+	}
+	if r.ContentLength != 0 && r.Body == nil {
+		return nil, fmt.Errorf("http: Request.ContentLength=%d with nil Body", r.ContentLength)
+	}
+	if r.Body != nil && r.Body != NoBody {
+		t.Body = r.Body
+		t.BodyCloser = r.Body
+		// For client requests, a ContentLength of 0 with a
+		// non-nil Body means the length is unknown.
+		t.ContentLength = r.ContentLength
+		if t.ContentLength == 0 {
+			t.ContentLength = -1
+		}
+	}
+
+	// A body of unknown length goes out chunked, unless the caller
+	// asked for "identity", in which case the body ends when the
+	// connection does.
+	identity := len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "identity"
+	if !identity {
+		t.TransferEncoding = r.TransferEncoding
+		if t.ContentLength < 0 && len(t.TransferEncoding) == 0 && t.Method != "CONNECT" {
+			t.TransferEncoding = []string{"chunked"}
+		}
+	}
+	if t.ContentLength < 0 && !chunked(t.TransferEncoding) {
+		t.Close = true
+	}
+	if len(t.Trailer) > 0 && !chunked(t.TransferEncoding) {
+		return nil, ErrUnexpectedTrailer
+	}
+	return t, nil
+}
+
+// Checks whether chunked is part of the encodings stack
+func chunked(te []string) bool { return len(te) > 0 && te[0] == "chunked" }
+
+func (t *transferWriter) shouldSendContentLength() bool {
+	if chunked(t.TransferEncoding) {
+		return false
+	}
+	if t.ContentLength > 0 {
+		return true
+	}
+	if t.ContentLength < 0 {
+		return false
+	}
+	// Many servers expect a Content-Length for these methods
+	return t.Method == "POST" || t.Method == "PUT"
+}
+
+func (t *transferWriter) WriteHeader(w io.Writer) error {
+	if t.Close {
+		if _, err := io.WriteString(w, "Connection: close\r\n"); err != nil {
+			return err
+		}
+	}
+
+	// Write Content-Length and/or Transfer-Encoding whose values are a
+	// function of the sanitized field triple (Body, ContentLength,
+	// TransferEncoding)
+	if t.shouldSendContentLength() {
+		if _, err := io.WriteString(w, "Content-Length: "+strconv.FormatInt(t.ContentLength, 10)+"\r\n"); err != nil {
+			return err
+		}
+	} else if chunked(t.TransferEncoding) {
+		if _, err := io.WriteString(w, "Transfer-Encoding: chunked\r\n"); err != nil {
+			return err
+		}
+	}
+
+	if t.Trailer != nil {
+		keys := make([]string, 0, len(t.Trailer))
+		for k := range t.Trailer {
+			k = CanonicalHeaderKey(k)
+			switch k {
+			case "Transfer-Encoding", "Trailer", "Content-Length":
+				return &badStringError{"invalid Trailer key", k}
+			}
+			keys = append(keys, k)
+		}
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			if _, err := io.WriteString(w, "Trailer: "+strings.Join(keys, ",")+"\r\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *transferWriter) WriteBody(w io.Writer) error {
+	var err error
+	var ncopy int64
+
+	// Write body
+	if t.Body != nil {
+		if chunked(t.TransferEncoding) {
+			cw := newChunkedWriter(w)
+			_, err = io.Copy(cw, t.Body)
+			if err == nil {
+				err = cw.Close()
+			}
+		} else if t.ContentLength == -1 {
+			ncopy, err = io.Copy(w, t.Body)
+		} else {
+			ncopy, err = io.Copy(w, io.LimitReader(t.Body, t.ContentLength))
+			if err != nil {
+				return err
+			}
+			var nextra int64
+			nextra, err = io.Copy(io.Discard, t.Body)
+			ncopy += nextra
+		}
+		if err != nil {
+			return err
+		}
+		if err = t.BodyCloser.Close(); err != nil {
+			return err
+		}
+	}
+
+	if t.ContentLength != -1 && t.ContentLength != ncopy {
+		return fmt.Errorf("http: ContentLength=%d with Body length %d", t.ContentLength, ncopy)
+	}
+
+	if chunked(t.TransferEncoding) {
+		// Write Trailer header
+		if t.Trailer != nil {
+			if err := t.Trailer.Write(w); err != nil {
+				return err
+			}
+		}
+		// Last chunk, empty trailer
+		_, err = io.WriteString(w, "\r\n")
+	}
+	return err
+}
+
+// readTransfer sets req.Body, ContentLength, TransferEncoding and
+// Trailer from the header of an incoming request whose body follows
+// on r.
+func readTransfer(req *Request, r *bufio.Reader) error {
+	chunked, err := fixTransferEncoding(req)
+	if err != nil {
+		return err
+	}
+	if req.ContentLength, err = fixLength(req.Header, chunked); err != nil {
+		return err
+	}
+	if req.Trailer, err = fixTrailer(req.Header, chunked); err != nil {
+		return err
+	}
+
+	// A request without Content-Length or chunked encoding has no body;
+	// unlike a response, it can't be delimited by closing the connection.
+	switch {
+	case chunked:
+		req.Body = &body{src: newChunkedReader(r), hdr: req, r: r, closing: req.Close}
+	case req.ContentLength > 0:
+		req.Body = &body{src: io.LimitReader(r, req.ContentLength), closing: req.Close}
+	default:
+		req.Body = NoBody
+	}
+	return nil
+}
+
+// fixTransferEncoding removes Transfer-Encoding from the header and
+// reports whether the body is chunked. Only "chunked" is supported.
+func fixTransferEncoding(req *Request) (bool, error) {
+	raw, present := req.Header["Transfer-Encoding"]
+	if !present {
+		return false, nil
+	}
+	delete(req.Header, "Transfer-Encoding")
+
+	// Transfer-Encoding was added in HTTP/1.1; earlier clients that send
+	// it anyway are ignored.
+	if !req.ProtoAtLeast(1, 1) {
+		return false, nil
+	}
+	if len(raw) != 1 || !strings.EqualFold(textproto.TrimString(raw[0]), "chunked") {
+		return false, &badStringError{"unsupported transfer encoding", strings.Join(raw, ",")}
+	}
+	req.TransferEncoding = []string{"chunked"}
+	return true, nil
+}
+
+// fixLength returns the length of the body, or -1 if it is chunked.
+// Per RFC 7230 section 3.3.3 Content-Length is ignored (and removed)
+// when the body is chunked.
+func fixLength(header Header, chunked bool) (int64, error) {
+	contentLens := header["Content-Length"]
+	if chunked {
+		header.Del("Content-Length")
+		return -1, nil
+	}
+	if len(contentLens) > 1 {
+		// Identical duplicates are harmless, anything else is an attack.
+		first := textproto.TrimString(contentLens[0])
+		for _, ct := range contentLens[1:] {
+			if first != textproto.TrimString(ct) {
+				return 0, &badStringError{"message cannot contain multiple Content-Length headers", strings.Join(contentLens, ",")}
+			}
+		}
+		header.Del("Content-Length")
+		header.Add("Content-Length", first)
+		contentLens = header["Content-Length"]
+	}
+	if len(contentLens) == 0 {
+		return 0, nil
+	}
+	cl := textproto.TrimString(contentLens[0])
+	n, err := strconv.ParseInt(cl, 10, 64)
+	if err != nil || n < 0 {
+		return 0, &badStringError{"bad Content-Length", cl}
+	}
+	return n, nil
+}
+
+// fixTrailer parses the Trailer header into a map with the announced keys
+// and nil values; the values are filled in once the body is read to EOF.
+func fixTrailer(header Header, chunked bool) (Header, error) {
+	vv, ok := header["Trailer"]
+	if !ok {
+		return nil, nil
+	}
+	header.Del("Trailer")
+
+	trailer := make(Header)
+	var err error
+	for _, v := range vv {
+		foreachHeaderElement(v, func(key string) {
+			key = CanonicalHeaderKey(key)
+			switch key {
+			case "Transfer-Encoding", "Trailer", "Content-Length":
+				if err == nil {
+					err = &badStringError{"bad trailer key", key}
+				}
+				return
+			}
+			trailer[key] = nil
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(trailer) == 0 {
+		return nil, nil
+	}
+	if !chunked {
+		// Trailer and no chunking.
+		return nil, ErrUnexpectedTrailer
+	}
+	return trailer, nil
+}
+
+// shouldClose determines whether to hang up after sending a request
+// and body, or receiving a response and body.
+func shouldClose(major, minor int, header Header) bool {
+	if major < 1 {
+		return true
+	}
+	conv := header["Connection"]
+	hasClose := headerValuesContainsToken(conv, "close")
+	if major == 1 && minor == 0 {
+		return hasClose || !headerValuesContainsToken(conv, "keep-alive")
+	}
+	return hasClose
+}
+
+// body turns a Reader into a ReadCloser.
+// Close ensures that the body has been fully read
+// and then reads the trailer if necessary.
+type body struct {
+	src          io.Reader
+	hdr          *Request      // non-nil if reading trailer
+	r            *bufio.Reader // underlying wire-format reader for the trailer
+	closing      bool          // is the connection to be closed after reading body?
+	doEarlyClose bool          // whether Close should stop early
+
+	mu         sync.Mutex // guards following, and calls to Read and Close
+	sawEOF     bool
+	closed     bool
+	earlyClose bool // Close called and we didn't read to the end of src
+}
+
+func (b *body) Read(p []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+	return b.readLocked(p)
+}
+
+// Must hold b.mu.
+func (b *body) readLocked(p []byte) (n int, err error) {
+	if b.sawEOF {
+		return 0, io.EOF
+	}
+	n, err = b.src.Read(p)
+
+	if err == io.EOF {
+		b.sawEOF = true
+		// Chunked case. Read the trailer.
+		if b.hdr != nil {
+			if e := b.readTrailer(); e != nil {
+				err = e
+				// Something went wrong in the trailer, we must not allow any
+				// further reads of any kind to succeed from body, nor any
+				// subsequent requests on the server connection.
+				b.sawEOF = false
+				b.closed = true
+			}
+			b.hdr = nil
+		} else if lr, ok := b.src.(*io.LimitedReader); ok && lr.N > 0 {
+			// If the server declared the Content-Length, our body is a LimitedReader
+			// and we need to check whether this EOF arrived early.
+			err = io.ErrUnexpectedEOF
+		}
+	}
+
+	// If we can return an EOF here along with the read data, do so.
+	// This is optional per the io.Reader contract, but doing so helps
+	// the server loop reuse the connection without waiting for another Read.
+	if err == nil && n > 0 {
+		if lr, ok := b.src.(*io.LimitedReader); ok && lr.N == 0 {
+			err = io.EOF
+			b.sawEOF = true
+		}
+	}
+	return n, err
+}
+
+var singleCRLF = []byte("\r\n")
+
+var errTrailerEOF = errors.New("http: unexpected EOF reading trailer")
+
+func (b *body) readTrailer() error {
+	// The common case, since nobody uses trailers.
+	buf, err := b.r.Peek(2)
+	if bytes.Equal(buf, singleCRLF) {
+		b.r.Discard(2)
+		return nil
+	}
+	if len(buf) < 2 {
+		return errTrailerEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	hdr, err := textproto.NewReader(b.r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF {
+			return errTrailerEOF
+		}
+		return err
+	}
+	mergeSetHeader(&b.hdr.Trailer, Header(hdr))
+	return nil
+}
+
+// maxPostHandlerReadBytes is the max number of Request.Body bytes not
+// consumed by a handler that the server will read from the client
+// in order to keep a connection alive. If there are more bytes than
+// this then the server closes the connection instead.
+const maxPostHandlerReadBytes = 256 << 10
+
+func (b *body) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	var err error
+	switch {
+	case b.sawEOF:
+		// Already saw EOF, so no need going to look for it.
+	case b.hdr == nil && b.closing:
+		// no trailer and closing the connection next.
+		// no point in reading to EOF.
+	case b.doEarlyClose:
+		// Read up to maxPostHandlerReadBytes bytes of the body, looking
+		// for EOF (and trailers), so we can re-use this connection.
+		if lr, ok := b.src.(*io.LimitedReader); ok && lr.N > maxPostHandlerReadBytes {
+			// There was a declared Content-Length, and we have more bytes remaining
+			// than our maxPostHandlerReadBytes tolerance. So, give up.
+			b.earlyClose = true
+		} else {
+			var n int64
+			// Consume the body, or, which will also lead to us reading
+			// the trailer headers after the body, if present.
+			n, err = io.CopyN(io.Discard, bodyLocked{b}, maxPostHandlerReadBytes)
+			if err == io.EOF {
+				err = nil
+			}
+			if n == maxPostHandlerReadBytes {
+				b.earlyClose = true
+			}
+		}
+	default:
+		// Fully consume the body, which will also lead to us reading
+		// the trailer headers after the body, if present.
+		_, err = io.Copy(io.Discard, bodyLocked{b})
+	}
+	b.closed = true
+	return err
+}
+
+func (b *body) didEarlyClose() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.earlyClose
+}
+
+// bodyRemains reports whether future calls to Read
+// on b might yield more data.
+func (b *body) bodyRemains() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.sawEOF
+}
+
+// bodyLocked is a io.Reader reading from a *body when its mutex is
+// already held.
+type bodyLocked struct {
+	b *body
+}
+
+func (bl bodyLocked) Read(p []byte) (n int, err error) {
+	if bl.b.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+	return bl.b.readLocked(p)
+}
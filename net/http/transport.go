@@ -1,3 +1,8 @@
+// Transport还没有写完: 连接池(persistConn, connLRU)与RoundTripper都还没有,
+// 写完之前不参与编译, 否则整个包都编译不了
+
+//go:build ignore
+
 package http
 
 import (